@@ -0,0 +1,278 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/civil"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// avroBlockRows is the number of rows in a block of Avro object container files, which bounds the memory to buffer rows.
+const avroBlockRows = 1000
+
+// avroWriter writes rows to an Avro object container file. All fields are nullable.
+type avroWriter struct {
+	out io.Writer
+
+	columnNames []string
+	ocf         *goavro.OCFWriter
+	block       []interface{}
+}
+
+func (w *avroWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	if err := validateExportColumnNames(columnNames); err != nil {
+		return err
+	}
+	schema, err := avroSchema(columnNames, columnTypes)
+	if err != nil {
+		return err
+	}
+	ocf, err := goavro.NewOCFWriter(goavro.OCFConfig{W: w.out, Schema: schema, CompressionName: goavro.CompressionSnappyLabel})
+	if err != nil {
+		return err
+	}
+	w.columnNames = columnNames
+	w.ocf = ocf
+	return nil
+}
+
+func (w *avroWriter) WriteRow(row Row) error {
+	record := make(map[string]interface{}, len(row.Values))
+	for i, value := range row.Values {
+		v, err := avroValue(value.Type, value.Value)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", w.columnNames[i], err)
+		}
+		record[w.columnNames[i]] = v
+	}
+	w.block = append(w.block, record)
+	if len(w.block) >= avroBlockRows {
+		return w.appendBlock()
+	}
+	return nil
+}
+
+func (w *avroWriter) Flush() error {
+	return w.appendBlock()
+}
+
+func (w *avroWriter) appendBlock() error {
+	if len(w.block) == 0 {
+		return nil
+	}
+	if err := w.ocf.Append(w.block); err != nil {
+		return err
+	}
+	w.block = w.block[:0]
+	return nil
+}
+
+// avroSchema returns the schema of a record of the columns.
+func avroSchema(columnNames []string, columnTypes []*pb.StructType_Field) (string, error) {
+	var fields []interface{}
+	for i, field := range columnTypes {
+		typ, _, err := avroType(field.GetType())
+		if err != nil {
+			return "", fmt.Errorf("column %q: %w", columnNames[i], err)
+		}
+		fields = append(fields, map[string]interface{}{
+			"name":    columnNames[i],
+			"type":    []interface{}{"null", typ},
+			"default": nil,
+		})
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"type":      "record",
+		"name":      "Row",
+		"namespace": "spanner",
+		"fields":    fields,
+	})
+	return string(b), err
+}
+
+// avroType returns the schema of the type and its name in unions.
+func avroType(typ *pb.Type) (interface{}, string, error) {
+	switch typ.GetCode() {
+	case pb.TypeCode_BOOL:
+		return "boolean", "boolean", nil
+	case pb.TypeCode_INT64, pb.TypeCode_ENUM:
+		return "long", "long", nil
+	case pb.TypeCode_FLOAT32:
+		return "float", "float", nil
+	case pb.TypeCode_FLOAT64:
+		return "double", "double", nil
+	case pb.TypeCode_NUMERIC:
+		return map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": numericPrecision, "scale": numericScale}, "bytes.decimal", nil
+	case pb.TypeCode_STRING:
+		return "string", "string", nil
+	case pb.TypeCode_JSON:
+		// sqlType is the same annotation as Avro files exported by Dataflow templates.
+		return map[string]interface{}{"type": "string", "sqlType": "JSON"}, "string", nil
+	case pb.TypeCode_BYTES, pb.TypeCode_PROTO:
+		return "bytes", "bytes", nil
+	case pb.TypeCode_DATE:
+		return map[string]interface{}{"type": "int", "logicalType": "date"}, "int.date", nil
+	case pb.TypeCode_TIMESTAMP:
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}, "long.timestamp-micros", nil
+	case pb.TypeCode_ARRAY:
+		if typ.GetArrayElementType().GetCode() == pb.TypeCode_ARRAY {
+			return nil, "", fmt.Errorf("type %s is not supported", formatTypeSimple(typ))
+		}
+		elem, _, err := avroType(typ.GetArrayElementType())
+		if err != nil {
+			return nil, "", err
+		}
+		return map[string]interface{}{"type": "array", "items": []interface{}{"null", elem}}, "array", nil
+	default:
+		return nil, "", fmt.Errorf("type %s is not supported", formatTypeSimple(typ))
+	}
+}
+
+// avroValue converts a value into the native form of goavro, where a non-NULL value is wrapped as a member of a union.
+func avroValue(typ *pb.Type, v *structpb.Value) (interface{}, error) {
+	if _, ok := v.GetKind().(*structpb.Value_NullValue); ok {
+		return nil, nil
+	}
+	_, name, err := avroType(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ.GetCode() == pb.TypeCode_ARRAY {
+		var elems []interface{}
+		for _, elem := range v.GetListValue().GetValues() {
+			e, err := avroValue(typ.GetArrayElementType(), elem)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, e)
+		}
+		return goavro.Union(name, elems), nil
+	}
+
+	native, err := exportValue(typ, v)
+	if err != nil {
+		return nil, err
+	}
+	switch n := native.(type) {
+	case float64:
+		if typ.GetCode() == pb.TypeCode_FLOAT32 {
+			native = float32(n)
+		}
+	case civil.Date:
+		native = int32(n.DaysSince(unixEpochDate))
+	}
+	return goavro.Union(name, native), nil
+}
+
+// avroLoadReader reads records of an Avro object container file as records of LOAD DATA.
+// Line numbers of the records are the 1-based record numbers.
+type avroLoadReader struct {
+	r    *offsetReader
+	ocf  *goavro.OCFReader
+	line int
+}
+
+func newAvroLoadReader(r io.Reader) (*avroLoadReader, error) {
+	reader := &avroLoadReader{r: &offsetReader{r: r}, line: 1}
+	ocf, err := goavro.NewOCFReader(reader.r)
+	if err != nil {
+		return nil, err
+	}
+	reader.ocf = ocf
+	return reader, nil
+}
+
+func (r *avroLoadReader) Read() (*loadRecord, error) {
+	datum, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	record := &loadRecord{Line: r.line, NextLine: r.line + 1, Offset: r.r.offset}
+	r.line++
+
+	fields, ok := datum.(map[string]interface{})
+	if !ok {
+		record.Err = fmt.Errorf("invalid Avro record: %v", datum)
+		return record, nil
+	}
+	record.Values = make(map[string]interface{}, len(fields))
+	for name, v := range fields {
+		record.Values[name] = normalizeAvroValue(v)
+	}
+	return record, nil
+}
+
+func (r *avroLoadReader) next() (interface{}, error) {
+	if !r.ocf.Scan() {
+		if err := r.ocf.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return r.ocf.Read()
+}
+
+// Skip skips records before the line, which is the record number.
+func (r *avroLoadReader) Skip(offset int64, line int) error {
+	for r.line < line {
+		if _, err := r.next(); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("can't skip to record %d beyond the end of the file", line)
+			}
+			return err
+		}
+		r.line++
+	}
+	return nil
+}
+
+func (r *avroLoadReader) Position() loadPosition {
+	return loadPosition{Offset: r.r.offset, Line: r.line}
+}
+
+// normalizeAvroValue unwraps unions, and converts values into the types accepted by loadValue.
+func normalizeAvroValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// A non-NULL member of a union is a map of a single entry keyed by its type name.
+		if len(v) == 1 {
+			for _, member := range v {
+				return normalizeAvroValue(member)
+			}
+		}
+		return v
+	case []interface{}:
+		elems := make([]interface{}, len(v))
+		for i, elem := range v {
+			elems[i] = normalizeAvroValue(elem)
+		}
+		return elems
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	default:
+		return v
+	}
+}
@@ -20,19 +20,20 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"os"
 	"os/signal"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/spanner"
-	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/chzyer/readline"
-	"github.com/olekukonko/tablewriter"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -45,33 +46,71 @@ const (
 	DisplayModeTable DisplayMode = iota
 	DisplayModeVertical
 	DisplayModeTab
-
-	defaultPrompt      = `spanner\t> `
+	DisplayModeCSV
+	DisplayModeJSON
+	DisplayModeJSONL
+	DisplayModeMarkdown
+	DisplayModeHTML
+	DisplayModeTemplate
+
+	defaultPrompt      = `spanner\t\b> `
 	defaultHistoryFile = `/tmp/spanner_cli_readline.tmp`
 
+	// Table mode computes column widths from this number of rows, and streams the rest.
+	defaultTablePreviewRows = 1000
+
 	exitCodeSuccess = 0
 	exitCodeError   = 1
 )
 
+var displayModeNames = map[DisplayMode]string{
+	DisplayModeTable:    "TABLE",
+	DisplayModeVertical: "VERTICAL",
+	DisplayModeTab:      "TAB",
+	DisplayModeCSV:      "CSV",
+	DisplayModeJSON:     "JSON",
+	DisplayModeJSONL:    "JSONL",
+	DisplayModeMarkdown: "MARKDOWN",
+	DisplayModeHTML:     "HTML",
+	DisplayModeTemplate: "TEMPLATE",
+}
+
+func (m DisplayMode) String() string {
+	return displayModeNames[m]
+}
+
+func parseDisplayMode(s string) (DisplayMode, error) {
+	for mode, name := range displayModeNames {
+		if strings.EqualFold(s, name) {
+			return mode, nil
+		}
+	}
+	var names []string
+	for mode := DisplayMode(0); int(mode) < len(displayModeNames); mode++ {
+		names = append(names, displayModeNames[mode])
+	}
+	return 0, fmt.Errorf("display mode must be one of %s, but got %q", strings.Join(names, ", "), s)
+}
+
 var (
 	promptReInTransaction = regexp.MustCompile(`\\t`)
+	promptReInBatch       = regexp.MustCompile(`\\b`)
 	promptReProjectId     = regexp.MustCompile(`\\p`)
 	promptReInstanceId    = regexp.MustCompile(`\\i`)
 	promptReDatabaseId    = regexp.MustCompile(`\\d`)
 )
 
 type Cli struct {
-	Session       *Session
-	Prompt        string
-	HistoryFile   string
-	Credential    []byte
-	InStream      io.ReadCloser
-	OutStream     io.Writer
-	ErrStream     io.Writer
-	Verbose       bool
-	Priority      pb.RequestOptions_Priority
-	Endpoint      string
-	SkipTLSVerify bool
+	Session         *Session
+	Prompt          string
+	HistoryFile     string
+	Credential      []byte
+	InStream        io.ReadCloser
+	OutStream       io.Writer
+	ErrStream       io.Writer
+	SystemVariables *systemVariables
+	Endpoint        string
+	SkipTLSVerify   bool
 }
 
 type command struct {
@@ -79,8 +118,8 @@ type command struct {
 	Vertical bool
 }
 
-func NewCli(projectId, instanceId, databaseId, prompt, historyFile string, credential []byte, inStream io.ReadCloser, outStream io.Writer, errStream io.Writer, verbose bool, priority pb.RequestOptions_Priority, role string, endpoint string, directedRead *pb.DirectedReadOptions, skipTLSVerify bool) (*Cli, error) {
-	session, err := createSession(projectId, instanceId, databaseId, credential, priority, role, endpoint, directedRead, skipTLSVerify)
+func NewCli(projectId, instanceId, databaseId, prompt, historyFile string, credential []byte, inStream io.ReadCloser, outStream io.Writer, errStream io.Writer, sysVars *systemVariables, role string, endpoint string, skipTLSVerify bool) (*Cli, error) {
+	session, err := createSession(projectId, instanceId, databaseId, credential, sysVars, role, endpoint, skipTLSVerify)
 	if err != nil {
 		return nil, err
 	}
@@ -94,16 +133,16 @@ func NewCli(projectId, instanceId, databaseId, prompt, historyFile string, crede
 	}
 
 	return &Cli{
-		Session:       session,
-		Prompt:        prompt,
-		HistoryFile:   historyFile,
-		Credential:    credential,
-		InStream:      inStream,
-		OutStream:     outStream,
-		ErrStream:     errStream,
-		Verbose:       verbose,
-		Endpoint:      endpoint,
-		SkipTLSVerify: skipTLSVerify,
+		Session:         session,
+		Prompt:          prompt,
+		HistoryFile:     historyFile,
+		Credential:      credential,
+		InStream:        inStream,
+		OutStream:       outStream,
+		ErrStream:       errStream,
+		SystemVariables: sysVars,
+		Endpoint:        endpoint,
+		SkipTLSVerify:   skipTLSVerify,
 	}, nil
 }
 
@@ -153,7 +192,11 @@ func (c *Cli) RunInteractive() int {
 		}
 
 		if s, ok := stmt.(*UseStatement); ok {
-			newSession, err := createSession(c.Session.projectId, c.Session.instanceId, s.Database, c.Credential, c.Priority, s.Role, c.Endpoint, c.Session.directedRead, c.SkipTLSVerify)
+			instanceId := c.Session.instanceId
+			if s.Instance != "" {
+				instanceId = s.Instance
+			}
+			newSession, err := createSession(c.Session.projectId, instanceId, s.Database, c.Credential, c.SystemVariables, s.Role, c.Endpoint, c.SkipTLSVerify)
 			if err != nil {
 				c.PrintInteractiveError(err)
 				continue
@@ -171,6 +214,18 @@ func (c *Cli) RunInteractive() int {
 				continue
 			}
 
+			if c.SystemVariables.DirectedRead != nil {
+				if err := newSession.ValidateDirectedRead(context.Background()); err != nil {
+					newSession.Close()
+					c.PrintInteractiveError(err)
+					continue
+				}
+				c.PrintWarnings(newSession.TakeWarnings())
+			}
+
+			// Query parameters are client-side state, so they survive switching databases.
+			newSession.params = c.Session.params
+
 			c.Session.Close()
 			c.Session = newSession
 			fmt.Fprintf(c.OutStream, "Database changed")
@@ -188,12 +243,30 @@ func (c *Cli) RunInteractive() int {
 			}
 		}
 
+		if s, ok := stmt.(*DropBackupStatement); ok {
+			if !confirm(c.OutStream, fmt.Sprintf("Backup %q will be deleted.\nDo you want to continue?", s.Backup)) {
+				continue
+			}
+		}
+
+		if s, ok := stmt.(*DropBackupScheduleStatement); ok {
+			if !confirm(c.OutStream, fmt.Sprintf("Backup schedule %q will be deleted.\nDo you want to continue?", s.Schedule)) {
+				continue
+			}
+		}
+
+		if s, ok := stmt.(*RestoreRowsStatement); ok {
+			if !c.confirmRestoreRows(s, input.delim == delimiterVertical) {
+				continue
+			}
+		}
+
 		// Execute the statement.
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := c.withStatementTimeout(context.Background())
 		go handleInterrupt(cancel)
-		stop := c.PrintProgressingMark()
+		stop := sync.OnceFunc(c.PrintProgressingMark())
 		t0 := time.Now()
-		result, err := stmt.Execute(ctx, c.Session)
+		result, err := c.executeStatement(ctx, stmt, input.delim == delimiterVertical, stop)
 		elapsed := time.Since(t0).Seconds()
 		stop()
 		if err != nil {
@@ -214,10 +287,8 @@ func (c *Cli) RunInteractive() int {
 			result.Stats.ElapsedTime = fmt.Sprintf("%0.2f sec", elapsed)
 		}
 
-		if input.delim == delimiterHorizontal {
-			c.PrintResult(result, DisplayModeTable, true)
-		} else {
-			c.PrintResult(result, DisplayModeVertical, true)
+		if err := c.PrintResult(result, input.delim == delimiterVertical, true); err != nil {
+			c.PrintInteractiveError(err)
 		}
 
 		fmt.Fprintf(c.OutStream, "\n")
@@ -225,6 +296,35 @@ func (c *Cli) RunInteractive() int {
 	}
 }
 
+// confirmRestoreRows prints the rows which will be restored by the statement, and asks whether to restore them.
+func (c *Cli) confirmRestoreRows(s *RestoreRowsStatement, vertical bool) bool {
+	ctx, cancel := c.withStatementTimeout(context.Background())
+	defer cancel()
+	go handleInterrupt(cancel)
+	stop := sync.OnceFunc(c.PrintProgressingMark())
+	t0 := time.Now()
+	result, rows, err := s.Preview(ctx, c.Session)
+	elapsed := time.Since(t0).Seconds()
+	stop()
+	if err != nil {
+		c.PrintInteractiveError(err)
+		return false
+	}
+	if rows == 0 {
+		fmt.Fprintf(c.OutStream, "No rows to restore\n\n")
+		return false
+	}
+
+	result.Stats.ElapsedTime = fmt.Sprintf("%0.2f sec", elapsed)
+	if err := c.PrintResult(result, vertical, true); err != nil {
+		c.PrintInteractiveError(err)
+		return false
+	}
+	return confirm(c.OutStream, fmt.Sprintf("%d rows of table %q will be restored.\nDo you want to continue?", rows, s.Table))
+}
+
+// RunBatch executes the statements of the input.
+// If displayTable is true by --table, `\G` is ignored and results are printed in the table format.
 func (c *Cli) RunBatch(input string, displayTable bool) int {
 	cmds, err := buildCommands(input)
 	if err != nil {
@@ -236,18 +336,23 @@ func (c *Cli) RunBatch(input string, displayTable bool) int {
 	go handleInterrupt(cancel)
 
 	for _, cmd := range cmds {
-		result, err := cmd.Stmt.Execute(ctx, c.Session)
+		vertical := cmd.Vertical && !displayTable
+		stmtCtx, stmtCancel := c.withStatementTimeout(ctx)
+		result, err := c.executeStatement(stmtCtx, cmd.Stmt, vertical, nil)
+		stmtCancel()
 		if err != nil {
 			c.PrintBatchError(err)
 			return exitCodeError
 		}
 
-		if displayTable {
-			c.PrintResult(result, DisplayModeTable, false)
-		} else if cmd.Vertical {
-			c.PrintResult(result, DisplayModeVertical, false)
-		} else {
-			c.PrintResult(result, DisplayModeTab, false)
+		if err := c.PrintResult(result, vertical, false); err != nil {
+			c.PrintBatchError(err)
+			return exitCodeError
+		}
+
+		// Stop at the interrupted statement.
+		if ctx.Err() != nil {
+			return exitCodeError
 		}
 	}
 
@@ -274,26 +379,108 @@ func (c *Cli) PrintBatchError(err error) {
 	fmt.Fprintf(c.ErrStream, "ERROR: %s\n", err)
 }
 
-func (c *Cli) PrintResult(result *Result, mode DisplayMode, interactive bool) {
-	printResult(c.OutStream, result, mode, interactive, c.Verbose)
+// PrintResult prints the result in the display mode of CLI_FORMAT, or vertically if vertical is true.
+func (c *Cli) PrintResult(result *Result, vertical, interactive bool) error {
+	mode := c.displayMode(vertical)
+	c.PrintWarnings(result.Warnings)
+	return printResult(c.OutStream, result, mode, interactive, c.SystemVariables)
+}
+
+func (c *Cli) PrintWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(c.ErrStream, "WARNING: %s\n", warning)
+	}
+}
+
+func (c *Cli) displayMode(vertical bool) DisplayMode {
+	if vertical {
+		return DisplayModeVertical
+	}
+	return c.SystemVariables.CLIFormat
+}
+
+// executeStatement executes the statement.
+// If the statement supports streaming, rows are written to the output as they arrive, and onOutput is called before the first write.
+func (c *Cli) executeStatement(ctx context.Context, stmt Statement, vertical bool, onOutput func()) (*Result, error) {
+	out := &notifyingWriter{out: c.OutStream, notify: onOutput}
+
+	var result *Result
+	var err error
+	streamed := true
+	switch s := stmt.(type) {
+	case scriptStatement:
+		// Scripts are written as is regardless of the display mode.
+		result, err = s.ExecuteScript(ctx, c.Session, out)
+	case streamingStatement:
+		w := newResultWriter(out, c.displayMode(vertical), c.SystemVariables)
+		result, err = s.ExecuteStream(ctx, c.Session, w)
+	default:
+		result, err = stmt.Execute(ctx, c.Session)
+		streamed = false
+	}
+	// STATEMENT_TAG is applied only to the next statement which sends requests, in the same way as the other clients.
+	if !isClientSideStatement(stmt) {
+		c.SystemVariables.StatementTag = ""
+	}
+	// Warnings added by the session, e.g. for the directed read option ignored in a read-write transaction.
+	warnings := c.Session.TakeWarnings()
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(result.Warnings, warnings...)
+	result.Streamed = streamed
+	return result, nil
+}
+
+// isClientSideStatement reports whether the statement only reads or writes the state of the session without requests.
+func isClientSideStatement(stmt Statement) bool {
+	switch stmt.(type) {
+	case *SetStatement, *ShowVariableStatement, *ShowVariablesStatement, *SetParamStatement, *UnsetParamStatement, *ShowParamsStatement:
+		return true
+	default:
+		return false
+	}
 }
 
+// withStatementTimeout returns a context which is canceled after STATEMENT_TIMEOUT if it is set.
+func (c *Cli) withStatementTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := c.SystemVariables.StatementTimeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// PrintProgressingMark prints a progressing mark, or the progress of the running operation if the session has it.
 func (c *Cli) PrintProgressingMark() func() {
 	progressMarks := []string{`-`, `\`, `|`, `/`}
 	ticker := time.NewTicker(time.Millisecond * 100)
+	done := make(chan struct{})
+	width := make(chan int) // width of the printed line to be cleared
 	go func() {
-		i := 0
-		for {
-			<-ticker.C
-			mark := progressMarks[i%len(progressMarks)]
-			fmt.Fprintf(c.OutStream, "\r%s", mark)
-			i++
+		var w int
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				width <- w
+				return
+			case <-ticker.C:
+			}
+			line := progressMarks[i%len(progressMarks)]
+			if progress := c.Session.Progress(); progress != "" {
+				line += " " + progress
+			}
+			fmt.Fprintf(c.OutStream, "\r%-*s", w, line)
+			if len(line) > w {
+				w = len(line)
+			}
 		}
 	}()
 
 	stop := func() {
 		ticker.Stop()
-		fmt.Fprintf(c.OutStream, "\r") // clear progressing mark
+		close(done)
+		c.Session.SetProgress("")
+		fmt.Fprintf(c.OutStream, "\r%s\r", strings.Repeat(" ", <-width)) // clear progressing mark
 	}
 	return stop
 }
@@ -312,10 +499,19 @@ func (c *Cli) getInterpolatedPrompt() string {
 		prompt = promptReInTransaction.ReplaceAllString(prompt, "")
 	}
 
+	switch {
+	case c.Session.InBatchMode(batchModeDDL):
+		prompt = promptReInBatch.ReplaceAllString(prompt, "(ddl batch)")
+	case c.Session.InBatchMode(batchModeDML):
+		prompt = promptReInBatch.ReplaceAllString(prompt, "(dml batch)")
+	default:
+		prompt = promptReInBatch.ReplaceAllString(prompt, "")
+	}
+
 	return prompt
 }
 
-func createSession(projectId string, instanceId string, databaseId string, credential []byte, priority pb.RequestOptions_Priority, role string, endpoint string, directedRead *pb.DirectedReadOptions, skipTLSVerify bool) (*Session, error) {
+func createSession(projectId string, instanceId string, databaseId string, credential []byte, sysVars *systemVariables, role string, endpoint string, skipTLSVerify bool) (*Session, error) {
 	var opts []option.ClientOption
 	if credential != nil {
 		opts = append(opts, option.WithCredentialsJSON(credential))
@@ -327,7 +523,7 @@ func createSession(projectId string, instanceId string, databaseId string, crede
 		creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
 		opts = append(opts, option.WithGRPCDialOption(grpc.WithTransportCredentials(creds)))
 	}
-	return NewSession(projectId, instanceId, databaseId, priority, role, directedRead, opts...)
+	return NewSession(projectId, instanceId, databaseId, sysVars, role, opts...)
 }
 
 func readInteractiveInput(rl *readline.Instance, prompt string) (*inputStatement, error) {
@@ -363,64 +559,49 @@ func readInteractiveInput(rl *readline.Instance, prompt string) (*inputStatement
 	}
 }
 
-func printResult(out io.Writer, result *Result, mode DisplayMode, interactive, verbose bool) {
-	if mode == DisplayModeTable {
-		table := tablewriter.NewWriter(out)
-		table.SetAutoFormatHeaders(false)
-		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		table.SetAutoWrapText(false)
-
-		var forceTableRender bool
-		// This condition is true if statement is SelectStatement or DmlStatement
-		if verbose && len(result.ColumnTypes) > 0 {
-			forceTableRender = true
-			var headers []string
-			for _, field := range result.ColumnTypes {
-				typename := formatTypeSimple(field.GetType())
-				headers = append(headers, field.GetName()+"\n"+typename)
-			}
-			table.SetHeader(headers)
-		} else {
-			table.SetHeader(result.ColumnNames)
-		}
+func printResult(out io.Writer, result *Result, mode DisplayMode, interactive bool, sysVars *systemVariables) error {
+	verbose := sysVars.CLIVerbose
 
-		for _, row := range result.Rows {
-			table.Append(row.Columns)
+	// Rows of a streamed result have been already written.
+	if !result.Streamed {
+		if err := writeResult(newResultWriter(out, mode, sysVars), result); err != nil {
+			return err
 		}
+	}
 
-		if forceTableRender || len(result.Rows) > 0 {
-			table.Render()
-		}
-	} else if mode == DisplayModeVertical {
-		max := 0
-		for _, columnName := range result.ColumnNames {
-			if len(columnName) > max {
-				max = len(columnName)
+	if len(result.Predicates) > 0 {
+		printPredicates(out, mode, result.Predicates)
+	}
+
+	if mode == DisplayModeTemplate {
+		if len(result.ColumnNames) > 0 {
+			if err := renderOutputTemplate(out, "CLI_TEMPLATE_FOOTER", sysVars.TemplateFooter, newTemplateResult(result)); err != nil {
+				return err
 			}
 		}
-		format := fmt.Sprintf("%%%ds: %%s\n", max) // for align right
-		for i, row := range result.Rows {
-			fmt.Fprintf(out, "*************************** %d. row ***************************\n", i+1)
-			for j, column := range row.Columns {
-				fmt.Fprintf(out, format, result.ColumnNames[j], column)
-			}
+		if interactive {
+			fmt.Fprint(out, resultLine(result, verbose))
 		}
-	} else if mode == DisplayModeTab {
-		if len(result.ColumnNames) > 0 {
-			fmt.Fprintln(out, strings.Join(result.ColumnNames, "\t"))
-			for _, row := range result.Rows {
-				fmt.Fprintln(out, strings.Join(row.Columns, "\t"))
+		return nil
+	}
+
+	if mode == DisplayModeJSON || mode == DisplayModeJSONL {
+		// The result line is replaced with a metadata object to keep the output parsable.
+		if verbose || result.ForceVerbose {
+			b, err := json.Marshal(map[string]resultMetadata{"metadata": newResultMetadata(result)})
+			if err != nil {
+				return err
 			}
+			fmt.Fprintf(out, "%s\n", b)
+		} else if interactive {
+			fmt.Fprint(out, resultLine(result, false))
 		}
+		return nil
 	}
 
-	if len(result.Predicates) > 0 {
-		fmt.Fprintln(out, "Predicates(identified by ID):")
-		for _, s := range result.Predicates {
-			fmt.Fprintf(out, " %s\n", s)
-		}
-		fmt.Fprintln(out)
+	// CSV output is kept parsable without the summary.
+	if result.Summary != "" && mode != DisplayModeCSV {
+		fmt.Fprintln(out, result.Summary)
 	}
 
 	if verbose || result.ForceVerbose {
@@ -428,6 +609,63 @@ func printResult(out io.Writer, result *Result, mode DisplayMode, interactive, v
 	} else if interactive {
 		fmt.Fprint(out, resultLine(result, verbose))
 	}
+	return nil
+}
+
+// printPredicates prints predicates of EXPLAIN and EXPLAIN ANALYZE keeping their alignment.
+func printPredicates(out io.Writer, mode DisplayMode, predicates []string) {
+	switch mode {
+	case DisplayModeMarkdown:
+		fmt.Fprint(out, "Predicates(identified by ID):\n\n```\n")
+		for _, s := range predicates {
+			fmt.Fprintf(out, " %s\n", s)
+		}
+		fmt.Fprint(out, "```\n\n")
+	case DisplayModeHTML:
+		fmt.Fprint(out, "<p>Predicates(identified by ID):</p>\n<pre>\n")
+		for _, s := range predicates {
+			fmt.Fprintf(out, " %s\n", html.EscapeString(s))
+		}
+		fmt.Fprint(out, "</pre>\n")
+	default:
+		fmt.Fprintln(out, "Predicates(identified by ID):")
+		for _, s := range predicates {
+			fmt.Fprintf(out, " %s\n", s)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// resultMetadata is the stats and the timestamp of a result printed as a JSON object.
+type resultMetadata struct {
+	AffectedRows               int    `json:"affected_rows"`
+	ElapsedTime                string `json:"elapsed_time,omitempty"`
+	CPUTime                    string `json:"cpu_time,omitempty"`
+	RowsScanned                string `json:"rows_scanned,omitempty"`
+	DeletedRowsScanned         string `json:"deleted_rows_scanned,omitempty"`
+	OptimizerVersion           string `json:"optimizer_version,omitempty"`
+	OptimizerStatisticsPackage string `json:"optimizer_statistics_package,omitempty"`
+	Timestamp                  string `json:"timestamp,omitempty"`
+	MutationCount              int64  `json:"mutation_count,omitempty"`
+	Summary                    string `json:"summary,omitempty"`
+}
+
+func newResultMetadata(result *Result) resultMetadata {
+	metadata := resultMetadata{
+		AffectedRows:               result.AffectedRows,
+		ElapsedTime:                result.Stats.ElapsedTime,
+		CPUTime:                    result.Stats.CPUTime,
+		RowsScanned:                result.Stats.RowsScanned,
+		DeletedRowsScanned:         result.Stats.DeletedRowsScanned,
+		OptimizerVersion:           result.Stats.OptimizerVersion,
+		OptimizerStatisticsPackage: result.Stats.OptimizerStatisticsPackage,
+		MutationCount:              result.CommitStats.GetMutationCount(),
+		Summary:                    result.Summary,
+	}
+	if !result.Timestamp.IsZero() {
+		metadata.Timestamp = result.Timestamp.Format(time.RFC3339Nano)
+	}
+	return metadata
 }
 
 func resultLine(result *Result, verbose bool) string {
@@ -503,7 +741,8 @@ func buildCommands(input string) ([]*command, error) {
 		if err != nil {
 			return nil, err
 		}
-		if ddl, ok := stmt.(*DdlStatement); ok {
+		// Async DDL is not grouped, so that the operation ID is returned for each statement.
+		if ddl, ok := stmt.(*DdlStatement); ok && !ddl.Async {
 			pendingDdls = append(pendingDdls, ddl.Ddl)
 			continue
 		}
@@ -28,6 +28,7 @@ import (
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/chzyer/readline"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
 )
 
 type nopCloser struct {
@@ -80,6 +81,33 @@ func TestBuildCommands(t *testing.T) {
 				{&DmlStatement{"DELETE t1 WHERE TRUE /* AND pk = 1 */"}, false},
 				{&SelectStatement{"SELECT 0x1/**/A"}, false},
 			}},
+		{
+			// Async DDL is not grouped with other DDLs.
+			Input: "CREATE TABLE t1(pk INT64) PRIMARY KEY(pk);\nCREATE INDEX i1 ON t1(col) --async\n;\nDROP TABLE t2;",
+			Expected: []*command{
+				{&BulkDdlStatement{[]string{"CREATE TABLE t1(pk INT64) PRIMARY KEY(pk)"}}, false},
+				{&DdlStatement{Ddl: "CREATE INDEX i1 ON t1(col)", Async: true}, false},
+				{&BulkDdlStatement{[]string{"DROP TABLE t2"}}, false},
+			}},
+		{
+			// The --async comment on the same line as the delimiter belongs to the statement before the delimiter.
+			Input: "CREATE INDEX i1 ON t1(col); --async\nCREATE INDEX i2 ON t1(col);\n--async\nDROP TABLE t2;",
+			Expected: []*command{
+				{&DdlStatement{Ddl: "CREATE INDEX i1 ON t1(col)", Async: true}, false},
+				{&BulkDdlStatement{[]string{"CREATE INDEX i2 ON t1(col)", "DROP TABLE t2"}}, false},
+			}},
+		{
+			// The delimiter in the --async comment doesn't terminate the statement.
+			Input:       "CREATE INDEX i1 ON t1(col) --async;\nDROP TABLE t2;",
+			ExpectError: true,
+		},
+		{
+			// --async; in string literals is not a comment.
+			Input: "SELECT '''a --async;\n''';\nALTER TABLE t1 ALTER COLUMN col SET DEFAULT (\"\"\"--async;\n\"\"\");",
+			Expected: []*command{
+				{&SelectStatement{"SELECT '''a --async;\n'''"}, false},
+				{&BulkDdlStatement{[]string{"ALTER TABLE t1 ALTER COLUMN col SET DEFAULT (\"\"\"--async;\n\"\"\")"}}, false},
+			}},
 		{
 			// spanner-cli don't permit empty statements.
 			Input:       `SELECT 1; /* comment */; SELECT 2`,
@@ -155,6 +183,15 @@ func TestReadInteractiveInput(t *testing.T) {
 				delim:                    delimiterHorizontal,
 			},
 		},
+		{
+			desc:  "async comment after delimiter",
+			input: "CREATE INDEX i1 ON t1(col); -- async\n",
+			want: &inputStatement{
+				statement:                "CREATE INDEX i1 ON t1(col) --async",
+				statementWithoutComments: "CREATE INDEX i1 ON t1(col)",
+				delim:                    delimiterHorizontal,
+			},
+		},
 		{
 			desc:      "multiple statements",
 			input:     "SELECT 1; SELECT 2;",
@@ -183,18 +220,86 @@ func TestReadInteractiveInput(t *testing.T) {
 	}
 }
 
+func TestRunBatchDisplayMode(t *testing.T) {
+	for _, tt := range []struct {
+		desc         string
+		format       DisplayMode
+		displayTable bool
+		want         string
+	}{
+		{
+			desc:   "vertical delimiter",
+			format: DisplayModeTab,
+			want:   "*************************** 1. row ***************************\nx: 1\n",
+		},
+		{
+			desc:         "table takes precedence over vertical delimiter",
+			format:       DisplayModeTable,
+			displayTable: true,
+			want:         "+---+\n| x |\n+---+\n| 1 |\n+---+\n",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			sysVars := &systemVariables{CLIFormat: tt.format}
+			session := newLoadTestSession(t)
+			session.systemVariables = sysVars
+			cli := &Cli{Session: session, OutStream: out, ErrStream: ioutil.Discard, SystemVariables: sysVars}
+			if code := cli.RunBatch(`SELECT 1 AS x\G`, tt.displayTable); code != exitCodeSuccess {
+				t.Fatalf("RunBatch() = %d, but want = %d", code, exitCodeSuccess)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("invalid print: expected = %q, but got = %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStatementTag(t *testing.T) {
+	var recorder requestRecorder
+	unaryInterceptor, streamInterceptor := recordRequestsInterceptors(&recorder)
+	session := newLoadTestSessionWithDialOptions(t, []grpc.DialOption{
+		grpc.WithUnaryInterceptor(unaryInterceptor),
+		grpc.WithStreamInterceptor(streamInterceptor),
+	})
+	sysVars := &systemVariables{CLIFormat: DisplayModeTab}
+	session.systemVariables = sysVars
+	cli := &Cli{Session: session, OutStream: ioutil.Discard, ErrStream: ioutil.Discard, SystemVariables: sysVars}
+
+	recorder.flush()
+	input := "SET STATEMENT_TAG = 'app=foo'; SHOW VARIABLE STATEMENT_TAG; SELECT 1; SELECT 2;"
+	if code := cli.RunBatch(input, false); code != exitCodeSuccess {
+		t.Fatalf("RunBatch() = %d, but want = %d", code, exitCodeSuccess)
+	}
+
+	var got []string
+	for _, r := range recorder.requests {
+		if req, ok := r.(*sppb.ExecuteSqlRequest); ok {
+			got = append(got, req.GetRequestOptions().GetRequestTag())
+		}
+	}
+	// The tag is applied only to the first query.
+	want := []string{"app=foo", ""}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("request tags mismatch (-want +got):\n%s", diff)
+	}
+	if sysVars.StatementTag != "" {
+		t.Errorf("STATEMENT_TAG = %q, but want to be cleared", sysVars.StatementTag)
+	}
+}
+
 func TestPrintResult(t *testing.T) {
 	t.Run("DisplayModeTable", func(t *testing.T) {
 		out := &bytes.Buffer{}
 		result := &Result{
 			ColumnNames: []string{"foo", "bar"},
 			Rows: []Row{
-				Row{[]string{"1", "2"}},
-				Row{[]string{"3", "4"}},
+				stringRow("1", "2"),
+				stringRow("3", "4"),
 			},
 			IsMutation: false,
 		}
-		printResult(out, result, DisplayModeTable, false, false)
+		printResult(out, result, DisplayModeTable, false, &systemVariables{})
 
 		expected := strings.TrimPrefix(`
 +-----+-----+
@@ -216,12 +321,12 @@ func TestPrintResult(t *testing.T) {
 		result := &Result{
 			ColumnNames: []string{"foo", "bar"},
 			Rows: []Row{
-				Row{[]string{"1", "2"}},
-				Row{[]string{"3", "4"}},
+				stringRow("1", "2"),
+				stringRow("3", "4"),
 			},
 			IsMutation: false,
 		}
-		printResult(out, result, DisplayModeVertical, false, false)
+		printResult(out, result, DisplayModeVertical, false, &systemVariables{})
 
 		expected := strings.TrimPrefix(`
 *************************** 1. row ***************************
@@ -243,12 +348,12 @@ bar: 4
 		result := &Result{
 			ColumnNames: []string{"foo", "bar"},
 			Rows: []Row{
-				Row{[]string{"1", "2"}},
-				Row{[]string{"3", "4"}},
+				stringRow("1", "2"),
+				stringRow("3", "4"),
 			},
 			IsMutation: false,
 		}
-		printResult(out, result, DisplayModeTab, false, false)
+		printResult(out, result, DisplayModeTab, false, &systemVariables{})
 
 		expected := "foo\tbar\n" +
 			"1\t2\n" +
@@ -259,6 +364,26 @@ bar: 4
 			t.Errorf("invalid print: expected = %s, but got = %s", expected, got)
 		}
 	})
+
+	t.Run("Summary", func(t *testing.T) {
+		result := &Result{
+			ColumnNames: []string{"foo"},
+			Rows:        []Row{stringRow("1")},
+			Summary:     "1 missing rows",
+		}
+
+		out := &bytes.Buffer{}
+		printResult(out, result, DisplayModeTab, false, &systemVariables{})
+		if expected, got := "foo\n1\n1 missing rows\n", out.String(); got != expected {
+			t.Errorf("invalid print: expected = %s, but got = %s", expected, got)
+		}
+
+		out = &bytes.Buffer{}
+		printResult(out, result, DisplayModeCSV, false, &systemVariables{})
+		if got := out.String(); strings.Contains(got, result.Summary) {
+			t.Errorf("CSV should not have the summary, but got = %s", got)
+		}
+	})
 }
 
 func TestResultLine(t *testing.T) {
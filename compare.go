@@ -0,0 +1,203 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// CompareTableStatement prints rows which differ between the table of the current database and a table of another database.
+// Rows are compared by the primary key in the same way as DIFF TABLE, where the current database is the left side.
+type CompareTableStatement struct {
+	Schema       string
+	Table        string
+	Database     string
+	TargetSchema string
+	TargetTable  string
+}
+
+func (s *CompareTableStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	collector := &rowCollector{}
+	result, err := s.ExecuteStream(ctx, session, collector)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = collector.rows
+	return result, nil
+}
+
+// ExecuteStream writes differences to w as they are found.
+// If ctx is canceled, e.g. by Ctrl-C, it stops comparing rows and returns the result of the rows written so far.
+func (s *CompareTableStatement) ExecuteStream(ctx context.Context, session *Session, w resultWriter) (*Result, error) {
+	client, err := session.NewDatabaseClient(ctx, s.Database)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// Each side reads the schema and the rows at the same timestamp.
+	localTxn := session.client.ReadOnlyTransaction()
+	defer localTxn.Close()
+	targetTxn := client.ReadOnlyTransaction()
+	defer targetTxn.Close()
+
+	local, err := loadDiffTable(ctx, localTxn, s.Schema, s.Table)
+	if err != nil {
+		return nil, err
+	}
+	target, err := loadDiffTable(ctx, targetTxn, s.TargetSchema, s.TargetTable)
+	if err != nil {
+		return nil, fmt.Errorf("database %q: %w", s.Database, err)
+	}
+	local, target, warnings, err := compareTables(local, target, s.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := diffQueryOptions(session)
+	differ := newRowDiffer(local,
+		localTxn.QueryWithOptions(ctx, spanner.Statement{SQL: local.scanQuery("")}, opts),
+		targetTxn.QueryWithOptions(ctx, spanner.Statement{SQL: target.scanQuery("")}, opts))
+	defer differ.stop()
+
+	result, err := compareRows(ctx, differ, w, localTxn, targetTxn, s.Database)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(warnings, result.Warnings...)
+	return result, nil
+}
+
+// CompareQueryStatement prints rows of a query which differ between the current database and another database.
+// Rows are compared by the key columns, and the query must return rows in the order of the key.
+type CompareQueryStatement struct {
+	Query    string
+	Database string
+	Keys     []string
+}
+
+func (s *CompareQueryStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	collector := &rowCollector{}
+	result, err := s.ExecuteStream(ctx, session, collector)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = collector.rows
+	return result, nil
+}
+
+// ExecuteStream writes differences to w as they are found.
+// If ctx is canceled, e.g. by Ctrl-C, it stops comparing rows and returns the result of the rows written so far.
+func (s *CompareQueryStatement) ExecuteStream(ctx context.Context, session *Session, w resultWriter) (*Result, error) {
+	client, err := session.NewDatabaseClient(ctx, s.Database)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	localTxn := session.client.Single()
+	defer localTxn.Close()
+	targetTxn := client.Single()
+	defer targetTxn.Close()
+
+	stmt := spanner.Statement{SQL: s.Query}
+	opts := diffQueryOptions(session)
+	differ := newRowDiffer(&diffTable{keyNames: s.Keys}, localTxn.QueryWithOptions(ctx, stmt, opts), targetTxn.QueryWithOptions(ctx, stmt, opts))
+	defer differ.stop()
+
+	return compareRows(ctx, differ, w, localTxn, targetTxn, s.Database)
+}
+
+// compareRows writes differences found by the differ, and returns the result with the summary of the comparison.
+// The sides are read at different timestamps, which are printed in the summary.
+func compareRows(ctx context.Context, differ *rowDiffer, w resultWriter, localTxn, targetTxn *spanner.ReadOnlyTransaction, database string) (*Result, error) {
+	count, interrupted, err := writeRowDiffs(ctx, differ, w)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := differ.fields()
+	result := &Result{
+		ColumnNames:  extractColumnNames(fields),
+		ColumnTypes:  fields,
+		AffectedRows: count,
+		Summary: fmt.Sprintf("%d missing rows, %d extra rows, %d changed rows, %d same rows",
+			differ.onlyLeft, differ.onlyRight, differ.changed, differ.same),
+	}
+	if interrupted {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("comparison was interrupted after %d rows", count))
+	}
+
+	// ReadOnlyTransaction.Timestamp() is invalid until read.
+	localTimestamp, localErr := localTxn.Timestamp()
+	targetTimestamp, targetErr := targetTxn.Timestamp()
+	if localErr == nil && targetErr == nil {
+		result.Timestamp = localTimestamp
+		result.Summary += fmt.Sprintf("\nread at %s in this database and %s in database %q",
+			localTimestamp.Format(time.RFC3339Nano), targetTimestamp.Format(time.RFC3339Nano), database)
+	}
+	return result, nil
+}
+
+// compareTables returns the tables which read the columns existing in both tables in the same order,
+// and the warnings of the columns existing only in one of them. Both tables must have the same primary key.
+func compareTables(local, target *diffTable, database string) (*diffTable, *diffTable, []string, error) {
+	if len(local.Keys) != len(target.Keys) {
+		return nil, nil, nil, fmt.Errorf("primary key of table %s doesn't match the table in database %q", local, database)
+	}
+	for i, key := range local.Keys {
+		other := target.Keys[i]
+		if !strings.EqualFold(local.Columns[key.Column].Name, target.Columns[other.Column].Name) || key.Desc != other.Desc {
+			return nil, nil, nil, fmt.Errorf("primary key of table %s doesn't match the table in database %q", local, database)
+		}
+	}
+
+	targetColumns := make(map[string]bool, len(target.Columns))
+	for _, column := range target.Columns {
+		targetColumns[strings.ToLower(column.Name)] = true
+	}
+
+	var warnings []string
+	compared := &diffTable{dumpTableName: local.dumpTableName}
+	indexes := make(map[int]int, len(local.Columns)) // index of local.Columns to index of compared.Columns
+	for i, column := range local.Columns {
+		name := strings.ToLower(column.Name)
+		if !targetColumns[name] {
+			warnings = append(warnings, fmt.Sprintf("column %q is not compared because it doesn't exist in database %q", column.Name, database))
+			continue
+		}
+		delete(targetColumns, name)
+		indexes[i] = len(compared.Columns)
+		compared.Columns = append(compared.Columns, column)
+	}
+	for _, column := range target.Columns {
+		if targetColumns[strings.ToLower(column.Name)] {
+			warnings = append(warnings, fmt.Sprintf("column %q of database %q is not compared because it doesn't exist in this database", column.Name, database))
+		}
+	}
+	for _, key := range local.Keys {
+		compared.Keys = append(compared.Keys, diffKey{Column: indexes[key.Column], Desc: key.Desc})
+	}
+
+	// Column names are case-insensitive, so the target is read with the same names.
+	return compared, &diffTable{dumpTableName: target.dumpTableName, Columns: compared.Columns, Keys: compared.Keys}, warnings, nil
+}
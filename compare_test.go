@@ -0,0 +1,260 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareTables(t *testing.T) {
+	for _, tt := range []struct {
+		desc         string
+		local        *diffTable
+		target       *diffTable
+		wantColumns  []diffColumn
+		wantKeys     []diffKey
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{
+			desc: "columns in different order",
+			local: &diffTable{
+				Columns: []diffColumn{{Name: "Id"}, {Name: "Name"}, {Name: "Age"}},
+				Keys:    []diffKey{{Column: 0}},
+			},
+			target: &diffTable{
+				Columns: []diffColumn{{Name: "age"}, {Name: "ID"}, {Name: "Name"}},
+				Keys:    []diffKey{{Column: 1}},
+			},
+			wantColumns: []diffColumn{{Name: "Id"}, {Name: "Name"}, {Name: "Age"}},
+			wantKeys:    []diffKey{{Column: 0}},
+		},
+		{
+			desc: "columns which exist only in one of the tables",
+			local: &diffTable{
+				Columns: []diffColumn{{Name: "Removed"}, {Name: "Id"}, {Name: "Name"}},
+				Keys:    []diffKey{{Column: 1, Desc: true}},
+			},
+			target: &diffTable{
+				Columns: []diffColumn{{Name: "Id"}, {Name: "Name"}, {Name: "Added"}},
+				Keys:    []diffKey{{Column: 0, Desc: true}},
+			},
+			wantColumns: []diffColumn{{Name: "Id"}, {Name: "Name"}},
+			wantKeys:    []diffKey{{Column: 0, Desc: true}},
+			wantWarnings: []string{
+				`column "Removed" is not compared because it doesn't exist in database "staging"`,
+				`column "Added" of database "staging" is not compared because it doesn't exist in this database`,
+			},
+		},
+		{
+			desc: "different key order",
+			local: &diffTable{
+				Columns: []diffColumn{{Name: "Id"}},
+				Keys:    []diffKey{{Column: 0}},
+			},
+			target: &diffTable{
+				Columns: []diffColumn{{Name: "Id"}},
+				Keys:    []diffKey{{Column: 0, Desc: true}},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "different key columns",
+			local: &diffTable{
+				Columns: []diffColumn{{Name: "Id"}, {Name: "Name"}},
+				Keys:    []diffKey{{Column: 0}},
+			},
+			target: &diffTable{
+				Columns: []diffColumn{{Name: "Id"}, {Name: "Name"}},
+				Keys:    []diffKey{{Column: 0}, {Column: 1}},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			local, target, warnings, err := compareTables(tt.local, tt.target, "staging")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("compareTables() should fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compareTables() got error: %v", err)
+			}
+			for _, table := range []*diffTable{local, target} {
+				if diff := cmp.Diff(tt.wantColumns, table.Columns); diff != "" {
+					t.Errorf("columns mismatch (-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(tt.wantKeys, table.Keys); diff != "" {
+					t.Errorf("keys mismatch (-want +got):\n%s", diff)
+				}
+			}
+			if diff := cmp.Diff(tt.wantWarnings, warnings); diff != "" {
+				t.Errorf("warnings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompareTableRows(t *testing.T) {
+	ctx := context.Background()
+	session := newLoadTestSession(t,
+		"CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX), Age INT64) PRIMARY KEY (Id)",
+		"CREATE TABLE SingersCopy (Age INT64, Extra STRING(MAX), Name STRING(MAX), Id INT64 NOT NULL) PRIMARY KEY (Id)",
+	)
+	_, err := session.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert("Singers", []string{"Id", "Name", "Age"}, []interface{}{1, "missing", 10}),
+		spanner.Insert("Singers", []string{"Id", "Name", "Age"}, []interface{}{2, "same", 20}),
+		spanner.Insert("Singers", []string{"Id", "Name", "Age"}, []interface{}{3, "changed", 30}),
+		spanner.Insert("SingersCopy", []string{"Id", "Name", "Age", "Extra"}, []interface{}{2, "same", 20, "ignored"}),
+		spanner.Insert("SingersCopy", []string{"Id", "Name", "Age", "Extra"}, []interface{}{3, "changed", 31, nil}),
+		spanner.Insert("SingersCopy", []string{"Id", "Name", "Age", "Extra"}, []interface{}{4, "extra", 40, nil}),
+	})
+	if err != nil {
+		t.Fatalf("failed to insert rows: %v", err)
+	}
+
+	local, target, _, err := compareTables(
+		&diffTable{
+			dumpTableName: dumpTableName{Name: "Singers"},
+			Columns:       []diffColumn{{Name: "Id"}, {Name: "Name"}, {Name: "Age"}},
+			Keys:          []diffKey{{Column: 0}},
+		},
+		&diffTable{
+			dumpTableName: dumpTableName{Name: "SingersCopy"},
+			Columns:       []diffColumn{{Name: "Age"}, {Name: "Extra"}, {Name: "Name"}, {Name: "Id"}},
+			Keys:          []diffKey{{Column: 3}},
+		},
+		"staging",
+	)
+	if err != nil {
+		t.Fatalf("compareTables() got error: %v", err)
+	}
+	differ := newRowDiffer(local,
+		session.client.Single().Query(ctx, spanner.Statement{SQL: local.scanQuery("")}),
+		session.client.Single().Query(ctx, spanner.Statement{SQL: target.scanQuery("")}))
+	defer differ.stop()
+
+	w := &rowCollector{}
+	if _, _, err := writeRowDiffs(ctx, differ, w); err != nil {
+		t.Fatalf("writeRowDiffs() got error: %v", err)
+	}
+	want := [][]string{
+		{"-", "NULL", "1", "missing", "10"},
+		{"-", "Age", "3", "changed", "30"},
+		{"+", "Age", "3", "changed", "31"},
+		{"+", "NULL", "4", "extra", "40"},
+	}
+	if diff := cmp.Diff(want, decodeRows(t, w.rows)); diff != "" {
+		t.Errorf("differences mismatch (-want +got):\n%s", diff)
+	}
+	if differ.onlyLeft != 1 || differ.onlyRight != 1 || differ.changed != 1 || differ.same != 1 {
+		t.Errorf("counts mismatch: got = %+v", differ)
+	}
+}
+
+func TestCompareQueryRows(t *testing.T) {
+	ctx := context.Background()
+	session := newLoadTestSession(t,
+		"CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX), Age INT64) PRIMARY KEY (Id)",
+	)
+	_, err := session.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert("Singers", []string{"Id", "Name", "Age"}, []interface{}{1, "foo", 10}),
+		spanner.Insert("Singers", []string{"Id", "Name", "Age"}, []interface{}{2, "bar", 20}),
+		spanner.Insert("Singers", []string{"Id", "Name", "Age"}, []interface{}{3, "bar", 30}),
+	})
+	if err != nil {
+		t.Fatalf("failed to insert rows: %v", err)
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		keys    []string
+		left    string
+		right   string
+		want    [][]string
+		wantErr string
+	}{
+		{
+			desc:  "key of the query",
+			keys:  []string{"name", "ID"},
+			left:  "SELECT Name, Id, Age FROM Singers WHERE Id <= 2 ORDER BY Name, Id",
+			right: "SELECT Name, Id, Age + 1 AS Age FROM Singers WHERE Id >= 2 ORDER BY Name, Id",
+			want: [][]string{
+				{"-", "Age", "bar", "2", "20"},
+				{"+", "Age", "bar", "2", "21"},
+				{"+", "NULL", "bar", "3", "31"},
+				{"-", "NULL", "foo", "1", "10"},
+			},
+		},
+		{
+			desc:    "key which doesn't exist",
+			keys:    []string{"SingerId"},
+			left:    "SELECT Id, Name FROM Singers ORDER BY Id",
+			right:   "SELECT Id, Name FROM Singers ORDER BY Id",
+			wantErr: `key column "SingerId" doesn't exist in the result of the query`,
+		},
+		{
+			desc:    "different columns",
+			keys:    []string{"Id"},
+			left:    "SELECT Id, Name FROM Singers ORDER BY Id",
+			right:   "SELECT Id, Age FROM Singers ORDER BY Id",
+			wantErr: "columns of the query differ: (Id, Name) and (Id, Age)",
+		},
+		{
+			desc:    "rows which are not ordered by the key",
+			keys:    []string{"Id"},
+			left:    "SELECT Id, Name FROM Singers ORDER BY Id DESC",
+			right:   "SELECT Id, Name FROM Singers ORDER BY Id DESC",
+			wantErr: "rows are not read in the order of the key",
+		},
+		{
+			desc:    "duplicate keys",
+			keys:    []string{"Name"},
+			left:    "SELECT Name FROM Singers ORDER BY Name",
+			right:   "SELECT Name FROM Singers ORDER BY Name",
+			wantErr: "rows have the same key",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			differ := newRowDiffer(&diffTable{keyNames: tt.keys},
+				session.client.Single().Query(ctx, spanner.Statement{SQL: tt.left}),
+				session.client.Single().Query(ctx, spanner.Statement{SQL: tt.right}))
+			defer differ.stop()
+
+			w := &rowCollector{}
+			_, _, err := writeRowDiffs(ctx, differ, w)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("writeRowDiffs() should fail with %q, but got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("writeRowDiffs() got error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, decodeRows(t, w.rows)); diff != "" {
+				t.Errorf("differences mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -17,15 +17,62 @@
 package main
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"cloud.google.com/go/spanner"
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
+// valueFormatter renders a typed value as a string for output.
+type valueFormatter func(value spanner.GenericColumnValue) (string, error)
+
+// formatRow renders all values of the row by the formatter.
+func formatRow(row Row, format valueFormatter) ([]string, error) {
+	columns := make([]string, len(row.Values))
+	for i, value := range row.Values {
+		column, err := format(value)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+// rowValues returns the typed values of all columns of the row.
+func rowValues(row *spanner.Row) ([]spanner.GenericColumnValue, error) {
+	values := make([]spanner.GenericColumnValue, row.Size())
+	for i := range values {
+		if err := row.Column(i, &values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// isNullValue returns true if the value is NULL. Note that an ARRAY containing NULL is not NULL.
+func isNullValue(value spanner.GenericColumnValue) bool {
+	_, ok := value.Value.GetKind().(*structpb.Value_NullValue)
+	return ok
+}
+
+func stringValue(s string) spanner.GenericColumnValue {
+	return spanner.GenericColumnValue{
+		Type:  &sppb.Type{Code: sppb.TypeCode_STRING},
+		Value: structpb.NewStringValue(s),
+	}
+}
+
 func DecodeRow(row *spanner.Row) ([]string, error) {
 	columns := make([]string, row.Size())
 	for i := 0; i < row.Size(); i++ {
@@ -237,6 +284,25 @@ func DecodeColumn(column spanner.GenericColumnValue) (string, error) {
 			return "", err
 		}
 		return nullJSONToString(v), nil
+	case sppb.TypeCode_STRUCT:
+		// STRUCT can't be a column of query results, but it can be a query parameter.
+		// google-cloud-go/spanner only decodes STRUCT in an ARRAY, so we wrap it.
+		wrapped := spanner.GenericColumnValue{
+			Type:  &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: column.Type},
+			Value: structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{column.Value}}),
+		}
+		var vs []spanner.NullRow
+		if err := wrapped.Decode(&vs); err != nil {
+			return "", err
+		}
+		if !vs[0].Valid {
+			return "NULL", nil
+		}
+		columns, err := DecodeRow(&vs[0].Row)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("[%s]", strings.Join(columns, ", ")), nil
 	default:
 		return fmt.Sprintf("%s", column.Value), nil
 	}
@@ -361,3 +427,327 @@ func formatTypeVerbose(typ *sppb.Type) string {
 		}
 	}
 }
+
+// encodeJSONValue encodes the value as a native JSON value.
+// INT64 and ENUM are encoded as strings if int64AsString is true to keep their precision in JavaScript,
+// and NUMERIC is always encoded as a string for the same reason.
+func encodeJSONValue(typ *sppb.Type, value *structpb.Value, int64AsString bool) ([]byte, error) {
+	if _, ok := value.GetKind().(*structpb.Value_NullValue); ok {
+		return []byte("null"), nil
+	}
+
+	switch typ.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return json.Marshal(value.GetBoolValue())
+	case sppb.TypeCode_INT64, sppb.TypeCode_ENUM:
+		if int64AsString {
+			return json.Marshal(value.GetStringValue())
+		}
+		return []byte(value.GetStringValue()), nil
+	case sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64:
+		// NaN and Infinity are encoded as strings because JSON doesn't have them.
+		if _, ok := value.GetKind().(*structpb.Value_StringValue); ok {
+			return json.Marshal(value.GetStringValue())
+		}
+		switch f := value.GetNumberValue(); {
+		case math.IsNaN(f):
+			return json.Marshal("NaN")
+		case math.IsInf(f, 1):
+			return json.Marshal("Infinity")
+		case math.IsInf(f, -1):
+			return json.Marshal("-Infinity")
+		}
+		if typ.GetCode() == sppb.TypeCode_FLOAT32 {
+			return json.Marshal(float32(value.GetNumberValue()))
+		}
+		return json.Marshal(value.GetNumberValue())
+	case sppb.TypeCode_JSON:
+		if s := value.GetStringValue(); json.Valid([]byte(s)) {
+			return []byte(s), nil
+		}
+		return json.Marshal(value.GetStringValue())
+	case sppb.TypeCode_ARRAY:
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		for i, elem := range value.GetListValue().GetValues() {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			b, err := encodeJSONValue(typ.GetArrayElementType(), elem, int64AsString)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+		}
+		buf.WriteString("]")
+		return buf.Bytes(), nil
+	case sppb.TypeCode_STRUCT:
+		fields := typ.GetStructType().GetFields()
+		values := value.GetListValue().GetValues()
+		if len(fields) != len(values) {
+			return nil, fmt.Errorf("STRUCT has %d fields, but got %d values", len(fields), len(values))
+		}
+		names := make([]string, len(fields))
+		types := make([]*sppb.Type, len(fields))
+		for i, field := range fields {
+			names[i] = field.GetName()
+			types[i] = field.GetType()
+		}
+		return encodeJSONObject(names, types, values, int64AsString)
+	default:
+		// STRING, BYTES, PROTO, NUMERIC, DATE and TIMESTAMP are encoded as strings in the same form as Cloud Spanner API.
+		// BYTES and PROTO are base64-encoded.
+		return json.Marshal(value.GetStringValue())
+	}
+}
+
+// encodeJSONObject encodes the values as a JSON object keeping the order of names.
+// Unnamed values are keyed by their 1-based positions.
+func encodeJSONObject(names []string, types []*sppb.Type, values []*structpb.Value, int64AsString bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name := names[i]
+		if name == "" {
+			name = fmt.Sprint(i + 1)
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		b, err := encodeJSONValue(types[i], value, int64AsString)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(b)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// formatSQLLiteral renders the value as a GoogleSQL literal, so that it can be pasted into queries.
+func formatSQLLiteral(column spanner.GenericColumnValue) (string, error) {
+	return encodeSQLLiteral(column.Type, column.Value)
+}
+
+// encodeSQLLiteral encodes the value in the form of Cloud Spanner API as a GoogleSQL literal.
+func encodeSQLLiteral(typ *sppb.Type, value *structpb.Value) (string, error) {
+	if _, ok := value.GetKind().(*structpb.Value_NullValue); ok {
+		return "NULL", nil
+	}
+
+	switch typ.GetCode() {
+	case sppb.TypeCode_BOOL:
+		if value.GetBoolValue() {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case sppb.TypeCode_INT64:
+		return value.GetStringValue(), nil
+	case sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64:
+		s := encodeSQLFloat(value, typ.GetCode())
+		if typ.GetCode() == sppb.TypeCode_FLOAT32 {
+			return fmt.Sprintf("CAST(%s AS FLOAT32)", s), nil
+		}
+		return s, nil
+	case sppb.TypeCode_NUMERIC:
+		return "NUMERIC " + quoteSQLString(value.GetStringValue(), '"'), nil
+	case sppb.TypeCode_STRING:
+		return quoteSQLString(value.GetStringValue(), '"'), nil
+	case sppb.TypeCode_JSON:
+		return "JSON " + quoteSQLString(value.GetStringValue(), '\''), nil
+	case sppb.TypeCode_DATE:
+		return "DATE " + quoteSQLString(value.GetStringValue(), '"'), nil
+	case sppb.TypeCode_TIMESTAMP:
+		return "TIMESTAMP " + quoteSQLString(value.GetStringValue(), '"'), nil
+	case sppb.TypeCode_BYTES, sppb.TypeCode_PROTO:
+		b, err := base64.StdEncoding.DecodeString(value.GetStringValue())
+		if err != nil {
+			return "", err
+		}
+		if typ.GetCode() == sppb.TypeCode_PROTO {
+			return fmt.Sprintf("CAST(%s AS %s)", quoteSQLBytes(b), quoteSQLIdentifier(typ.GetProtoTypeFqn())), nil
+		}
+		return quoteSQLBytes(b), nil
+	case sppb.TypeCode_ENUM:
+		return fmt.Sprintf("CAST(%s AS %s)", value.GetStringValue(), quoteSQLIdentifier(typ.GetProtoTypeFqn())), nil
+	case sppb.TypeCode_ARRAY:
+		values := value.GetListValue().GetValues()
+		if len(values) == 0 {
+			// An empty array needs the type because it can't be inferred from elements.
+			return formatSQLType(typ) + "[]", nil
+		}
+		elems := make([]string, len(values))
+		for i, elem := range values {
+			s, err := encodeSQLLiteral(typ.GetArrayElementType(), elem)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = s
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case sppb.TypeCode_STRUCT:
+		fields := typ.GetStructType().GetFields()
+		values := value.GetListValue().GetValues()
+		if len(fields) != len(values) {
+			return "", fmt.Errorf("STRUCT has %d fields, but got %d values", len(fields), len(values))
+		}
+		elems := make([]string, len(fields))
+		for i, field := range fields {
+			s, err := encodeSQLLiteral(field.GetType(), values[i])
+			if err != nil {
+				return "", err
+			}
+			if field.GetName() != "" {
+				s += " AS " + quoteSQLIdentifier(field.GetName())
+			}
+			elems[i] = s
+		}
+		return "STRUCT(" + strings.Join(elems, ", ") + ")", nil
+	default:
+		return "", fmt.Errorf("unsupported type for SQL literal: %v", formatTypeSimple(typ))
+	}
+}
+
+// encodeSQLFloat encodes a float value with the shortest representation which keeps its precision.
+// NaN and infinities don't have literals, so they are cast from strings.
+func encodeSQLFloat(value *structpb.Value, code sppb.TypeCode) string {
+	f := value.GetNumberValue()
+	if s, ok := value.GetKind().(*structpb.Value_StringValue); ok {
+		// Cloud Spanner API encodes NaN and infinities as strings.
+		switch s.StringValue {
+		case "NaN":
+			f = math.NaN()
+		case "Infinity":
+			f = math.Inf(1)
+		case "-Infinity":
+			f = math.Inf(-1)
+		}
+	}
+	switch {
+	case math.IsNaN(f):
+		return `CAST("nan" AS FLOAT64)`
+	case math.IsInf(f, 1):
+		return `CAST("inf" AS FLOAT64)`
+	case math.IsInf(f, -1):
+		return `CAST("-inf" AS FLOAT64)`
+	}
+
+	bitSize := 64
+	if code == sppb.TypeCode_FLOAT32 {
+		bitSize = 32
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	// A number without a decimal point or an exponent is an INT64 literal.
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// quoteSQLString quotes the string as a GoogleSQL string literal.
+func quoteSQLString(s string, quote rune) string {
+	var sb strings.Builder
+	sb.WriteRune(quote)
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == quote:
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case !unicode.IsPrint(r) && r <= 0xFFFF:
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&sb, `\U%08x`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteRune(quote)
+	return sb.String()
+}
+
+// quoteSQLBytes quotes the bytes as a GoogleSQL bytes literal, escaping non-printable ASCII characters.
+func quoteSQLBytes(b []byte) string {
+	var sb strings.Builder
+	sb.WriteString(`b"`)
+	for _, c := range b {
+		switch {
+		case c == '\\' || c == '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteString(`"`)
+	return sb.String()
+}
+
+var sqlIdentifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqlReservedKeywords are the reserved keywords of GoogleSQL, which can't be used as identifiers without quotes.
+// https://cloud.google.com/spanner/docs/reference/standard-sql/lexical#reserved_keywords
+var sqlReservedKeywords = map[string]bool{
+	"ALL": true, "AND": true, "ANY": true, "ARRAY": true, "AS": true, "ASC": true, "ASSERT_ROWS_MODIFIED": true, "AT": true,
+	"BETWEEN": true, "BY": true, "CASE": true, "CAST": true, "COLLATE": true, "CONTAINS": true, "CREATE": true, "CROSS": true,
+	"CUBE": true, "CURRENT": true, "DEFAULT": true, "DEFINE": true, "DESC": true, "DISTINCT": true, "ELSE": true, "END": true,
+	"ENUM": true, "ESCAPE": true, "EXCEPT": true, "EXCLUDE": true, "EXISTS": true, "EXTRACT": true, "FALSE": true, "FETCH": true,
+	"FOLLOWING": true, "FOR": true, "FROM": true, "FULL": true, "GROUP": true, "GROUPING": true, "GROUPS": true, "HASH": true,
+	"HAVING": true, "IF": true, "IGNORE": true, "IN": true, "INNER": true, "INTERSECT": true, "INTERVAL": true, "INTO": true,
+	"IS": true, "JOIN": true, "LATERAL": true, "LEFT": true, "LIKE": true, "LIMIT": true, "LOOKUP": true, "MERGE": true,
+	"NATURAL": true, "NEW": true, "NO": true, "NOT": true, "NULL": true, "NULLS": true, "OF": true, "ON": true,
+	"OR": true, "ORDER": true, "OUTER": true, "OVER": true, "PARTITION": true, "PRECEDING": true, "PROTO": true, "RANGE": true,
+	"RECURSIVE": true, "RESPECT": true, "RIGHT": true, "ROLLUP": true, "ROWS": true, "SELECT": true, "SET": true, "SOME": true,
+	"STRUCT": true, "TABLESAMPLE": true, "THEN": true, "TO": true, "TREAT": true, "TRUE": true, "UNBOUNDED": true, "UNION": true,
+	"UNNEST": true, "USING": true, "WHEN": true, "WHERE": true, "WINDOW": true, "WITH": true, "WITHIN": true,
+}
+
+// quoteSQLIdentifier quotes the identifier by backticks unless it can be used as is, i.e. it isn't a reserved keyword.
+// A fully qualified name such as `examples.Book` is quoted as a whole.
+func quoteSQLIdentifier(name string) string {
+	if sqlIdentifierRe.MatchString(name) && !sqlReservedKeywords[strings.ToUpper(name)] {
+		return name
+	}
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
+
+// formatSQLType formats the type in GoogleSQL syntax such as ARRAY<STRUCT<name STRING>>.
+func formatSQLType(typ *sppb.Type) string {
+	switch typ.GetCode() {
+	case sppb.TypeCode_ARRAY:
+		return fmt.Sprintf("ARRAY<%s>", formatSQLType(typ.GetArrayElementType()))
+	case sppb.TypeCode_STRUCT:
+		var fields []string
+		for _, field := range typ.GetStructType().GetFields() {
+			if field.GetName() == "" {
+				fields = append(fields, formatSQLType(field.GetType()))
+				continue
+			}
+			fields = append(fields, quoteSQLIdentifier(field.GetName())+" "+formatSQLType(field.GetType()))
+		}
+		return fmt.Sprintf("STRUCT<%s>", strings.Join(fields, ", "))
+	case sppb.TypeCode_PROTO, sppb.TypeCode_ENUM:
+		return quoteSQLIdentifier(typ.GetProtoTypeFqn())
+	default:
+		return formatTypeSimple(typ)
+	}
+}
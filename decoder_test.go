@@ -17,6 +17,7 @@
 package main
 
 import (
+	"math"
 	"math/big"
 	"testing"
 	"time"
@@ -441,3 +442,121 @@ func TestDecodeRow(t *testing.T) {
 		})
 	}
 }
+
+func TestRowValues(t *testing.T) {
+	values, err := rowValues(createRow(t, []interface{}{int64(1), "NULL", spanner.NullString{}}))
+	if err != nil {
+		t.Fatalf("rowValues() got error: %v", err)
+	}
+
+	// The string "NULL" and NULL keep their difference in the typed values.
+	wantCodes := []sppb.TypeCode{sppb.TypeCode_INT64, sppb.TypeCode_STRING, sppb.TypeCode_STRING}
+	wantNulls := []bool{false, false, true}
+	for i, value := range values {
+		if got := value.Type.GetCode(); got != wantCodes[i] {
+			t.Errorf("values[%d] type = %v, but want = %v", i, got, wantCodes[i])
+		}
+		if _, got := value.Value.GetKind().(*structpb.Value_NullValue); got != wantNulls[i] {
+			t.Errorf("values[%d] is null = %v, but want = %v", i, got, wantNulls[i])
+		}
+	}
+
+	got, err := formatRow(Row{Values: values}, DecodeColumn)
+	if err != nil {
+		t.Fatalf("formatRow() got error: %v", err)
+	}
+	if want := []string{"1", "NULL", "NULL"}; !equalStringSlice(got, want) {
+		t.Errorf("formatRow() = %v, want = %v", got, want)
+	}
+}
+
+func TestEncodeJSONValue(t *testing.T) {
+	type item struct {
+		Name  string `spanner:"name"`
+		Count int64  `spanner:"count"`
+	}
+
+	tests := []struct {
+		desc          string
+		value         interface{}
+		int64AsString bool
+		want          string
+	}{
+		{desc: "bool", value: true, want: `true`},
+		{desc: "int64", value: int64(9007199254740993), want: `9007199254740993`},
+		{desc: "int64 as string", value: int64(9007199254740993), int64AsString: true, want: `"9007199254740993"`},
+		{desc: "float64", value: 1.5, want: `1.5`},
+		{desc: "float64 NaN", value: math.NaN(), want: `"NaN"`},
+		{desc: "float32", value: float32(0.1), want: `0.1`},
+		{desc: "numeric", value: big.NewRat(3, 2), want: `"1.500000000"`},
+		{desc: "string", value: `say "hi"`, want: `"say \"hi\""`},
+		{desc: "string NULL", value: "NULL", want: `"NULL"`},
+		{desc: "null", value: spanner.NullString{}, want: `null`},
+		{desc: "bytes", value: []byte{0x00, 0xff}, want: `"AP8="`},
+		{desc: "date", value: civil.Date{Year: 2024, Month: 1, Day: 2}, want: `"2024-01-02"`},
+		{desc: "json", value: spanner.NullJSON{Value: jsonMessage{Msg: "foo"}, Valid: true}, want: `{"msg":"foo"}`},
+		{desc: "array", value: []spanner.NullInt64{{Int64: 1, Valid: true}, {}}, want: `[1,null]`},
+		{desc: "array of struct", value: []item{{Name: "a", Count: 1}}, want: `[{"name":"a","count":1}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			value := createColumnValue(t, test.value)
+			got, err := encodeJSONValue(value.Type, value.Value, test.int64AsString)
+			if err != nil {
+				t.Fatalf("encodeJSONValue(%v) got error: %v", test.value, err)
+			}
+			if string(got) != test.want {
+				t.Errorf("encodeJSONValue(%v) = %s, want = %s", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatSQLLiteral(t *testing.T) {
+	type item struct {
+		Name  string `spanner:"name"`
+		Count int64  `spanner:"count"`
+	}
+	type keywordItem struct {
+		Order int64  `spanner:"order"`
+		Group string `spanner:"Group"`
+	}
+
+	tests := []struct {
+		desc  string
+		value interface{}
+		want  string
+	}{
+		{desc: "bool", value: true, want: `TRUE`},
+		{desc: "int64", value: int64(-1), want: `-1`},
+		{desc: "float64", value: 0.0000001, want: `1e-07`},
+		{desc: "float64 integral", value: float64(2), want: `2.0`},
+		{desc: "float64 NaN", value: math.NaN(), want: `CAST("nan" AS FLOAT64)`},
+		{desc: "float64 -Inf", value: math.Inf(-1), want: `CAST("-inf" AS FLOAT64)`},
+		{desc: "float32", value: float32(0.1), want: `CAST(0.1 AS FLOAT32)`},
+		{desc: "numeric", value: big.NewRat(3, 2), want: `NUMERIC "1.500000000"`},
+		{desc: "string", value: "say \"hi\"\n\\\x01", want: `"say \"hi\"\n\\\u0001"`},
+		{desc: "null", value: spanner.NullString{}, want: `NULL`},
+		{desc: "bytes", value: []byte("\x00a\"\xff"), want: `b"\x00a\"\xff"`},
+		{desc: "date", value: civil.Date{Year: 2024, Month: 1, Day: 2}, want: `DATE "2024-01-02"`},
+		{desc: "timestamp", value: time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC), want: `TIMESTAMP "2024-01-02T03:04:05.0000006Z"`},
+		{desc: "json", value: spanner.NullJSON{Value: map[string]string{"msg": "it's\n"}, Valid: true}, want: `JSON '{"msg":"it\'s\\n"}'`},
+		{desc: "array", value: []spanner.NullInt64{{Int64: 1, Valid: true}, {}}, want: `[1, NULL]`},
+		{desc: "empty array", value: []string{}, want: `ARRAY<STRING>[]`},
+		{desc: "array of struct", value: []item{{Name: "a", Count: 1}}, want: `[STRUCT("a" AS name, 1 AS count)]`},
+		{desc: "empty array of struct", value: []item{}, want: "ARRAY<STRUCT<name STRING, count INT64>>[]"},
+		{desc: "struct with reserved keyword", value: []keywordItem{{Order: 1, Group: "a"}}, want: "[STRUCT(1 AS `order`, \"a\" AS `Group`)]"},
+		{desc: "empty array of struct with reserved keyword", value: []keywordItem{}, want: "ARRAY<STRUCT<`order` INT64, `Group` STRING>>[]"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := formatSQLLiteral(createColumnValue(t, test.value))
+			if err != nil {
+				t.Fatalf("formatSQLLiteral(%v) got error: %v", test.value, err)
+			}
+			if got != test.want {
+				t.Errorf("formatSQLLiteral(%v) = %s, want = %s", test.value, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,598 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// DiffTableStatement prints rows of the table which differ between two read timestamps.
+// Rows are compared by the primary key while both timestamps are scanned in the key order,
+// so that the memory doesn't depend on the size of the table.
+type DiffTableStatement struct {
+	Schema string
+	Table  string
+	From   readOnlyStaleness
+	To     readOnlyStaleness
+}
+
+func (s *DiffTableStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	collector := &rowCollector{}
+	result, err := s.ExecuteStream(ctx, session, collector)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = collector.rows
+	return result, nil
+}
+
+// ExecuteStream writes differences to w as they are found.
+// If ctx is canceled, e.g. by Ctrl-C, it stops comparing rows and returns the result of the rows written so far.
+func (s *DiffTableStatement) ExecuteStream(ctx context.Context, session *Session, w resultWriter) (*Result, error) {
+	table, err := loadDiffTable(ctx, session.client.Single(), s.Schema, s.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	fromTxn := session.client.ReadOnlyTransaction().WithTimestampBound(s.From.timestampBound())
+	defer fromTxn.Close()
+	toTxn := session.client.ReadOnlyTransaction().WithTimestampBound(s.To.timestampBound())
+	defer toTxn.Close()
+
+	stmt := spanner.Statement{SQL: table.scanQuery("")}
+	opts := diffQueryOptions(session)
+	differ := newRowDiffer(table, fromTxn.QueryWithOptions(ctx, stmt, opts), toTxn.QueryWithOptions(ctx, stmt, opts))
+	defer differ.stop()
+
+	count, interrupted, err := writeRowDiffs(ctx, differ, w)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := differ.fields()
+	result := &Result{
+		ColumnNames:  extractColumnNames(fields),
+		ColumnTypes:  fields,
+		AffectedRows: count,
+	}
+	if interrupted {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("diff was interrupted after %d rows", count))
+	}
+	// The timestamp of the later read, which can be used to read the table of the result again.
+	result.Timestamp, _ = toTxn.Timestamp()
+	return result, nil
+}
+
+// writeRowDiffs writes differences found by the differ.
+// It returns the number of written rows, and whether ctx was canceled.
+func writeRowDiffs(ctx context.Context, differ *rowDiffer, w resultWriter) (int, bool, error) {
+	dw := &rowDiffWriter{w: w}
+	for {
+		diff, err := differ.next()
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return dw.count, true, nil
+			}
+			return 0, false, err
+		}
+		if diff == nil {
+			break
+		}
+		if !dw.headerWritten {
+			if err := dw.writeHeader(differ.fields()); err != nil {
+				return 0, false, err
+			}
+		}
+		if err := dw.write(diff); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if !dw.headerWritten {
+		if err := dw.writeHeader(differ.fields()); err != nil {
+			return 0, false, err
+		}
+	}
+	return dw.count, false, w.Flush()
+}
+
+// rowDiffWriter writes differences as rows of "-" for left (old) rows and "+" for right (new) rows with the names of changed columns.
+type rowDiffWriter struct {
+	w             resultWriter
+	headerWritten bool
+	count         int // number of written rows
+}
+
+// writeHeader writes the columns of the differences, which are returned by diffFields.
+func (w *rowDiffWriter) writeHeader(fields []*pb.StructType_Field) error {
+	w.headerWritten = true
+	return w.w.WriteHeader(extractColumnNames(fields), fields)
+}
+
+func (w *rowDiffWriter) write(diff *rowDiff) error {
+	changed := spanner.GenericColumnValue{Type: &pb.Type{Code: pb.TypeCode_STRING}, Value: structpb.NewNullValue()}
+	if len(diff.Changed) > 0 {
+		changed = stringValue(strings.Join(diff.Changed, ", "))
+	}
+	for _, side := range []struct {
+		sign   string
+		values []spanner.GenericColumnValue
+	}{{"-", diff.Left}, {"+", diff.Right}} {
+		if side.values == nil {
+			continue
+		}
+		if err := w.w.WriteRow(Row{Values: append([]spanner.GenericColumnValue{stringValue(side.sign), changed}, side.values...)}); err != nil {
+			return err
+		}
+		w.count++
+	}
+	return nil
+}
+
+// diffFields returns the columns of the differences, which are the sign, the changed columns and the columns of the table.
+func diffFields(tableFields []*pb.StructType_Field) []*pb.StructType_Field {
+	fields := []*pb.StructType_Field{
+		{Name: "Diff", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+		{Name: "Changed_Columns", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+	}
+	return append(fields, tableFields...)
+}
+
+// diffQueryOptions returns the options of queries to scan tables.
+func diffQueryOptions(session *Session) spanner.QueryOptions {
+	return spanner.QueryOptions{
+		Options:    session.systemVariables.queryOptions(),
+		Priority:   session.currentPriority(),
+		RequestTag: session.currentRequestTag(),
+	}
+}
+
+// diffTable is a table with the metadata needed to compare its rows by the primary key.
+type diffTable struct {
+	dumpTableName
+	Columns []diffColumn // all columns in the order of the definition
+	Keys    []diffKey    // primary key columns in the order of the key
+
+	// keyNames are the key columns of the rows of a query, whose columns are known when the first row is read.
+	keyNames []string
+}
+
+type diffColumn struct {
+	Name      string
+	Generated bool
+}
+
+type diffKey struct {
+	Column int // index of Columns
+	Desc   bool
+}
+
+// loadDiffTable reads the columns and the primary key of the table from INFORMATION_SCHEMA.
+func loadDiffTable(ctx context.Context, txn *spanner.ReadOnlyTransaction, schema, name string) (*diffTable, error) {
+	stmt := spanner.Statement{SQL: `SELECT
+  C.TABLE_SCHEMA,
+  C.TABLE_NAME,
+  C.COLUMN_NAME,
+  C.IS_GENERATED,
+  IC.ORDINAL_POSITION,
+  IC.COLUMN_ORDERING
+FROM
+  INFORMATION_SCHEMA.COLUMNS C
+LEFT JOIN
+  (SELECT * FROM INFORMATION_SCHEMA.INDEX_COLUMNS WHERE INDEX_TYPE = 'PRIMARY_KEY') IC USING(TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME)
+WHERE
+  LOWER(C.TABLE_SCHEMA) = LOWER(@table_schema) AND LOWER(C.TABLE_NAME) = LOWER(@table_name)
+ORDER BY
+  C.ORDINAL_POSITION ASC`,
+		Params: map[string]interface{}{"table_name": name, "table_schema": schema}}
+
+	table := &diffTable{}
+	keyPositions := make(map[int]int64)
+	err := txn.Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var column diffColumn
+		var generated string
+		var position spanner.NullInt64
+		var ordering spanner.NullString
+		if err := row.Columns(&table.Schema, &table.Name, &column.Name, &generated, &position, &ordering); err != nil {
+			return err
+		}
+		column.Generated = generated != "NEVER"
+		if position.Valid {
+			table.Keys = append(table.Keys, diffKey{Column: len(table.Columns), Desc: ordering.StringVal == "DESC"})
+			keyPositions[len(table.Columns)] = position.Int64
+		}
+		table.Columns = append(table.Columns, column)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(table.Columns) == 0 {
+		return nil, fmt.Errorf("table %q doesn't exist in schema %q", name, schema)
+	}
+	sort.Slice(table.Keys, func(i, j int) bool {
+		return keyPositions[table.Keys[i].Column] < keyPositions[table.Keys[j].Column]
+	})
+	return table, nil
+}
+
+// setQueryColumns sets the columns of the rows of a query and its keys.
+// Both sides must return the same columns, so it checks that they are the same if they are already set.
+func (t *diffTable) setQueryColumns(fields []*pb.StructType_Field) error {
+	if t.keyNames == nil {
+		return nil
+	}
+	names := extractColumnNames(fields)
+	if t.Columns != nil {
+		same := len(names) == len(t.Columns)
+		for i := 0; same && i < len(names); i++ {
+			same = strings.EqualFold(names[i], t.Columns[i].Name)
+		}
+		if !same {
+			var columns []string
+			for _, column := range t.Columns {
+				columns = append(columns, column.Name)
+			}
+			return fmt.Errorf("columns of the query differ: (%s) and (%s)", strings.Join(columns, ", "), strings.Join(names, ", "))
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		t.Columns = append(t.Columns, diffColumn{Name: name})
+	}
+	for _, key := range t.keyNames {
+		column := -1
+		for i, name := range names {
+			if !strings.EqualFold(name, key) {
+				continue
+			}
+			if column >= 0 {
+				return fmt.Errorf("key column %q is ambiguous in the result of the query", key)
+			}
+			column = i
+		}
+		if column < 0 {
+			return fmt.Errorf("key column %q doesn't exist in the result of the query", key)
+		}
+		t.Keys = append(t.Keys, diffKey{Column: column})
+	}
+	return nil
+}
+
+// scanQuery returns the query which reads all columns of the rows matching the condition in the order of the primary key.
+func (t *diffTable) scanQuery(where string) string {
+	columns := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		columns[i] = quoteSQLIdentifier(column.Name)
+	}
+	var orderBy []string
+	for _, key := range t.Keys {
+		order := quoteSQLIdentifier(t.Columns[key.Column].Name)
+		if key.Desc {
+			order += " DESC"
+		}
+		orderBy = append(orderBy, order)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), t.dumpTableName)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	if len(orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(orderBy, ", ")
+	}
+	return query
+}
+
+// compareKeys compares the primary keys of two rows in the order of the key.
+func (t *diffTable) compareKeys(a, b []spanner.GenericColumnValue) (int, error) {
+	for _, key := range t.Keys {
+		c, err := compareKeyValues(a[key.Column], b[key.Column])
+		if err != nil {
+			return 0, fmt.Errorf("column %q: %w", t.Columns[key.Column].Name, err)
+		}
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+// compareKeyValues compares two values of a key column in the same order as Cloud Spanner, where NULL is the smallest.
+func compareKeyValues(a, b spanner.GenericColumnValue) (int, error) {
+	if aNull, bNull := isNullValue(a), isNullValue(b); aNull || bNull {
+		switch {
+		case aNull && bNull:
+			return 0, nil
+		case aNull:
+			return -1, nil
+		default:
+			return 1, nil
+		}
+	}
+
+	x, err := exportValue(a.Type, a.Value)
+	if err != nil {
+		return 0, err
+	}
+	y, err := exportValue(b.Type, b.Value)
+	if err != nil {
+		return 0, err
+	}
+	switch x := x.(type) {
+	case bool:
+		if y, ok := y.(bool); ok {
+			return cmp.Compare(boolToInt(x), boolToInt(y)), nil
+		}
+	case int64:
+		if y, ok := y.(int64); ok {
+			return cmp.Compare(x, y), nil
+		}
+	case float64:
+		// NaN is smaller than any other values as same as Cloud Spanner.
+		if y, ok := y.(float64); ok {
+			return cmp.Compare(x, y), nil
+		}
+	case *big.Rat:
+		if y, ok := y.(*big.Rat); ok {
+			return x.Cmp(y), nil
+		}
+	case string:
+		// Strings are ordered by UTF-8 bytes, which is the order of code points.
+		if y, ok := y.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case []byte:
+		if y, ok := y.([]byte); ok {
+			return bytes.Compare(x, y), nil
+		}
+	case civil.Date:
+		if y, ok := y.(civil.Date); ok {
+			switch {
+			case x.Before(y):
+				return -1, nil
+			case x.After(y):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case time.Time:
+		if y, ok := y.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	}
+	return 0, fmt.Errorf("can't compare %s with %s", formatTypeSimple(a.Type), formatTypeSimple(b.Type))
+}
+
+// changedColumns returns the names of columns whose values differ between two rows.
+func (t *diffTable) changedColumns(a, b []spanner.GenericColumnValue) []string {
+	var changed []string
+	for i := range a {
+		if !proto.Equal(a[i].Value, b[i].Value) {
+			changed = append(changed, t.Columns[i].Name)
+		}
+	}
+	return changed
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// rowDiff is a difference of a row between the left and the right, for example, the old and the new rows.
+// Left is nil for a row which exists only in the right, and Right is nil for a row which exists only in the left.
+type rowDiff struct {
+	Left    []spanner.GenericColumnValue
+	Right   []spanner.GenericColumnValue
+	Changed []string // names of changed columns of a row which exists in both
+}
+
+// rowDiffer merges rows of two iterators in the order of the primary key, and finds differences.
+type rowDiffer struct {
+	table       *diffTable
+	left, right *diffRowReader
+
+	// Counts of rows found so far.
+	onlyLeft, onlyRight, changed, same int
+}
+
+func newRowDiffer(table *diffTable, left, right *spanner.RowIterator) *rowDiffer {
+	return &rowDiffer{
+		table: table,
+		left:  &diffRowReader{table: table, iter: left},
+		right: &diffRowReader{table: table, iter: right},
+	}
+}
+
+// next returns the next difference, or nil if there are no more differences.
+func (d *rowDiffer) next() (*rowDiff, error) {
+	for {
+		left, err := d.left.peek()
+		if err != nil {
+			return nil, err
+		}
+		right, err := d.right.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		var c int
+		switch {
+		case left == nil && right == nil:
+			return nil, nil
+		case left == nil:
+			c = 1
+		case right == nil:
+			c = -1
+		default:
+			if c, err = d.table.compareKeys(left, right); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case c < 0:
+			d.left.consume()
+			d.onlyLeft++
+			return &rowDiff{Left: left}, nil
+		case c > 0:
+			d.right.consume()
+			d.onlyRight++
+			return &rowDiff{Right: right}, nil
+		}
+		d.left.consume()
+		d.right.consume()
+		changed := d.table.changedColumns(left, right)
+		if len(changed) == 0 {
+			d.same++
+			continue
+		}
+		d.changed++
+		return &rowDiff{Left: left, Right: right, Changed: changed}, nil
+	}
+}
+
+// fields returns the columns of the differences, which are the sign, the changed columns and the columns of the table.
+// It is valid after next is called.
+func (d *rowDiffer) fields() []*pb.StructType_Field {
+	metadata := d.right.iter.Metadata
+	if metadata == nil {
+		metadata = d.left.iter.Metadata
+	}
+	return diffFields(metadata.GetRowType().GetFields())
+}
+
+func (d *rowDiffer) stop() {
+	d.left.iter.Stop()
+	d.right.iter.Stop()
+}
+
+// diffRowReader reads rows one ahead, and checks that they are in the order of the primary key.
+type diffRowReader struct {
+	table *diffTable
+	iter  *spanner.RowIterator
+	row   []spanner.GenericColumnValue
+	last  []spanner.GenericColumnValue
+	done  bool
+}
+
+// peek returns the current row without consuming it, or nil at the end.
+func (r *diffRowReader) peek() ([]spanner.GenericColumnValue, error) {
+	if r.row != nil || r.done {
+		return r.row, nil
+	}
+	row, err := r.iter.Next()
+	if err == iterator.Done {
+		r.done = true
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, err := rowValues(row)
+	if err != nil {
+		return nil, err
+	}
+	if r.last == nil {
+		if err := r.table.setQueryColumns(r.iter.Metadata.GetRowType().GetFields()); err != nil {
+			return nil, err
+		}
+	} else {
+		c, err := r.table.compareKeys(r.last, values)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case c == 0:
+			return nil, errors.New("rows have the same key")
+		case c > 0:
+			return nil, errors.New("rows are not read in the order of the key")
+		}
+	}
+	r.row = values
+	return values, nil
+}
+
+func (r *diffRowReader) consume() {
+	r.last, r.row = r.row, nil
+}
+
+var diffSecondsRe = regexp.MustCompile(`(?i)^(\d+)\s*(?:SEC|SECS|SECOND|SECONDS)$`)
+
+// parseDiffTimestamp parses a read timestamp of DIFF TABLE, which is NOW for a strong read,
+// a negative duration such as -60 sec or -5m for an exact staleness, or a timestamp.
+func parseDiffTimestamp(s string) (readOnlyStaleness, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "NOW") {
+		return readOnlyStaleness{typ: strong}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		rest = strings.TrimSpace(rest)
+		var d time.Duration
+		if matched := diffSecondsRe.FindStringSubmatch(rest); matched != nil {
+			n, err := strconv.Atoi(matched[1])
+			if err != nil {
+				return readOnlyStaleness{}, fmt.Errorf("invalid staleness: %q", s)
+			}
+			d = time.Duration(n) * time.Second
+		} else {
+			var err error
+			if d, err = time.ParseDuration(rest); err != nil || d <= 0 {
+				return readOnlyStaleness{}, fmt.Errorf("invalid staleness: %q", s)
+			}
+		}
+		return readOnlyStaleness{typ: exactStaleness, staleness: d}, nil
+	}
+
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`) {
+		unquoted, n, err := unquoteStringLiteral(s, false)
+		if err != nil || n != len(s) {
+			return readOnlyStaleness{}, fmt.Errorf("invalid timestamp: %s", s)
+		}
+		s = unquoted
+	}
+	t, err := parseTimestampLiteral(s)
+	if err != nil {
+		return readOnlyStaleness{}, err
+	}
+	return readOnlyStaleness{typ: readTimestamp, timestamp: t}, nil
+}
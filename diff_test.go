@@ -0,0 +1,159 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCompareKeyValues(t *testing.T) {
+	value := func(code pb.TypeCode, v *structpb.Value) spanner.GenericColumnValue {
+		return spanner.GenericColumnValue{Type: &pb.Type{Code: code}, Value: v}
+	}
+	for _, tt := range []struct {
+		desc string
+		a, b spanner.GenericColumnValue
+		want int
+	}{
+		{
+			desc: "NULL is the smallest",
+			a:    value(pb.TypeCode_INT64, structpb.NewNullValue()),
+			b:    value(pb.TypeCode_INT64, structpb.NewStringValue("-9223372036854775808")),
+			want: -1,
+		},
+		{
+			desc: "INT64 is compared as numbers",
+			a:    value(pb.TypeCode_INT64, structpb.NewStringValue("10")),
+			b:    value(pb.TypeCode_INT64, structpb.NewStringValue("9")),
+			want: 1,
+		},
+		{
+			desc: "NaN is smaller than -Infinity",
+			a:    value(pb.TypeCode_FLOAT64, floatValue(math.NaN())),
+			b:    value(pb.TypeCode_FLOAT64, floatValue(math.Inf(-1))),
+			want: -1,
+		},
+		{
+			desc: "STRING is compared by code points",
+			a:    value(pb.TypeCode_STRING, structpb.NewStringValue("Z")),
+			b:    value(pb.TypeCode_STRING, structpb.NewStringValue("a")),
+			want: -1,
+		},
+		{
+			desc: "TIMESTAMP is compared as time",
+			a:    value(pb.TypeCode_TIMESTAMP, structpb.NewStringValue("2024-01-02T03:04:05.5Z")),
+			b:    value(pb.TypeCode_TIMESTAMP, structpb.NewStringValue("2024-01-02T03:04:05Z")),
+			want: 1,
+		},
+		{
+			desc: "NUMERIC is compared as numbers",
+			a:    value(pb.TypeCode_NUMERIC, structpb.NewStringValue("1.500000000")),
+			b:    value(pb.TypeCode_NUMERIC, structpb.NewStringValue("1.5")),
+			want: 0,
+		},
+		{
+			desc: "DATE",
+			a:    value(pb.TypeCode_DATE, structpb.NewStringValue("2024-01-02")),
+			b:    value(pb.TypeCode_DATE, structpb.NewStringValue("2023-12-31")),
+			want: 1,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := compareKeyValues(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("compareKeyValues() got error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("compareKeyValues() = %d, but want = %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := compareKeyValues(value(pb.TypeCode_INT64, structpb.NewStringValue("1")), value(pb.TypeCode_STRING, structpb.NewStringValue("1"))); err == nil {
+		t.Errorf("compareKeyValues() should fail for different types")
+	}
+}
+
+func TestRowDiffer(t *testing.T) {
+	ctx := context.Background()
+	session := newLoadTestSession(t,
+		"CREATE TABLE Old (Id INT64 NOT NULL, Name STRING(MAX), Age INT64) PRIMARY KEY (Id DESC)",
+		"CREATE TABLE New (Id INT64 NOT NULL, Name STRING(MAX), Age INT64) PRIMARY KEY (Id DESC)",
+	)
+	columns := []string{"Id", "Name", "Age"}
+	_, err := session.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert("Old", columns, []interface{}{1, "removed", 10}),
+		spanner.Insert("Old", columns, []interface{}{2, "same", 20}),
+		spanner.Insert("Old", columns, []interface{}{3, "changed", 30}),
+		spanner.Insert("Old", columns, []interface{}{5, "same", nil}),
+		spanner.Insert("New", columns, []interface{}{2, "same", 20}),
+		spanner.Insert("New", columns, []interface{}{3, "changed", nil}),
+		spanner.Insert("New", columns, []interface{}{4, "added", 40}),
+		spanner.Insert("New", columns, []interface{}{5, "same", nil}),
+	})
+	if err != nil {
+		t.Fatalf("failed to insert rows: %v", err)
+	}
+
+	newTable := func(name string) *diffTable {
+		return &diffTable{
+			dumpTableName: dumpTableName{Name: name},
+			Columns:       []diffColumn{{Name: "Id"}, {Name: "Name"}, {Name: "Age"}},
+			Keys:          []diffKey{{Column: 0, Desc: true}},
+		}
+	}
+	oldIter := session.client.Single().Query(ctx, spanner.Statement{SQL: newTable("Old").scanQuery("")})
+	newIter := session.client.Single().Query(ctx, spanner.Statement{SQL: newTable("New").scanQuery("")})
+	differ := newRowDiffer(newTable("Old"), oldIter, newIter)
+	defer differ.stop()
+
+	w := &rowCollector{}
+	count, interrupted, err := writeRowDiffs(ctx, differ, w)
+	if err != nil {
+		t.Fatalf("writeRowDiffs() got error: %v", err)
+	}
+	if interrupted {
+		t.Errorf("writeRowDiffs() was interrupted")
+	}
+
+	got := decodeRows(t, w.rows)
+	want := [][]string{
+		{"+", "NULL", "4", "added", "40"},
+		{"-", "Age", "3", "changed", "30"},
+		{"+", "Age", "3", "changed", "NULL"},
+		{"-", "NULL", "1", "removed", "10"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("differences mismatch (-want +got):\n%s", diff)
+	}
+	if count != len(want) {
+		t.Errorf("count mismatch: got = %d, want = %d", count, len(want))
+	}
+	if differ.onlyLeft != 1 || differ.onlyRight != 1 || differ.changed != 1 || differ.same != 2 {
+		t.Errorf("counts mismatch: got = %+v", differ)
+	}
+	if diff := cmp.Diff([]string{"Diff", "Changed_Columns", "Id", "Name", "Age"}, extractColumnNames(differ.fields())); diff != "" {
+		t.Errorf("columns mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,417 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// mutationLimit is the maximum number of mutations in a commit.
+	// https://cloud.google.com/spanner/quotas#limits-for
+	mutationLimit = 80000
+
+	// dumpStatementBytesLimit keeps an INSERT statement under the limit of the SQL statement length (1 MiB).
+	dumpStatementBytesLimit = 1000000
+)
+
+// DumpStatement prints the schema and the data of the database, or of the tables, as an SQL script
+// which can be replayed by `spanner-cli -f`.
+type DumpStatement struct {
+	Tables []dumpTableName // nil means all tables in the database
+}
+
+type dumpTableName struct {
+	Schema string
+	Name   string
+}
+
+// String returns the name which can be used in SQL statements.
+func (n dumpTableName) String() string {
+	if n.Schema == "" {
+		return quoteSQLIdentifier(n.Name)
+	}
+	return quoteSQLIdentifier(n.Schema) + "." + quoteSQLIdentifier(n.Name)
+}
+
+// qualifiedName is the unquoted name used as the key of tables and for Read API.
+func (n dumpTableName) qualifiedName() string {
+	if n.Schema == "" {
+		return n.Name
+	}
+	return n.Schema + "." + n.Name
+}
+
+// dumpTable is a table with the metadata needed to dump its rows.
+type dumpTable struct {
+	dumpTableName
+	Parent     string   // qualified name of the interleaving parent
+	References []string // qualified names of tables referenced by foreign keys
+	Columns    []string // non-generated columns
+	Indexes    int
+}
+
+func (s *DumpStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	result := &Result{ColumnNames: []string{"Statement"}}
+	rows, timestamp, err := s.dump(ctx, session, func(readTimestamp time.Time) error {
+		return nil
+	}, func(stmt string) error {
+		result.Rows = append(result.Rows, stringRow(stmt))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.AffectedRows = rows
+	result.Timestamp = timestamp
+	return result, nil
+}
+
+// ExecuteScript writes the statements terminated by semicolons to the output as they are built.
+// The script begins with a comment of the read timestamp of the snapshot.
+func (s *DumpStatement) ExecuteScript(ctx context.Context, session *Session, out io.Writer) (*Result, error) {
+	rows, timestamp, err := s.dump(ctx, session, func(readTimestamp time.Time) error {
+		_, err := fmt.Fprintf(out, "-- read at %s\n", readTimestamp.Format(time.RFC3339Nano))
+		return err
+	}, func(stmt string) error {
+		_, err := fmt.Fprintf(out, "%s;\n", stmt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Result{AffectedRows: rows, Timestamp: timestamp}, nil
+}
+
+// dump emits DDL statements followed by INSERT statements, and returns the number of dumped rows and the read timestamp.
+// emitHeader is called with the read timestamp before the statements.
+func (s *DumpStatement) dump(ctx context.Context, session *Session, emitHeader func(readTimestamp time.Time) error, emit func(stmt string) error) (int, time.Time, error) {
+	ddls, err := getDumpDDLs(ctx, session)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	// All tables and their metadata in INFORMATION_SCHEMA are read at the same timestamp to dump a consistent snapshot.
+	txn := session.client.ReadOnlyTransaction()
+	defer txn.Close()
+
+	tables, err := loadDumpTables(ctx, txn)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	// ReadOnlyTransaction.Timestamp() is valid after the first read.
+	timestamp, err := txn.Timestamp()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	// GetDatabaseDdl can't read the schema at the timestamp, so the schema must not be changed
+	// between before and after the timestamp is fixed.
+	ddlsAfter, err := getDumpDDLs(ctx, session)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if !slices.Equal(ddls, ddlsAfter) {
+		return 0, time.Time{}, errors.New("the schema is changed while the snapshot is taken, retry the dump")
+	}
+
+	if s.Tables != nil {
+		if tables, err = selectDumpTables(tables, s.Tables); err != nil {
+			return 0, time.Time{}, err
+		}
+		ddls = filterTableDDLs(ddls, s.Tables)
+	}
+
+	if err := emitHeader(timestamp); err != nil {
+		return 0, time.Time{}, err
+	}
+	if len(ddls) > 0 {
+		// DDL statements are applied in a batch because it is much faster than one by one.
+		for _, stmt := range append(append([]string{"START BATCH DDL"}, ddls...), "RUN BATCH") {
+			if err := emit(stmt); err != nil {
+				return 0, time.Time{}, err
+			}
+		}
+	}
+
+	var rows int
+	for _, table := range sortDumpTables(tables) {
+		n, err := dumpRows(ctx, txn, table, emit)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		rows += n
+	}
+	return rows, timestamp, nil
+}
+
+func getDumpDDLs(ctx context.Context, session *Session) ([]string, error) {
+	response, err := session.adminClient.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{
+		Database: session.DatabasePath(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.GetStatements(), nil
+}
+
+// loadDumpTables reads the tables and their dependencies from INFORMATION_SCHEMA.
+func loadDumpTables(ctx context.Context, txn *spanner.ReadOnlyTransaction) (map[string]*dumpTable, error) {
+	tables := make(map[string]*dumpTable)
+	err := txn.Query(ctx, spanner.NewStatement(
+		"SELECT TABLE_SCHEMA, TABLE_NAME, PARENT_TABLE_NAME FROM INFORMATION_SCHEMA.TABLES "+
+			"WHERE TABLE_CATALOG = '' AND TABLE_SCHEMA NOT IN ('INFORMATION_SCHEMA', 'SPANNER_SYS') AND TABLE_TYPE = 'BASE TABLE'",
+	)).Do(func(row *spanner.Row) error {
+		var schema, name string
+		var parent spanner.NullString
+		if err := row.Columns(&schema, &name, &parent); err != nil {
+			return err
+		}
+		table := &dumpTable{dumpTableName: dumpTableName{Schema: schema, Name: name}}
+		if parent.Valid {
+			table.Parent = dumpTableName{Schema: schema, Name: parent.StringVal}.qualifiedName()
+		}
+		tables[table.qualifiedName()] = table
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = txn.Query(ctx, spanner.NewStatement(
+		"SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS "+
+			"WHERE TABLE_CATALOG = '' AND IS_GENERATED = 'NEVER' ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION",
+	)).Do(func(row *spanner.Row) error {
+		var schema, name, column string
+		if err := row.Columns(&schema, &name, &column); err != nil {
+			return err
+		}
+		if table, ok := tables[dumpTableName{Schema: schema, Name: name}.qualifiedName()]; ok {
+			table.Columns = append(table.Columns, column)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = txn.Query(ctx, spanner.NewStatement(
+		"SELECT TABLE_SCHEMA, TABLE_NAME, COUNT(*) FROM INFORMATION_SCHEMA.INDEXES "+
+			"WHERE TABLE_CATALOG = '' AND INDEX_TYPE = 'INDEX' GROUP BY TABLE_SCHEMA, TABLE_NAME",
+	)).Do(func(row *spanner.Row) error {
+		var schema, name string
+		var count int64
+		if err := row.Columns(&schema, &name, &count); err != nil {
+			return err
+		}
+		if table, ok := tables[dumpTableName{Schema: schema, Name: name}.qualifiedName()]; ok {
+			table.Indexes = int(count)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = txn.Query(ctx, spanner.NewStatement(
+		"SELECT fk.TABLE_SCHEMA, fk.TABLE_NAME, pk.TABLE_SCHEMA, pk.TABLE_NAME "+
+			"FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc "+
+			"JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS fk ON rc.CONSTRAINT_SCHEMA = fk.CONSTRAINT_SCHEMA AND rc.CONSTRAINT_NAME = fk.CONSTRAINT_NAME "+
+			"JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS pk ON rc.UNIQUE_CONSTRAINT_SCHEMA = pk.CONSTRAINT_SCHEMA AND rc.UNIQUE_CONSTRAINT_NAME = pk.CONSTRAINT_NAME",
+	)).Do(func(row *spanner.Row) error {
+		var schema, name, refSchema, refName string
+		if err := row.Columns(&schema, &name, &refSchema, &refName); err != nil {
+			return err
+		}
+		if table, ok := tables[dumpTableName{Schema: schema, Name: name}.qualifiedName()]; ok {
+			table.References = append(table.References, dumpTableName{Schema: refSchema, Name: refName}.qualifiedName())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// selectDumpTables returns the specified tables, which must exist.
+func selectDumpTables(tables map[string]*dumpTable, names []dumpTableName) (map[string]*dumpTable, error) {
+	selected := make(map[string]*dumpTable)
+	for _, name := range names {
+		table, ok := tables[name.qualifiedName()]
+		if !ok {
+			return nil, fmt.Errorf("table %q doesn't exist in schema %q", name.Name, name.Schema)
+		}
+		selected[name.qualifiedName()] = table
+	}
+	return selected, nil
+}
+
+// filterTableDDLs returns CREATE TABLE and CREATE INDEX statements of the tables in the original order.
+func filterTableDDLs(ddls []string, names []dumpTableName) []string {
+	var filtered []string
+	for _, ddl := range ddls {
+		for _, name := range names {
+			if isCreateTableDDL(ddl, name.Schema, name.Name) || isCreateIndexDDL(ddl, name.Schema, name.Name) {
+				filtered = append(filtered, ddl)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func isCreateIndexDDL(ddl string, schema string, table string) bool {
+	table = regexp.QuoteMeta(table)
+	var re string
+	if schema == "" {
+		re = fmt.Sprintf("(?is)^CREATE\\s+(?:UNIQUE\\s+)?(?:NULL_FILTERED\\s+)?INDEX\\s+\\S+\\s+ON\\s+(%s|`%s`)\\s*\\(", table, table)
+	} else {
+		schema = regexp.QuoteMeta(schema)
+		re = fmt.Sprintf("(?is)^CREATE\\s+(?:UNIQUE\\s+)?(?:NULL_FILTERED\\s+)?INDEX\\s+\\S+\\s+ON\\s+(%s|`%s`)\\.(%s|`%s`)\\s*\\(", schema, schema, table, table)
+	}
+	return regexp.MustCompile(re).MatchString(ddl)
+}
+
+// sortDumpTables orders the tables so that interleaving parents and tables referenced by foreign keys come first.
+// Tables without dependencies between them are ordered by names.
+// Tables in a cycle of foreign keys are put at the end in the order of names, and may need to be fixed by hand.
+func sortDumpTables(tables map[string]*dumpTable) []*dumpTable {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sorted []*dumpTable
+	done := make(map[string]bool)
+	for len(sorted) < len(names) {
+		progressed := false
+		for _, name := range names {
+			table := tables[name]
+			if done[name] || !dependenciesDone(table, tables, done) {
+				continue
+			}
+			sorted = append(sorted, table)
+			done[name] = true
+			progressed = true
+		}
+		if !progressed {
+			for _, name := range names {
+				if !done[name] {
+					sorted = append(sorted, tables[name])
+					done[name] = true
+				}
+			}
+		}
+	}
+	return sorted
+}
+
+// dependenciesDone reports whether all dependencies of the table in the tables are done.
+// A self-reference and tables which are not dumped are ignored.
+func dependenciesDone(table *dumpTable, tables map[string]*dumpTable, done map[string]bool) bool {
+	for _, dep := range append([]string{table.Parent}, table.References...) {
+		if _, ok := tables[dep]; !ok || dep == table.qualifiedName() {
+			continue
+		}
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// dumpRows emits INSERT statements of all rows in the table.
+// Rows are split into statements which fit in the mutation limit and the statement length limit,
+// considering that secondary indexes also count mutations.
+func dumpRows(ctx context.Context, txn *spanner.ReadOnlyTransaction, table *dumpTable, emit func(stmt string) error) (int, error) {
+	if len(table.Columns) == 0 {
+		return 0, nil
+	}
+	rowsPerStatement := mutationLimit / (len(table.Columns) * (1 + table.Indexes))
+	if rowsPerStatement < 1 {
+		rowsPerStatement = 1
+	}
+
+	columns := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = quoteSQLIdentifier(column)
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES", table, strings.Join(columns, ", "))
+
+	var values []string
+	var size, rows int
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		stmt := prefix + "\n" + strings.Join(values, ",\n")
+		values, size = nil, len(prefix)
+		return emit(stmt)
+	}
+
+	size = len(prefix)
+	iter := txn.Read(ctx, table.qualifiedName(), spanner.AllKeys(), table.Columns)
+	defer iter.Stop()
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		literals := make([]string, row.Size())
+		for i := range literals {
+			var value spanner.GenericColumnValue
+			if err := row.Column(i, &value); err != nil {
+				return 0, err
+			}
+			if literals[i], err = formatSQLLiteral(value); err != nil {
+				return 0, err
+			}
+		}
+		tuple := "  (" + strings.Join(literals, ", ") + ")"
+
+		if len(values) >= rowsPerStatement || (len(values) > 0 && size+len(tuple)+2 > dumpStatementBytesLimit) {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+		values = append(values, tuple)
+		size += len(tuple) + 2
+		rows++
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
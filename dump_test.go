@@ -0,0 +1,123 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSortDumpTables(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		tables []*dumpTable
+		want   []string
+	}{
+		{
+			desc: "interleaved tables",
+			tables: []*dumpTable{
+				{dumpTableName: dumpTableName{Name: "Albums"}, Parent: "Singers"},
+				{dumpTableName: dumpTableName{Name: "Songs"}, Parent: "Albums"},
+				{dumpTableName: dumpTableName{Name: "Singers"}},
+			},
+			want: []string{"Singers", "Albums", "Songs"},
+		},
+		{
+			desc: "foreign keys",
+			tables: []*dumpTable{
+				{dumpTableName: dumpTableName{Name: "A"}, References: []string{"s.C"}},
+				{dumpTableName: dumpTableName{Name: "B"}, References: []string{"B"}},
+				{dumpTableName: dumpTableName{Schema: "s", Name: "C"}, References: []string{"Other"}},
+			},
+			want: []string{"B", "s.C", "A"},
+		},
+		{
+			desc: "cycle",
+			tables: []*dumpTable{
+				{dumpTableName: dumpTableName{Name: "A"}, References: []string{"B"}},
+				{dumpTableName: dumpTableName{Name: "B"}, References: []string{"A"}},
+				{dumpTableName: dumpTableName{Name: "C"}},
+			},
+			want: []string{"C", "A", "B"},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			tables := make(map[string]*dumpTable)
+			for _, table := range tt.tables {
+				tables[table.qualifiedName()] = table
+			}
+			var got []string
+			for _, table := range sortDumpTables(tables) {
+				got = append(got, table.qualifiedName())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("sortDumpTables() differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestFilterTableDDLs(t *testing.T) {
+	ddls := []string{
+		"CREATE TABLE Singers (\n  SingerId INT64 NOT NULL,\n) PRIMARY KEY(SingerId)",
+		"CREATE TABLE Albums (\n  SingerId INT64 NOT NULL,\n) PRIMARY KEY(SingerId)",
+		"CREATE UNIQUE NULL_FILTERED INDEX SingersByName ON Singers(Name)",
+		"CREATE INDEX AlbumsByTitle ON Albums(Title)",
+		"CREATE TABLE sch1.Singers (\n  SingerId INT64 NOT NULL,\n) PRIMARY KEY(SingerId)",
+		"CREATE VIEW SingerNames SQL SECURITY INVOKER AS SELECT Name FROM Singers",
+	}
+	got := filterTableDDLs(ddls, []dumpTableName{{Name: "Singers"}, {Schema: "sch1", Name: "Singers"}})
+	want := []string{ddls[0], ddls[2], ddls[4]}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("filterTableDDLs() differs: %v", diff)
+	}
+}
+
+func TestDumpRows(t *testing.T) {
+	ctx := context.Background()
+	session := newLoadTestSession(t, "CREATE TABLE `Order` (Id INT64 NOT NULL, `Group` STRING(MAX)) PRIMARY KEY (Id)")
+	_, err := session.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert("Order", []string{"Id", "Group"}, []interface{}{1, "a"}),
+		spanner.Insert("Order", []string{"Id", "Group"}, []interface{}{2, nil}),
+	})
+	if err != nil {
+		t.Fatalf("failed to insert rows: %v", err)
+	}
+
+	// Reserved keywords are quoted, so that the statements can be replayed.
+	table := &dumpTable{dumpTableName: dumpTableName{Name: "Order"}, Columns: []string{"Id", "Group"}}
+	var got []string
+	txn := session.client.ReadOnlyTransaction()
+	defer txn.Close()
+	n, err := dumpRows(ctx, txn, table, func(stmt string) error {
+		got = append(got, stmt)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("dumpRows() got error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("dumpRows() = %d, want = 2", n)
+	}
+	want := []string{"INSERT INTO `Order` (Id, `Group`) VALUES\n  (1, \"a\"),\n  (2, NULL)"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("dumpRows() differs: %v", diff)
+	}
+}
@@ -0,0 +1,417 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ExportQueryStatement writes the result of the query to a local file in a columnar format.
+type ExportQueryStatement struct {
+	Query  string
+	Path   string
+	Format string // PARQUET or AVRO, empty to infer from the file extension
+}
+
+func (s *ExportQueryStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	format, err := exportFormat(s.Format, s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := createExportFile(s.Path, format)
+	if err != nil {
+		return nil, err
+	}
+	// Rows are written as they arrive, so that the memory is bounded by a row group or a block.
+	result, err := (&SelectStatement{Query: s.Query}).ExecuteStream(ctx, session, f)
+	if err == nil && ctx.Err() != nil {
+		// The query stops without an error when it is interrupted, but the truncated file must not be kept.
+		err = fmt.Errorf("export was interrupted after %d rows: %w", result.AffectedRows, ctx.Err())
+	}
+	if err := f.close(err); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		IsMutation:   true,
+		AffectedRows: result.AffectedRows,
+		Stats:        result.Stats,
+		Timestamp:    result.Timestamp,
+		Warnings:     result.Warnings,
+	}, nil
+}
+
+// PartitionedExportStatement writes the result of the query to local files by executing partitions of the query in parallel.
+// All partitions are read at the same timestamp by a batch read-only transaction.
+type PartitionedExportStatement struct {
+	Query            string
+	Path             string
+	Format           string // PARQUET or AVRO, empty to infer from the file extension
+	Workers          int    // 0 for defaultExportWorkers
+	DataBoost        bool
+	FilePerPartition bool
+}
+
+// defaultExportWorkers is the number of partitions executed concurrently by default.
+const defaultExportWorkers = 4
+
+func (s *PartitionedExportStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	// Bounded staleness is only supported in single-use transactions.
+	staleness := session.systemVariables.ReadOnlyStaleness
+	if staleness.typ == maxStaleness || staleness.typ == minReadTimestamp {
+		return nil, fmt.Errorf("READ_ONLY_STALENESS %s is not supported by partitioned export, use STRONG, EXACT_STALENESS or READ_TIMESTAMP", staleness)
+	}
+
+	format, err := exportFormat(s.Format, s.Path)
+	if err != nil {
+		return nil, err
+	}
+	workers := s.Workers
+	if workers == 0 {
+		workers = defaultExportWorkers
+	}
+
+	txn, err := session.client.BatchReadOnlyTransaction(ctx, session.systemVariables.ReadOnlyStaleness.timestampBound())
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	opts := spanner.QueryOptions{
+		Options:          session.systemVariables.queryOptions(),
+		Priority:         session.currentPriority(),
+		RequestTag:       session.currentRequestTag(),
+		DataBoostEnabled: s.DataBoost,
+	}
+	partitions, err := txn.PartitionQueryWithOptions(ctx, session.newStatement(s.Query), spanner.PartitionOptions{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu    sync.Mutex
+		rows  int
+		done  int
+		paths []string
+	)
+	// finish records the partition, and shows the progress.
+	finish := func(count int) {
+		mu.Lock()
+		defer mu.Unlock()
+		rows += count
+		done++
+		session.SetProgress(fmt.Sprintf("%d/%d partitions, %d rows", done, len(partitions), rows))
+	}
+
+	var merged *exportFile
+	var mergedWriter *syncResultWriter
+	if !s.FilePerPartition {
+		if merged, err = createExportFile(s.Path, format); err != nil {
+			return nil, err
+		}
+		mergedWriter = &syncResultWriter{w: merged}
+		paths = append(paths, s.Path)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for i, p := range partitions {
+		i, p := i, p
+		g.Go(func() error {
+			if merged != nil {
+				count, err := exportPartition(gctx, txn, p, mergedWriter)
+				if err != nil {
+					return err
+				}
+				finish(count)
+				return nil
+			}
+
+			path := partitionFilePath(s.Path, i)
+			f, err := createExportFile(path, format)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			paths = append(paths, path)
+			mu.Unlock()
+			count, err := exportPartition(gctx, txn, p, f)
+			if err == nil {
+				err = f.Flush()
+			}
+			if err := f.close(err); err != nil {
+				return err
+			}
+			finish(count)
+			return nil
+		})
+	}
+	err = g.Wait()
+	if merged != nil {
+		if err == nil && len(partitions) == 0 {
+			err = errors.New("the query returned no partitions")
+		}
+		if err == nil {
+			err = merged.Flush()
+		}
+		err = merged.close(err)
+	}
+	if err != nil {
+		// Files of the other partitions are also removed, because they are an incomplete result.
+		for _, path := range paths {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+
+	// All partitions are read at the timestamp of the transaction, which is reported to read the same snapshot again.
+	timestamp, _ := txn.Timestamp()
+	return &Result{
+		IsMutation:   true,
+		AffectedRows: rows,
+		Timestamp:    timestamp,
+		ForceVerbose: true,
+	}, nil
+}
+
+// exportPartition writes rows of the partition to w, and returns the number of rows. w is not flushed.
+func exportPartition(ctx context.Context, txn *spanner.BatchReadOnlyTransaction, p *spanner.Partition, w resultWriter) (int, error) {
+	iter := txn.Execute(ctx, p)
+	defer iter.Stop()
+
+	var headerWritten bool
+	writeHeader := func() error {
+		headerWritten = true
+		fields := iter.Metadata.GetRowType().GetFields()
+		return w.WriteHeader(extractColumnNames(fields), fields)
+	}
+
+	var count int
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !headerWritten {
+			if err := writeHeader(); err != nil {
+				return 0, err
+			}
+		}
+		values, err := rowValues(row)
+		if err != nil {
+			return 0, err
+		}
+		if err := w.WriteRow(Row{Values: values}); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	if !headerWritten {
+		if err := writeHeader(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// partitionFilePath returns the path of the file of the i-th partition, which has the index before the extension.
+// For example, the file of the first partition of "/tmp/singers.parquet" is "/tmp/singers-00000.parquet".
+func partitionFilePath(path string, i int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%05d%s", strings.TrimSuffix(path, ext), i, ext)
+}
+
+// exportFormat returns the format given by FORMAT clause, or inferred from the file extension.
+func exportFormat(format, path string) (string, error) {
+	if format != "" {
+		return strings.ToUpper(format), nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".parquet":
+		return "PARQUET", nil
+	case ".avro":
+		return "AVRO", nil
+	default:
+		return "", fmt.Errorf("FORMAT is required for file %q, or use .parquet or .avro extension", path)
+	}
+}
+
+// exportFile is a local file written by the resultWriter of its format.
+type exportFile struct {
+	resultWriter
+	path string
+	f    *os.File
+	out  *bufio.Writer
+}
+
+func createExportFile(path, format string) (*exportFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &exportFile{path: path, f: f, out: bufio.NewWriter(f)}
+	switch format {
+	case "AVRO":
+		file.resultWriter = &avroWriter{out: file.out}
+	default:
+		file.resultWriter = newParquetWriter(file.out)
+	}
+	return file, nil
+}
+
+// close closes the file after writing rows, where err is the error of writing.
+// The file is removed if writing or closing fails, because a partially written file can't be read.
+func (f *exportFile) close(err error) error {
+	if err == nil {
+		err = f.out.Flush()
+	}
+	if closeErr := f.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.path)
+	}
+	return err
+}
+
+// syncResultWriter is a resultWriter shared by concurrent partitions. Only the first header is written.
+type syncResultWriter struct {
+	mu            sync.Mutex
+	w             resultWriter
+	headerWritten bool
+}
+
+func (w *syncResultWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.w.WriteHeader(columnNames, columnTypes)
+}
+
+func (w *syncResultWriter) WriteRow(row Row) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.WriteRow(row)
+}
+
+func (w *syncResultWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Flush()
+}
+
+// validateExportColumnNames checks that columns can be fields of Parquet and Avro records.
+func validateExportColumnNames(columnNames []string) error {
+	seen := make(map[string]bool, len(columnNames))
+	for i, name := range columnNames {
+		if name == "" {
+			return fmt.Errorf("column %d has no name, give it an alias", i+1)
+		}
+		if seen[name] {
+			return fmt.Errorf("column %q is duplicated, give it an alias", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// exportValue converts a non-NULL scalar value into a Go value to be written to a file.
+// It returns bool, int64, float64, *big.Rat for NUMERIC, string for STRING and JSON,
+// []byte for BYTES and PROTO, civil.Date or time.Time.
+func exportValue(typ *pb.Type, v *structpb.Value) (interface{}, error) {
+	switch typ.GetCode() {
+	case pb.TypeCode_BOOL:
+		return v.GetBoolValue(), nil
+	case pb.TypeCode_INT64, pb.TypeCode_ENUM:
+		return strconv.ParseInt(v.GetStringValue(), 10, 64)
+	case pb.TypeCode_FLOAT64, pb.TypeCode_FLOAT32:
+		// NaN and infinities are encoded as strings.
+		if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			return strconv.ParseFloat(s.StringValue, 64)
+		}
+		return v.GetNumberValue(), nil
+	case pb.TypeCode_NUMERIC:
+		r, ok := new(big.Rat).SetString(v.GetStringValue())
+		if !ok {
+			return nil, fmt.Errorf("invalid NUMERIC value: %q", v.GetStringValue())
+		}
+		return r, nil
+	case pb.TypeCode_STRING, pb.TypeCode_JSON:
+		return v.GetStringValue(), nil
+	case pb.TypeCode_BYTES, pb.TypeCode_PROTO:
+		return base64.StdEncoding.DecodeString(v.GetStringValue())
+	case pb.TypeCode_DATE:
+		return civil.ParseDate(v.GetStringValue())
+	case pb.TypeCode_TIMESTAMP:
+		return time.Parse(time.RFC3339Nano, v.GetStringValue())
+	default:
+		return nil, fmt.Errorf("type %s is not supported", formatTypeSimple(typ))
+	}
+}
+
+// unixEpochDate is the origin of DATE values in Parquet and Avro.
+var unixEpochDate = civil.Date{Year: 1970, Month: time.January, Day: 1}
+
+// offsetWriter is a writer which knows the number of written bytes.
+type offsetWriter struct {
+	w      io.Writer
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
+// offsetReader is a reader which knows the number of read bytes.
+type offsetReader struct {
+	r      io.Reader
+	offset int64
+}
+
+func (r *offsetReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
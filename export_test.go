@@ -0,0 +1,259 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	int64Type := &pb.Type{Code: pb.TypeCode_INT64}
+	stringType := &pb.Type{Code: pb.TypeCode_STRING}
+	columns := []*loadColumn{
+		{Name: "Bool", Type: &pb.Type{Code: pb.TypeCode_BOOL}},
+		{Name: "Int64", Type: int64Type},
+		{Name: "Float64", Type: &pb.Type{Code: pb.TypeCode_FLOAT64}},
+		{Name: "Numeric", Type: &pb.Type{Code: pb.TypeCode_NUMERIC}},
+		{Name: "String", Type: stringType},
+		{Name: "Json", Type: &pb.Type{Code: pb.TypeCode_JSON}},
+		{Name: "Bytes", Type: &pb.Type{Code: pb.TypeCode_BYTES}},
+		{Name: "Date", Type: &pb.Type{Code: pb.TypeCode_DATE}},
+		{Name: "Timestamp", Type: &pb.Type{Code: pb.TypeCode_TIMESTAMP}},
+		{Name: "Int64Array", Type: &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: int64Type}},
+		{Name: "StringArray", Type: &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: stringType}},
+	}
+	list := func(values ...*structpb.Value) *structpb.Value {
+		return structpb.NewListValue(&structpb.ListValue{Values: values})
+	}
+	null := structpb.NewNullValue()
+	rows := [][]*structpb.Value{
+		{
+			structpb.NewBoolValue(true),
+			structpb.NewStringValue("-9223372036854775808"),
+			structpb.NewNumberValue(1.5),
+			structpb.NewStringValue("-12345678901234567890123456789.123456789"),
+			structpb.NewStringValue("foo"),
+			structpb.NewStringValue(`{"a":[1,2]}`),
+			structpb.NewStringValue("AAEC/w=="),
+			structpb.NewStringValue("1969-12-31"),
+			structpb.NewStringValue("2020-01-02T03:04:05.123456Z"),
+			list(structpb.NewStringValue("1"), null, structpb.NewStringValue("3")),
+			list(structpb.NewStringValue(""), structpb.NewStringValue("bar")),
+		},
+		{null, null, null, null, null, null, null, null, null, null, null},
+		{
+			structpb.NewBoolValue(false),
+			structpb.NewStringValue("0"),
+			floatValue(math.Inf(-1)),
+			structpb.NewStringValue("0.000000001"),
+			structpb.NewStringValue(""),
+			structpb.NewStringValue("null"),
+			structpb.NewStringValue(""),
+			structpb.NewStringValue("9999-12-31"),
+			structpb.NewStringValue("1970-01-01T00:00:00Z"),
+			list(),
+			list(null),
+		},
+	}
+
+	for _, tt := range []struct {
+		desc      string
+		newWriter func(out io.Writer) resultWriter
+		newReader func(b []byte) (loadReader, error)
+	}{
+		{
+			desc:      "parquet",
+			newWriter: func(out io.Writer) resultWriter { return newParquetWriter(out) },
+			newReader: func(b []byte) (loadReader, error) {
+				return newParquetLoadReader(bytes.NewReader(b), int64(len(b)))
+			},
+		},
+		{
+			desc: "parquet with a row group per row",
+			newWriter: func(out io.Writer) resultWriter {
+				w := newParquetWriter(out)
+				w.rowGroupBytes = 1
+				return w
+			},
+			newReader: func(b []byte) (loadReader, error) {
+				return newParquetLoadReader(bytes.NewReader(b), int64(len(b)))
+			},
+		},
+		{
+			desc:      "avro",
+			newWriter: func(out io.Writer) resultWriter { return &avroWriter{out: out} },
+			newReader: func(b []byte) (loadReader, error) {
+				return newAvroLoadReader(bytes.NewReader(b))
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := tt.newWriter(&buf)
+			var columnNames []string
+			var columnTypes []*pb.StructType_Field
+			for _, column := range columns {
+				columnNames = append(columnNames, column.Name)
+				columnTypes = append(columnTypes, &pb.StructType_Field{Name: column.Name, Type: column.Type})
+			}
+			if err := w.WriteHeader(columnNames, columnTypes); err != nil {
+				t.Fatalf("WriteHeader() got error: %v", err)
+			}
+			for _, row := range rows {
+				var values []spanner.GenericColumnValue
+				for i, v := range row {
+					values = append(values, spanner.GenericColumnValue{Type: columns[i].Type, Value: v})
+				}
+				if err := w.WriteRow(Row{Values: values}); err != nil {
+					t.Fatalf("WriteRow() got error: %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() got error: %v", err)
+			}
+
+			reader, err := tt.newReader(buf.Bytes())
+			if err != nil {
+				t.Fatalf("failed to create a reader: %v", err)
+			}
+			var got [][]*structpb.Value
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read() got error: %v", err)
+				}
+				if record.Err != nil {
+					t.Fatalf("Read() got invalid record: %v", record.Err)
+				}
+				if want := len(got) + 1; record.Line != want {
+					t.Errorf("line mismatch: got = %d, want = %d", record.Line, want)
+				}
+				var values []*structpb.Value
+				for _, column := range columns {
+					v, err := loadValue(column.Type, record.Values[column.Name])
+					if err != nil {
+						t.Fatalf("column %q: loadValue() got error: %v", column.Name, err)
+					}
+					values = append(values, v)
+				}
+				got = append(got, values)
+			}
+			if diff := cmp.Diff(rows, got, protocmp.Transform()); diff != "" {
+				t.Errorf("rows mismatch (-want +got):\n%s", diff)
+			}
+
+			// Skip to the last row, which is the resumption of LOAD DATA.
+			reader, err = tt.newReader(buf.Bytes())
+			if err != nil {
+				t.Fatalf("failed to create a reader: %v", err)
+			}
+			if err := reader.Skip(0, 3); err != nil {
+				t.Fatalf("Skip() got error: %v", err)
+			}
+			record, err := reader.Read()
+			if err != nil {
+				t.Fatalf("Read() got error: %v", err)
+			}
+			if record.Line != 3 {
+				t.Errorf("line after Skip() mismatch: got = %d, want = 3", record.Line)
+			}
+		})
+	}
+}
+
+func TestExportQueryInterrupted(t *testing.T) {
+	session := newLoadTestSession(t, "CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)")
+	_, err := session.client.Apply(context.Background(), []*spanner.Mutation{
+		spanner.Insert("Singers", []string{"Id", "Name"}, []interface{}{1, "foo"}),
+	})
+	if err != nil {
+		t.Fatalf("failed to insert rows: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "singers.parquet")
+	stmt := &ExportQueryStatement{Query: "SELECT Id, Name FROM Singers", Path: path}
+	if _, err := stmt.Execute(context.Background(), session); err != nil {
+		t.Fatalf("Execute() got error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("exported file should exist: %v", err)
+	}
+
+	// A canceled context is the same as Ctrl-C.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := stmt.Execute(ctx, session); !errors.Is(err, context.Canceled) {
+		t.Errorf("Execute() should fail with context.Canceled, but got: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("truncated file should be removed, but got: %v", err)
+	}
+}
+
+func TestPartitionedExportStaleness(t *testing.T) {
+	session := newLoadTestSession(t, "CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)")
+	for _, value := range []string{"MAX_STALENESS 10s", "MIN_READ_TIMESTAMP 2024-01-01T00:00:00Z"} {
+		staleness, err := parseReadOnlyStaleness(value)
+		if err != nil {
+			t.Fatalf("parseReadOnlyStaleness(%q) got error: %v", value, err)
+		}
+		session.systemVariables.ReadOnlyStaleness = staleness
+
+		path := filepath.Join(t.TempDir(), "singers.csv")
+		stmt := &PartitionedExportStatement{Query: "SELECT Id, Name FROM Singers", Path: path}
+		_, err = stmt.Execute(context.Background(), session)
+		if err == nil || !strings.Contains(err.Error(), "is not supported by partitioned export") {
+			t.Errorf("%s: Execute() should fail with unsupported staleness, but got: %v", value, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s: file should not be created, but got: %v", value, err)
+		}
+	}
+}
+
+func TestPartitionFilePath(t *testing.T) {
+	for _, tt := range []struct {
+		path string
+		i    int
+		want string
+	}{
+		{path: "/tmp/singers.parquet", i: 0, want: "/tmp/singers-00000.parquet"},
+		{path: "singers.avro", i: 12, want: "singers-00012.avro"},
+		{path: "/tmp/singers", i: 1, want: "/tmp/singers-00001"},
+	} {
+		if got := partitionFilePath(tt.path, tt.i); got != tt.want {
+			t.Errorf("partitionFilePath(%q, %d) = %q, but want = %q", tt.path, tt.i, got, tt.want)
+		}
+	}
+}
@@ -86,7 +86,7 @@ func setup(t *testing.T, ctx context.Context, dmls []string) (*Session, string,
 	if testCredential != "" {
 		options = append(options, option.WithCredentialsJSON([]byte(testCredential)))
 	}
-	session, err := NewSession(testProjectId, testInstanceId, testDatabaseId, pb.RequestOptions_PRIORITY_UNSPECIFIED, "", nil, options...)
+	session, err := NewSession(testProjectId, testInstanceId, testDatabaseId, &systemVariables{}, "", options...)
 	if err != nil {
 		t.Fatalf("failed to create test session: err=%s", err)
 	}
@@ -149,6 +149,14 @@ func compareResult(t *testing.T, got *Result, expected *Result) {
 		cmpopts.IgnoreFields(Result{}, "Timestamp"),
 		// Commit Stats is only provided by real instances
 		cmpopts.IgnoreFields(Result{}, "CommitStats"),
+		// Compare rows by their rendered values, so that expected rows can be written as strings
+		cmp.Transformer("formatRow", func(row Row) []string {
+			columns, err := formatRow(row, DecodeColumn)
+			if err != nil {
+				t.Fatalf("failed to format row: %v", err)
+			}
+			return columns
+		}),
 		protocmp.Transform(),
 	}
 	if !cmp.Equal(got, expected, opts...) {
@@ -182,8 +190,8 @@ func TestSelect(t *testing.T) {
 	compareResult(t, result, &Result{
 		ColumnNames: []string{"id", "active"},
 		Rows: []Row{
-			Row{[]string{"1", "true"}},
-			Row{[]string{"2", "false"}},
+			stringRow("1", "true"),
+			stringRow("2", "false"),
 		},
 		AffectedRows: 2,
 		ColumnTypes: []*pb.StructType_Field{
@@ -485,8 +493,8 @@ func TestReadOnlyTransaction(t *testing.T) {
 		compareResult(t, result, &Result{
 			ColumnNames: []string{"id", "active"},
 			Rows: []Row{
-				Row{[]string{"1", "true"}},
-				Row{[]string{"2", "false"}},
+				stringRow("1", "true"),
+				stringRow("2", "false"),
 			},
 
 			ColumnTypes: []*pb.StructType_Field{
@@ -561,8 +569,8 @@ func TestReadOnlyTransaction(t *testing.T) {
 		compareResult(t, result, &Result{
 			ColumnNames: []string{"id", "active"},
 			Rows: []Row{
-				Row{[]string{"1", "true"}},
-				Row{[]string{"2", "false"}},
+				stringRow("1", "true"),
+				stringRow("2", "false"),
 			},
 			ColumnTypes: []*pb.StructType_Field{
 				{Name: "id", Type: &pb.Type{Code: pb.TypeCode_INT64}},
@@ -609,13 +617,48 @@ func TestShowCreateTable(t *testing.T) {
 	compareResult(t, result, &Result{
 		ColumnNames: []string{"Table", "Create Table"},
 		Rows: []Row{
-			Row{[]string{tableId, fmt.Sprintf("CREATE TABLE %s (\n  id INT64 NOT NULL,\n  active BOOL NOT NULL,\n) PRIMARY KEY(id)", tableId)}},
+			stringRow(tableId, fmt.Sprintf("CREATE TABLE %s (\n  id INT64 NOT NULL,\n  active BOOL NOT NULL,\n) PRIMARY KEY(id)", tableId)),
 		},
 		AffectedRows: 1,
 		IsMutation:   false,
 	})
 }
 
+func TestDumpTable(t *testing.T) {
+	if skipIntegrateTest {
+		t.Skip("Integration tests skipped")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	session, tableId, tearDown := setup(t, ctx, []string{
+		"INSERT INTO [[TABLE]] (id, active) VALUES (1, true), (2, false)",
+	})
+	defer tearDown()
+
+	stmt, err := BuildStatement(fmt.Sprintf("DUMP TABLE %s", tableId))
+	if err != nil {
+		t.Fatalf("invalid statement: error=%s", err)
+	}
+
+	result, err := stmt.Execute(ctx, session)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %s", err)
+	}
+
+	compareResult(t, result, &Result{
+		ColumnNames: []string{"Statement"},
+		Rows: []Row{
+			stringRow("START BATCH DDL"),
+			stringRow(fmt.Sprintf("CREATE TABLE %s (\n  id INT64 NOT NULL,\n  active BOOL NOT NULL,\n) PRIMARY KEY(id)", tableId)),
+			stringRow("RUN BATCH"),
+			stringRow(fmt.Sprintf("INSERT INTO %s (id, active) VALUES\n  (1, TRUE),\n  (2, FALSE)", tableId)),
+		},
+		AffectedRows: 2,
+	})
+}
+
 func TestShowColumns(t *testing.T) {
 	if skipIntegrateTest {
 		t.Skip("Integration tests skipped")
@@ -640,8 +683,8 @@ func TestShowColumns(t *testing.T) {
 	compareResult(t, result, &Result{
 		ColumnNames: []string{"Field", "Type", "NULL", "Key", "Key_Order", "Options"},
 		Rows: []Row{
-			Row{[]string{"id", "INT64", "NO", "PRIMARY_KEY", "ASC", "NULL"}},
-			Row{[]string{"active", "BOOL", "NO", "NULL", "NULL", "NULL"}},
+			stringRow("id", "INT64", "NO", "PRIMARY_KEY", "ASC", "NULL"),
+			stringRow("active", "BOOL", "NO", "NULL", "NULL", "NULL"),
 		},
 		AffectedRows: 2,
 		IsMutation:   false,
@@ -672,7 +715,7 @@ func TestShowIndexes(t *testing.T) {
 	compareResult(t, result, &Result{
 		ColumnNames: []string{"Table", "Parent_table", "Index_name", "Index_type", "Is_unique", "Is_null_filtered", "Index_state"},
 		Rows: []Row{
-			Row{[]string{tableId, "", "PRIMARY_KEY", "PRIMARY_KEY", "true", "false", "NULL"}},
+			stringRow(tableId, "", "PRIMARY_KEY", "PRIMARY_KEY", "true", "false", "NULL"),
 		},
 		AffectedRows: 1,
 		IsMutation:   false,
@@ -0,0 +1,893 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// loadBatchBytesLimit keeps a commit well under the limit of the commit size (100 MB).
+	loadBatchBytesLimit = 32 << 20
+
+	// loadMaxWarnings is the maximum number of rejected rows reported individually.
+	loadMaxWarnings = 100
+)
+
+// loadMutations are the functions to create mutations for each mode of LOAD DATA.
+var loadMutations = map[string]func(table string, in map[string]interface{}) *spanner.Mutation{
+	"INSERT":           spanner.InsertMap,
+	"INSERT_OR_UPDATE": spanner.InsertOrUpdateMap,
+	"REPLACE":          spanner.ReplaceMap,
+}
+
+// loadRetryMutations are the functions to write a batch which may have been committed by the previous run.
+// Modes which are not listed are idempotent.
+var loadRetryMutations = map[string]func(table string, in map[string]interface{}) *spanner.Mutation{
+	"INSERT": spanner.InsertOrUpdateMap,
+}
+
+// LoadDataStatement loads rows from a CSV or JSON Lines file into a table by mutations.
+type LoadDataStatement struct {
+	Path   string
+	Schema string
+	Table  string
+	Format string // CSV or JSONL, or empty to infer it from the file extension
+	Mode   string // INSERT, INSERT_OR_UPDATE or REPLACE
+}
+
+// loadColumn is a column of the table to be loaded.
+type loadColumn struct {
+	Name      string
+	Type      *sppb.Type
+	Generated bool
+}
+
+// loadRecord is a row read from the file.
+type loadRecord struct {
+	Line     int                    // line number where the record starts
+	NextLine int                    // line number just after the record
+	Offset   int64                  // byte offset just after the record
+	Values   map[string]interface{} // values keyed by column names, nil means NULL
+	Err      error                  // error which rejects the record
+}
+
+// loadReader reads records from the file. It returns io.EOF after the last record.
+type loadReader interface {
+	Read() (*loadRecord, error)
+	// Skip skips to the byte offset and the line number recorded by a checkpoint.
+	Skip(offset int64, line int) error
+	// Position returns the position of the next record, which is after the header if any.
+	Position() loadPosition
+}
+
+// loadCheckpoint is the position of the last committed batch of LOAD DATA, which is recorded in a file
+// so that a failed load can be resumed by `--resume` without duplicating or skipping rows.
+type loadCheckpoint struct {
+	Table           string    `json:"table"`
+	FileSize        int64     `json:"file_size"`
+	FileModTime     time.Time `json:"file_mod_time"`
+	Offset          int64     `json:"offset"`
+	Line            int       `json:"line"`
+	Loaded          int       `json:"loaded"`
+	CommitTimestamp time.Time `json:"commit_timestamp,omitempty"`
+	Completed       bool      `json:"completed"`
+
+	// Pending is the end of the batch being committed after Offset and Line. The batch may have been committed
+	// even if the commit failed, for example, when the response was lost.
+	Pending *loadPosition `json:"pending,omitempty"`
+}
+
+// loadPosition is the position just after a record, which is also the start of the next record.
+type loadPosition struct {
+	Offset int64 `json:"offset"`
+	Line   int   `json:"line"`
+}
+
+// loadCheckpointPath returns the path of the checkpoint file, which is next to the loaded file.
+func loadCheckpointPath(path string) string {
+	return path + ".checkpoint"
+}
+
+// readLoadCheckpoint reads the checkpoint file. It returns nil if the file doesn't exist.
+func readLoadCheckpoint(path string) (*loadCheckpoint, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint loadCheckpoint
+	if err := json.Unmarshal(b, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %w", path, err)
+	}
+	return &checkpoint, nil
+}
+
+// writeLoadCheckpoint replaces the checkpoint file atomically, so that it is not broken by an interruption.
+func writeLoadCheckpoint(path string, checkpoint *loadCheckpoint) error {
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	return nil
+}
+
+func (s *LoadDataStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if session.InReadWriteTransaction() {
+		return nil, errors.New(`"LOAD DATA" can not be used in a read-write transaction`)
+	}
+
+	columns, indexes, err := loadTableColumns(ctx, session, s.Schema, s.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := newLoadReader(f, s.format(), columns, session.systemVariables)
+	if err != nil {
+		return nil, err
+	}
+
+	table := s.Table
+	if s.Schema != "" {
+		table = s.Schema + "." + s.Table
+	}
+	loader := &tableLoader{
+		session:        session,
+		table:          table,
+		columns:        columns,
+		mutation:       loadMutations[s.Mode],
+		retryMutation:  loadRetryMutations[s.Mode],
+		rowsPerBatch:   max(1, mutationLimit/(len(columns)*(1+indexes))),
+		bytesLimit:     loadBatchBytesLimit,
+		size:           info.Size(),
+		priority:       session.systemVariables.RPCPriority,
+		checkpointPath: loadCheckpointPath(s.Path),
+		checkpoint:     &loadCheckpoint{Table: table, FileSize: info.Size(), FileModTime: info.ModTime()},
+	}
+
+	if session.systemVariables.LoadResume {
+		checkpoint, err := readLoadCheckpoint(loader.checkpointPath)
+		if err != nil {
+			return nil, err
+		}
+		if checkpoint != nil {
+			if err := loader.resume(reader, checkpoint); err != nil {
+				return nil, err
+			}
+			if checkpoint.Completed {
+				return &Result{IsMutation: true, Warnings: loader.warnings}, nil
+			}
+		}
+	}
+	return loader.load(ctx, reader)
+}
+
+// format returns the format given by FORMAT clause, or inferred from the file extension.
+func (s *LoadDataStatement) format() string {
+	if s.Format != "" {
+		return strings.ToUpper(s.Format)
+	}
+	switch strings.ToLower(filepath.Ext(s.Path)) {
+	case ".jsonl", ".ndjson":
+		return "JSONL"
+	case ".parquet":
+		return "PARQUET"
+	case ".avro":
+		return "AVRO"
+	default:
+		return "CSV"
+	}
+}
+
+// loadTableColumns reads the columns and the number of secondary indexes of the table from INFORMATION_SCHEMA.
+func loadTableColumns(ctx context.Context, session *Session, schema, table string) ([]*loadColumn, int, error) {
+	txn := session.client.ReadOnlyTransaction()
+	defer txn.Close()
+
+	var columns []*loadColumn
+	err := txn.Query(ctx, spanner.Statement{
+		SQL: "SELECT COLUMN_NAME, SPANNER_TYPE, IS_GENERATED FROM INFORMATION_SCHEMA.COLUMNS " +
+			"WHERE TABLE_CATALOG = '' AND TABLE_SCHEMA = @schema AND TABLE_NAME = @table ORDER BY ORDINAL_POSITION",
+		Params: map[string]interface{}{"schema": schema, "table": table},
+	}).Do(func(row *spanner.Row) error {
+		var name, spannerType, generated string
+		if err := row.Columns(&name, &spannerType, &generated); err != nil {
+			return err
+		}
+		typ, err := parseSpannerType(spannerType)
+		if err != nil {
+			// Columns of unsupported types can't be loaded, but they don't prevent loading the other columns.
+			typ = nil
+		}
+		columns = append(columns, &loadColumn{Name: name, Type: typ, Generated: generated != "NEVER"})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(columns) == 0 {
+		return nil, 0, fmt.Errorf("table %q doesn't exist in schema %q", table, schema)
+	}
+
+	var indexes int64
+	err = txn.Query(ctx, spanner.Statement{
+		SQL: "SELECT COUNT(*) FROM INFORMATION_SCHEMA.INDEXES " +
+			"WHERE TABLE_CATALOG = '' AND TABLE_SCHEMA = @schema AND TABLE_NAME = @table AND INDEX_TYPE = 'INDEX'",
+		Params: map[string]interface{}{"schema": schema, "table": table},
+	}).Do(func(row *spanner.Row) error {
+		return row.Columns(&indexes)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return columns, int(indexes), nil
+}
+
+// parseSpannerType parses SPANNER_TYPE of INFORMATION_SCHEMA.COLUMNS such as `STRING(MAX)` or `ARRAY<INT64>`.
+func parseSpannerType(s string) (*sppb.Type, error) {
+	tokens, err := tokenizeParam(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &paramParser{tokens: tokens}
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != paramTokenEOF {
+		return nil, fmt.Errorf("unexpected %q in type", tok.text)
+	}
+	return typ, nil
+}
+
+// tableLoader writes records to the table in batches of mutations.
+type tableLoader struct {
+	session      *Session
+	table        string
+	columns      []*loadColumn
+	mutation     func(table string, in map[string]interface{}) *spanner.Mutation
+	rowsPerBatch int
+	bytesLimit   int64
+	size         int64 // size of the file to show the progress, 0 if unknown
+	priority     sppb.RequestOptions_Priority
+
+	// retryMutation writes rows of the pending batch of the checkpoint, which may have been committed. nil means mutation is used.
+	retryMutation func(table string, in map[string]interface{}) *spanner.Mutation
+
+	// The checkpoint is written to checkpointPath after each commit. Empty path disables it.
+	checkpointPath string
+	checkpoint     *loadCheckpoint
+
+	batch      []*spanner.Mutation
+	batchBytes int64
+	batchLine  int           // line number of the first record in the batch
+	retry      *loadPosition // end of the pending batch of the previous run, which is committed again by itself
+	offset     int64         // byte offset just after the last read record
+	line       int           // line number just after the last read record
+	loaded     int
+	rejected   int
+	listed     int // rejected rows listed in warnings
+	warnings   []string
+	timestamp  time.Time
+}
+
+// resume skips the rows committed before the checkpoint.
+func (l *tableLoader) resume(reader loadReader, checkpoint *loadCheckpoint) error {
+	if checkpoint.Table != l.checkpoint.Table {
+		return fmt.Errorf("checkpoint file %s is for table %q, not %q", l.checkpointPath, checkpoint.Table, l.checkpoint.Table)
+	}
+	if checkpoint.FileSize != l.checkpoint.FileSize || !checkpoint.FileModTime.Equal(l.checkpoint.FileModTime) {
+		return fmt.Errorf("file has been changed since checkpoint file %s was written", l.checkpointPath)
+	}
+	if checkpoint.Completed {
+		l.warnings = append(l.warnings, fmt.Sprintf("all rows have been loaded by the previous run (%d rows)", checkpoint.Loaded))
+		return nil
+	}
+	// A checkpoint before the first record, such as one written before the first commit, resumes from the start.
+	if start := reader.Position(); checkpoint.Line < start.Line {
+		checkpoint.Offset, checkpoint.Line = start.Offset, start.Line
+	}
+	if err := reader.Skip(checkpoint.Offset, checkpoint.Line); err != nil {
+		return err
+	}
+	l.offset, l.line = checkpoint.Offset, checkpoint.Line
+	l.checkpoint = checkpoint
+	l.warnings = append(l.warnings, fmt.Sprintf("resumed from line %d, %d rows were loaded by the previous run", checkpoint.Line, checkpoint.Loaded))
+	if checkpoint.Pending != nil {
+		l.retry = checkpoint.Pending
+		l.warnings = append(l.warnings, fmt.Sprintf("lines %d-%d may have been committed by the previous run, and are written again idempotently", checkpoint.Line, checkpoint.Pending.Line-1))
+	}
+	return nil
+}
+
+func (l *tableLoader) load(ctx context.Context, reader loadReader) (*Result, error) {
+	columns := make(map[string]*loadColumn, len(l.columns))
+	for _, column := range l.columns {
+		columns[strings.ToLower(column.Name)] = column
+	}
+
+	if l.checkpoint == nil {
+		l.checkpoint = &loadCheckpoint{Table: l.table}
+	}
+	// Loading starts after the header unless it is resumed from a checkpoint.
+	if l.line == 0 {
+		start := reader.Position()
+		l.offset, l.line = start.Offset, start.Line
+		l.checkpoint.Offset, l.checkpoint.Line = start.Offset, start.Line
+	}
+	// The checkpoint is written before loading so that an unwritable path fails before any commit.
+	if err := l.writeCheckpoint(); err != nil {
+		return nil, err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, l.failure(err)
+		}
+
+		l.batchBytes += record.Offset - l.offset
+		l.offset, l.line = record.Offset, record.NextLine
+
+		mutation, err := l.newMutation(columns, record)
+		if err != nil {
+			l.reject(record.Line, err)
+		} else {
+			if len(l.batch) == 0 {
+				l.batchLine = record.Line
+			}
+			l.batch = append(l.batch, mutation)
+		}
+		if l.batchFull() {
+			if err := l.commit(ctx); err != nil {
+				return nil, l.failure(err)
+			}
+		}
+	}
+	if err := l.commit(ctx); err != nil {
+		return nil, l.failure(err)
+	}
+	l.checkpoint.Completed = true
+	if err := l.writeCheckpoint(); err != nil {
+		return nil, err
+	}
+
+	if l.rejected > l.listed {
+		l.warnings = append(l.warnings, fmt.Sprintf("%d more rows were rejected", l.rejected-l.listed))
+	}
+	return &Result{
+		IsMutation:   true,
+		AffectedRows: l.loaded,
+		Timestamp:    l.timestamp,
+		Warnings:     l.warnings,
+	}, nil
+}
+
+func (l *tableLoader) newMutation(columns map[string]*loadColumn, record *loadRecord) (*spanner.Mutation, error) {
+	if record.Err != nil {
+		return nil, record.Err
+	}
+	values := make(map[string]interface{}, len(record.Values))
+	for name, v := range record.Values {
+		column, ok := columns[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("column %q doesn't exist in table %q", name, l.table)
+		}
+		if column.Generated {
+			return nil, fmt.Errorf("generated column %q can not be written", column.Name)
+		}
+		if column.Type == nil {
+			return nil, fmt.Errorf("type of column %q is not supported", column.Name)
+		}
+		value, err := loadValue(column.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", column.Name, err)
+		}
+		values[column.Name] = spanner.GenericColumnValue{Type: column.Type, Value: value}
+	}
+	if l.retry != nil && l.retryMutation != nil {
+		return l.retryMutation(l.table, values), nil
+	}
+	return l.mutation(l.table, values), nil
+}
+
+// batchFull reports whether the batch should be committed.
+// The pending batch of the previous run ends at the same line, so that the following rows are written by the mode.
+func (l *tableLoader) batchFull() bool {
+	if l.retry != nil && l.line >= l.retry.Line {
+		return true
+	}
+	return len(l.batch) > 0 && (len(l.batch) >= l.rowsPerBatch || l.batchBytes >= l.bytesLimit)
+}
+
+func (l *tableLoader) reject(line int, err error) {
+	l.rejected++
+	if l.listed < loadMaxWarnings {
+		l.listed++
+		l.warnings = append(l.warnings, fmt.Sprintf("line %d: %v", line, err))
+	}
+}
+
+// commit applies the mutations in the batch in a transaction.
+func (l *tableLoader) commit(ctx context.Context) error {
+	if l.retry != nil && l.line >= l.retry.Line {
+		l.retry = nil
+		l.checkpoint.Pending = nil
+	}
+	if len(l.batch) == 0 {
+		return nil
+	}
+	// The end of the batch is recorded before the commit, because the batch may be committed even if the commit fails.
+	// While the pending batch of the previous run is written again, its end is kept because rows after the batch may have been committed.
+	l.checkpoint.Pending = &loadPosition{Offset: l.offset, Line: l.line}
+	if l.retry != nil {
+		l.checkpoint.Pending = l.retry
+	}
+	if err := l.writeCheckpoint(); err != nil {
+		return err
+	}
+	ts, err := l.session.client.Apply(ctx, l.batch, spanner.Priority(l.priority))
+	if err != nil {
+		return err
+	}
+	l.loaded += len(l.batch)
+	l.timestamp = ts
+	l.checkpoint.Offset, l.checkpoint.Line = l.offset, l.line
+	l.checkpoint.Loaded += len(l.batch)
+	l.checkpoint.CommitTimestamp = ts
+	l.checkpoint.Pending = nil
+	l.batch, l.batchBytes = nil, 0
+
+	if err := l.writeCheckpoint(); err != nil {
+		return err
+	}
+
+	progress := fmt.Sprintf("%d rows loaded", l.loaded)
+	if l.size > 0 {
+		progress = fmt.Sprintf("%3d%% %s", l.offset*100/l.size, progress)
+	}
+	l.session.SetProgress(progress)
+	return nil
+}
+
+func (l *tableLoader) writeCheckpoint() error {
+	if l.checkpointPath == "" {
+		return nil
+	}
+	return writeLoadCheckpoint(l.checkpointPath, l.checkpoint)
+}
+
+// failure describes how far rows were loaded, because committed batches are not rolled back.
+func (l *tableLoader) failure(err error) error {
+	hint := ""
+	if l.checkpointPath != "" {
+		hint = ", run again with --resume to continue from the last committed batch"
+	}
+	if len(l.batch) > 0 {
+		return fmt.Errorf("failed to load rows from line %d: %w (%d rows were loaded before the failure%s)", l.batchLine, err, l.loaded, hint)
+	}
+	return fmt.Errorf("%w (%d rows were loaded before the failure%s)", err, l.loaded, hint)
+}
+
+// loadValue converts a value read from the file into the wire format of the type.
+// A string is parsed in the same format as the output of the other statements, for example, BYTES is base64-encoded,
+// and ARRAY is a JSON array. A JSON value of JSON Lines is given as json.RawMessage.
+// Values of Parquet and Avro are given as int64, float64, *big.Rat, time.Time, []byte or []interface{}.
+func loadValue(typ *sppb.Type, v interface{}) (*structpb.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return structpb.NewNullValue(), nil
+	case json.RawMessage:
+		return loadJSONValue(typ, v)
+	case string:
+		switch typ.GetCode() {
+		case sppb.TypeCode_ARRAY:
+			return loadJSONValue(typ, json.RawMessage(v))
+		case sppb.TypeCode_BYTES:
+			if _, err := base64.StdEncoding.DecodeString(v); err != nil {
+				return nil, fmt.Errorf("invalid BYTES value, it must be base64-encoded: %q", v)
+			}
+			return structpb.NewStringValue(v), nil
+		}
+		return parseScalarParamValue(v, typ.GetCode())
+	case json.Number:
+		return parseScalarParamValue(v.String(), typ.GetCode())
+	case bool:
+		if typ.GetCode() != sppb.TypeCode_BOOL {
+			return nil, fmt.Errorf("invalid %s value: %v", typ.GetCode(), v)
+		}
+		return structpb.NewBoolValue(v), nil
+	case int64:
+		return loadValue(typ, json.Number(strconv.FormatInt(v, 10)))
+	case float64:
+		return loadValue(typ, json.Number(strconv.FormatFloat(v, 'g', -1, 64)))
+	case *big.Rat:
+		return loadValue(typ, json.Number(v.FloatString(numericScale)))
+	case time.Time:
+		if typ.GetCode() == sppb.TypeCode_DATE {
+			return structpb.NewStringValue(civil.DateOf(v).String()), nil
+		}
+		return parseScalarParamValue(v.Format(time.RFC3339Nano), typ.GetCode())
+	case []byte:
+		if typ.GetCode() == sppb.TypeCode_BYTES {
+			return structpb.NewStringValue(base64.StdEncoding.EncodeToString(v)), nil
+		}
+		return parseScalarParamValue(string(v), typ.GetCode())
+	case []interface{}:
+		if typ.GetCode() != sppb.TypeCode_ARRAY {
+			return nil, fmt.Errorf("invalid %s value: %v", typ.GetCode(), v)
+		}
+		values := make([]*structpb.Value, len(v))
+		for i, elem := range v {
+			value, err := loadValue(typ.GetArrayElementType(), elem)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values}), nil
+	default:
+		return nil, fmt.Errorf("invalid %s value: %v", typ.GetCode(), v)
+	}
+}
+
+// loadJSONValue converts a JSON value. A value of JSON type is kept as is instead of being decoded.
+func loadJSONValue(typ *sppb.Type, raw json.RawMessage) (*structpb.Value, error) {
+	if string(bytes.TrimSpace(raw)) == "null" {
+		return structpb.NewNullValue(), nil
+	}
+
+	switch typ.GetCode() {
+	case sppb.TypeCode_JSON:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON value: %q", raw)
+		}
+		return structpb.NewStringValue(buf.String()), nil
+	case sppb.TypeCode_ARRAY:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return nil, fmt.Errorf("invalid ARRAY value: %q", raw)
+		}
+		values := make([]*structpb.Value, len(elems))
+		for i, elem := range elems {
+			value, err := loadJSONValue(typ.GetArrayElementType(), elem)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values}), nil
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		var v interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid %s value: %q", typ.GetCode(), raw)
+		}
+		return loadValue(typ, v)
+	}
+}
+
+// newLoadReader creates a reader of the format.
+// CSV is read with CLI_CSV_* system variables in the same way as CSV output.
+func newLoadReader(r io.Reader, format string, columns []*loadColumn, sysVars *systemVariables) (loadReader, error) {
+	switch format {
+	case "JSONL":
+		return &jsonlLoadReader{r: bufio.NewReader(r), line: 1}, nil
+	case "AVRO":
+		return newAvroLoadReader(bufio.NewReader(r))
+	case "PARQUET":
+		// The footer of Parquet files is at the end.
+		f, ok := r.(interface {
+			io.ReaderAt
+			io.Seeker
+		})
+		if !ok {
+			return nil, errors.New("PARQUET format requires a seekable file")
+		}
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		return newParquetLoadReader(f, size)
+	default:
+		reader := newCSVRecordReader(r, sysVars)
+		var header []string
+		if sysVars.CSVHeader {
+			fields, _, err := reader.Read()
+			if err == io.EOF {
+				return &csvLoadReader{r: reader}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, field := range fields {
+				header = append(header, field.Value)
+			}
+		} else {
+			// Without the header, fields are the columns in the order of the table.
+			for _, column := range columns {
+				if !column.Generated {
+					header = append(header, column.Name)
+				}
+			}
+		}
+		return &csvLoadReader{r: reader, header: header, null: sysVars.CSVNull}, nil
+	}
+}
+
+type csvLoadReader struct {
+	r      *csvRecordReader
+	header []string
+	null   string
+}
+
+func (r *csvLoadReader) Read() (*loadRecord, error) {
+	fields, line, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	record := &loadRecord{Line: line, NextLine: r.r.line, Offset: r.r.offset}
+	if len(fields) != len(r.header) {
+		record.Err = fmt.Errorf("%d fields, but the header has %d columns", len(fields), len(r.header))
+		return record, nil
+	}
+	record.Values = make(map[string]interface{}, len(fields))
+	for i, field := range fields {
+		// An unquoted value same as the NULL representation is NULL.
+		if !field.Quoted && field.Value == r.null {
+			record.Values[r.header[i]] = nil
+			continue
+		}
+		record.Values[r.header[i]] = field.Value
+	}
+	return record, nil
+}
+
+func (r *csvLoadReader) Skip(offset int64, line int) error {
+	return r.r.Skip(offset, line)
+}
+
+func (r *csvLoadReader) Position() loadPosition {
+	return loadPosition{Offset: r.r.offset, Line: r.r.line}
+}
+
+type jsonlLoadReader struct {
+	r      *bufio.Reader
+	line   int
+	offset int64
+}
+
+func (r *jsonlLoadReader) Read() (*loadRecord, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		record := &loadRecord{Line: r.line}
+		r.line++
+		r.offset += int64(len(line))
+		record.NextLine, record.Offset = r.line, r.offset
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		// Values are decoded by their column types.
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(line, &values); err != nil || values == nil {
+			record.Err = fmt.Errorf("invalid JSON object: %q", bytes.TrimSpace(line))
+			return record, nil
+		}
+		record.Values = make(map[string]interface{}, len(values))
+		for name, value := range values {
+			record.Values[name] = value
+		}
+		return record, nil
+	}
+}
+
+func (r *jsonlLoadReader) Skip(offset int64, line int) error {
+	if err := skipLoadReader(r.r, r.offset, offset); err != nil {
+		return err
+	}
+	r.offset, r.line = offset, line
+	return nil
+}
+
+func (r *jsonlLoadReader) Position() loadPosition {
+	return loadPosition{Offset: r.offset, Line: r.line}
+}
+
+// skipLoadReader discards bytes from the current offset to the offset.
+func skipLoadReader(r *bufio.Reader, current, offset int64) error {
+	if offset < current {
+		return fmt.Errorf("can't skip back to offset %d from %d", offset, current)
+	}
+	if _, err := r.Discard(int(offset - current)); err != nil {
+		return fmt.Errorf("failed to skip to offset %d: %w", offset, err)
+	}
+	return nil
+}
+
+// csvField is a field of CSV with whether it is quoted, to distinguish an empty string from NULL.
+type csvField struct {
+	Value  string
+	Quoted bool
+}
+
+// csvRecordReader reads RFC 4180 CSV with the line numbers and the byte offsets of records.
+type csvRecordReader struct {
+	r         *bufio.Reader
+	delimiter rune
+	quote     rune
+	line      int   // line number of the next character
+	offset    int64 // byte offset of the next character
+}
+
+func newCSVRecordReader(r io.Reader, sysVars *systemVariables) *csvRecordReader {
+	reader := &csvRecordReader{r: bufio.NewReader(r), delimiter: sysVars.CSVDelimiter, quote: sysVars.CSVQuote, line: 1}
+	if reader.delimiter == 0 {
+		reader.delimiter = defaultCSVDelimiter
+	}
+	if reader.quote == 0 {
+		reader.quote = defaultCSVQuote
+	}
+	return reader
+}
+
+func (r *csvRecordReader) readRune() (rune, error) {
+	c, size, err := r.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	r.offset += int64(size)
+	if c == '\n' {
+		r.line++
+	}
+	return c, nil
+}
+
+// Skip skips to the offset, which must be the start of a record, and sets the line number there.
+func (r *csvRecordReader) Skip(offset int64, line int) error {
+	if err := skipLoadReader(r.r, r.offset, offset); err != nil {
+		return err
+	}
+	r.offset, r.line = offset, line
+	return nil
+}
+
+// Read reads a record and returns its fields and the line number where it starts. Empty lines are skipped.
+func (r *csvRecordReader) Read() ([]csvField, int, error) {
+	var fields []csvField
+	var field csvField
+	var sb strings.Builder
+	line := r.line
+	inQuotes := false
+	started := false // whether the record has any character
+
+	for {
+		c, err := r.readRune()
+		if err == io.EOF {
+			if inQuotes {
+				return nil, line, fmt.Errorf("line %d: unclosed quote", line)
+			}
+			if !started {
+				return nil, line, io.EOF
+			}
+			field.Value = sb.String()
+			return append(fields, field), line, nil
+		}
+		if err != nil {
+			return nil, line, err
+		}
+
+		if inQuotes {
+			if c != r.quote {
+				sb.WriteRune(c)
+				continue
+			}
+			// A doubled quote is an escaped quote.
+			if next, _, err := r.r.ReadRune(); err == nil {
+				if next == r.quote {
+					r.offset += int64(len(string(next)))
+					sb.WriteRune(c)
+					continue
+				}
+				r.r.UnreadRune()
+			}
+			inQuotes = false
+			continue
+		}
+
+		switch {
+		case c == '\r':
+			// CR of CRLF is dropped.
+			if next, _, err := r.r.ReadRune(); err == nil {
+				r.r.UnreadRune()
+				if next == '\n' {
+					continue
+				}
+			}
+			started = true
+			sb.WriteRune(c)
+		case c == '\n':
+			if !started {
+				line = r.line
+				continue
+			}
+			field.Value = sb.String()
+			return append(fields, field), line, nil
+		case c == r.delimiter:
+			started = true
+			field.Value = sb.String()
+			fields = append(fields, field)
+			field = csvField{}
+			sb.Reset()
+		case c == r.quote && sb.Len() == 0 && !field.Quoted:
+			started = true
+			field.Quoted = true
+			inQuotes = true
+		default:
+			started = true
+			sb.WriteRune(c)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"cloud.google.com/go/spanner/spannertest"
+	"cloud.google.com/go/spanner/spansql"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCSVRecordReader(t *testing.T) {
+	input := "id,name\r\n1,\"a,b\"\n\n2,\"say \"\"hi\"\"\nbye\"\n3,\n4,\"\""
+	reader := newCSVRecordReader(strings.NewReader(input), &systemVariables{})
+
+	type record struct {
+		Fields []csvField
+		Line   int
+		Offset int64
+	}
+	var got []record
+	for {
+		fields, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read() got error: %v", err)
+		}
+		got = append(got, record{fields, line, reader.offset})
+	}
+
+	want := []record{
+		{Fields: []csvField{{Value: "id"}, {Value: "name"}}, Line: 1, Offset: 9},
+		{Fields: []csvField{{Value: "1"}, {Value: "a,b", Quoted: true}}, Line: 2, Offset: 17},
+		{Fields: []csvField{{Value: "2"}, {Value: "say \"hi\"\nbye", Quoted: true}}, Line: 4, Offset: 37},
+		{Fields: []csvField{{Value: "3"}, {Value: ""}}, Line: 6, Offset: 40},
+		{Fields: []csvField{{Value: "4"}, {Value: "", Quoted: true}}, Line: 7, Offset: 44},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, _, err := newCSVRecordReader(strings.NewReader(`1,"a`), &systemVariables{}).Read(); err == nil {
+		t.Errorf("Read() should fail for an unclosed quote")
+	}
+}
+
+func TestLoadValue(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		typ   *pb.Type
+		value interface{}
+		want  *structpb.Value
+	}{
+		{desc: "NULL", typ: &pb.Type{Code: pb.TypeCode_INT64}, value: nil, want: structpb.NewNullValue()},
+		{desc: "INT64 from CSV", typ: &pb.Type{Code: pb.TypeCode_INT64}, value: "42", want: structpb.NewStringValue("42")},
+		{desc: "INT64 from JSON", typ: &pb.Type{Code: pb.TypeCode_INT64}, value: json.RawMessage(`9007199254740993`), want: structpb.NewStringValue("9007199254740993")},
+		{desc: "BOOL from JSON", typ: &pb.Type{Code: pb.TypeCode_BOOL}, value: json.RawMessage(`true`), want: structpb.NewBoolValue(true)},
+		{desc: "BYTES", typ: &pb.Type{Code: pb.TypeCode_BYTES}, value: "AP8=", want: structpb.NewStringValue("AP8=")},
+		{desc: "TIMESTAMP", typ: &pb.Type{Code: pb.TypeCode_TIMESTAMP}, value: "2024-01-02T12:00:00+09:00", want: structpb.NewStringValue("2024-01-02T03:00:00Z")},
+		{desc: "JSON from CSV", typ: &pb.Type{Code: pb.TypeCode_JSON}, value: `{"a": 1}`, want: structpb.NewStringValue(`{"a": 1}`)},
+		{desc: "JSON from JSON", typ: &pb.Type{Code: pb.TypeCode_JSON}, value: json.RawMessage(`{"a": "b"}`), want: structpb.NewStringValue(`{"a":"b"}`)},
+		{desc: "JSON string from JSON", typ: &pb.Type{Code: pb.TypeCode_JSON}, value: json.RawMessage(`"b"`), want: structpb.NewStringValue(`"b"`)},
+		{
+			desc:  "ARRAY from CSV",
+			typ:   &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: &pb.Type{Code: pb.TypeCode_DATE}},
+			value: `["2024-01-02", null]`,
+			want:  structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("2024-01-02"), structpb.NewNullValue()}}),
+		},
+		{desc: "INT64 from Parquet", typ: &pb.Type{Code: pb.TypeCode_INT64}, value: int64(-1), want: structpb.NewStringValue("-1")},
+		{desc: "NUMERIC from Parquet", typ: &pb.Type{Code: pb.TypeCode_NUMERIC}, value: big.NewRat(3, 2), want: structpb.NewStringValue("1.500000000")},
+		{desc: "DATE from Avro", typ: &pb.Type{Code: pb.TypeCode_DATE}, value: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), want: structpb.NewStringValue("2024-01-02")},
+		{desc: "BYTES from Parquet", typ: &pb.Type{Code: pb.TypeCode_BYTES}, value: []byte{0, 255}, want: structpb.NewStringValue("AP8=")},
+		{
+			desc:  "ARRAY from Parquet",
+			typ:   &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: &pb.Type{Code: pb.TypeCode_FLOAT64}},
+			value: []interface{}{float64(1.5), nil},
+			want:  structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(1.5), structpb.NewNullValue()}}),
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := loadValue(tt.typ, tt.value)
+			if err != nil {
+				t.Fatalf("loadValue() got error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("loadValue() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		desc  string
+		typ   *pb.Type
+		value interface{}
+	}{
+		{desc: "invalid INT64", typ: &pb.Type{Code: pb.TypeCode_INT64}, value: "1.5"},
+		{desc: "invalid BYTES", typ: &pb.Type{Code: pb.TypeCode_BYTES}, value: "not base64"},
+		{desc: "BOOL for STRING", typ: &pb.Type{Code: pb.TypeCode_STRING}, value: json.RawMessage(`true`)},
+		{desc: "invalid ARRAY", typ: &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: &pb.Type{Code: pb.TypeCode_INT64}}, value: "[1, 2"},
+		{desc: "FLOAT64 for INT64", typ: &pb.Type{Code: pb.TypeCode_INT64}, value: float64(1.5)},
+		{desc: "list for STRING", typ: &pb.Type{Code: pb.TypeCode_STRING}, value: []interface{}{"a"}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if _, err := loadValue(tt.typ, tt.value); err == nil {
+				t.Errorf("loadValue() should fail")
+			}
+		})
+	}
+}
+
+func newLoadTestSession(t *testing.T, ddls ...string) *Session {
+	t.Helper()
+	return newLoadTestSessionWithDialOptions(t, nil, ddls...)
+}
+
+func newLoadTestSessionWithDialOptions(t *testing.T, opts []grpc.DialOption, ddls ...string) *Session {
+	t.Helper()
+	server, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("failed to run test server: %v", err)
+	}
+	t.Cleanup(server.Close)
+	for _, s := range ddls {
+		ddl, err := spansql.ParseDDL("", s)
+		if err != nil {
+			t.Fatalf("failed to parse DDL: %v", err)
+		}
+		if err := server.UpdateDDL(ddl); err != nil {
+			t.Fatalf("failed to update DDL: %v", err)
+		}
+	}
+
+	conn, err := grpc.DialContext(context.Background(), server.Addr, append(opts, grpc.WithInsecure())...)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	session, err := NewSession("project", "instance", "database", &systemVariables{}, "role", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create spanner-cli session: %v", err)
+	}
+	return session
+}
+
+func TestTableLoader(t *testing.T) {
+	ctx := context.Background()
+	session := newLoadTestSession(t, "CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)")
+
+	columns := []*loadColumn{
+		{Name: "Id", Type: &pb.Type{Code: pb.TypeCode_INT64}},
+		{Name: "Name", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+	}
+	input := "id,name\n1,foo\nx,bar\n2,\n3,\"\"\n4,baz,extra\n"
+	reader, err := newLoadReader(strings.NewReader(input), "CSV", columns, &systemVariables{CSVHeader: true})
+	if err != nil {
+		t.Fatalf("newLoadReader() got error: %v", err)
+	}
+	loader := &tableLoader{
+		session:      session,
+		table:        "Singers",
+		columns:      columns,
+		mutation:     spanner.InsertMap,
+		rowsPerBatch: 2,
+		bytesLimit:   loadBatchBytesLimit,
+	}
+	result, err := loader.load(ctx, reader)
+	if err != nil {
+		t.Fatalf("load() got error: %v", err)
+	}
+
+	if result.AffectedRows != 3 {
+		t.Errorf("affected rows mismatch: got = %d, want = 3", result.AffectedRows)
+	}
+	wantWarnings := []string{
+		`line 3: column "Id": invalid INT64 value: "x"`,
+		"line 6: 3 fields, but the header has 2 columns",
+	}
+	if diff := cmp.Diff(wantWarnings, result.Warnings); diff != "" {
+		t.Errorf("warnings mismatch (-want +got):\n%s", diff)
+	}
+
+	var got []spanner.NullString
+	err = session.client.Single().Query(ctx, spanner.NewStatement("SELECT Name FROM Singers ORDER BY Id")).Do(func(row *spanner.Row) error {
+		var name spanner.NullString
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+		got = append(got, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	want := []spanner.NullString{{StringVal: "foo", Valid: true}, {}, {StringVal: "", Valid: true}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTableLoaderLostCommitResponse(t *testing.T) {
+	ctx := context.Background()
+	// The second commit succeeds, but its response is lost.
+	var commits int32
+	interceptor := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if strings.HasSuffix(method, "/Commit") && atomic.AddInt32(&commits, 1) == 2 {
+			return status.Error(codes.DeadlineExceeded, "response is lost")
+		}
+		return err
+	}
+	session := newLoadTestSessionWithDialOptions(t, []grpc.DialOption{grpc.WithUnaryInterceptor(interceptor)},
+		"CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)")
+
+	columns := []*loadColumn{
+		{Name: "Id", Type: &pb.Type{Code: pb.TypeCode_INT64}},
+		{Name: "Name", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+	}
+	input := "1,foo\n2,bar\n3,baz\n"
+	checkpointPath := filepath.Join(t.TempDir(), "singers.csv.checkpoint")
+	newLoader := func() (*tableLoader, loadReader) {
+		reader, err := newLoadReader(strings.NewReader(input), "CSV", columns, &systemVariables{})
+		if err != nil {
+			t.Fatalf("newLoadReader() got error: %v", err)
+		}
+		return &tableLoader{
+			session:        session,
+			table:          "Singers",
+			columns:        columns,
+			mutation:       loadMutations["INSERT"],
+			retryMutation:  loadRetryMutations["INSERT"],
+			rowsPerBatch:   1,
+			bytesLimit:     loadBatchBytesLimit,
+			checkpointPath: checkpointPath,
+			checkpoint:     &loadCheckpoint{Table: "Singers"},
+		}, reader
+	}
+
+	loader, reader := newLoader()
+	if _, err := loader.load(ctx, reader); spanner.ErrCode(err) != codes.DeadlineExceeded {
+		t.Fatalf("load() should fail by the lost response, but got: %v", err)
+	}
+	checkpoint, err := readLoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatalf("readLoadCheckpoint() got error: %v", err)
+	}
+	want := &loadCheckpoint{Table: "Singers", Offset: int64(len("1,foo\n")), Line: 2, Loaded: 1, Pending: &loadPosition{Offset: int64(len("1,foo\n2,bar\n")), Line: 3}}
+	if diff := cmp.Diff(want, checkpoint, cmpopts.IgnoreFields(loadCheckpoint{}, "CommitTimestamp")); diff != "" {
+		t.Fatalf("checkpoint mismatch (-want +got):\n%s", diff)
+	}
+
+	// The pending batch has been committed, so that it is written again by INSERT_OR_UPDATE instead of failing by ALREADY_EXISTS.
+	loader, reader = newLoader()
+	if err := loader.resume(reader, checkpoint); err != nil {
+		t.Fatalf("resume() got error: %v", err)
+	}
+	result, err := loader.load(ctx, reader)
+	if err != nil {
+		t.Fatalf("load() after resume got error: %v", err)
+	}
+	wantWarnings := []string{
+		"resumed from line 2, 1 rows were loaded by the previous run",
+		"lines 2-2 may have been committed by the previous run, and are written again idempotently",
+	}
+	if diff := cmp.Diff(wantWarnings, result.Warnings); diff != "" {
+		t.Errorf("warnings mismatch (-want +got):\n%s", diff)
+	}
+
+	var gotIds []int64
+	err = session.client.Single().Query(ctx, spanner.NewStatement("SELECT Id FROM Singers ORDER BY Id")).Do(func(row *spanner.Row) error {
+		var id int64
+		if err := row.Columns(&id); err != nil {
+			return err
+		}
+		gotIds = append(gotIds, id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	if diff := cmp.Diff([]int64{1, 2, 3}, gotIds); diff != "" {
+		t.Errorf("rows mismatch (-want +got):\n%s", diff)
+	}
+
+	checkpoint, err = readLoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatalf("readLoadCheckpoint() got error: %v", err)
+	}
+	want = &loadCheckpoint{Table: "Singers", Offset: int64(len(input)), Line: 4, Loaded: 3, Completed: true}
+	if diff := cmp.Diff(want, checkpoint, cmpopts.IgnoreFields(loadCheckpoint{}, "CommitTimestamp")); diff != "" {
+		t.Errorf("checkpoint mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTableLoaderResumeAfterHeader(t *testing.T) {
+	ctx := context.Background()
+	// The first commit fails, so that the checkpoint is still at the start of the file.
+	var commits int32
+	interceptor := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if strings.HasSuffix(method, "/Commit") && atomic.AddInt32(&commits, 1) == 1 {
+			return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	session := newLoadTestSessionWithDialOptions(t, []grpc.DialOption{grpc.WithUnaryInterceptor(interceptor)},
+		"CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)")
+
+	columns := []*loadColumn{
+		{Name: "Id", Type: &pb.Type{Code: pb.TypeCode_INT64}},
+		{Name: "Name", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+	}
+	header := "Id,Name\n"
+	input := header + "1,foo\n2,bar\n"
+	checkpointPath := filepath.Join(t.TempDir(), "singers.csv.checkpoint")
+	newLoader := func() (*tableLoader, loadReader) {
+		reader, err := newLoadReader(strings.NewReader(input), "CSV", columns, &systemVariables{CSVHeader: true})
+		if err != nil {
+			t.Fatalf("newLoadReader() got error: %v", err)
+		}
+		return &tableLoader{
+			session:        session,
+			table:          "Singers",
+			columns:        columns,
+			mutation:       loadMutations["INSERT"],
+			retryMutation:  loadRetryMutations["INSERT"],
+			rowsPerBatch:   1,
+			bytesLimit:     loadBatchBytesLimit,
+			checkpointPath: checkpointPath,
+			checkpoint:     &loadCheckpoint{Table: "Singers"},
+		}, reader
+	}
+
+	loader, reader := newLoader()
+	if _, err := loader.load(ctx, reader); spanner.ErrCode(err) != codes.DeadlineExceeded {
+		t.Fatalf("load() should fail by the first commit, but got: %v", err)
+	}
+	checkpoint, err := readLoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatalf("readLoadCheckpoint() got error: %v", err)
+	}
+	want := &loadCheckpoint{Table: "Singers", Offset: int64(len(header)), Line: 2, Pending: &loadPosition{Offset: int64(len(header + "1,foo\n")), Line: 3}}
+	if diff := cmp.Diff(want, checkpoint, cmpopts.IgnoreFields(loadCheckpoint{}, "CommitTimestamp")); diff != "" {
+		t.Fatalf("checkpoint mismatch (-want +got):\n%s", diff)
+	}
+
+	loader, reader = newLoader()
+	if err := loader.resume(reader, checkpoint); err != nil {
+		t.Fatalf("resume() got error: %v", err)
+	}
+	result, err := loader.load(ctx, reader)
+	if err != nil {
+		t.Fatalf("load() after resume got error: %v", err)
+	}
+	wantWarnings := []string{
+		"resumed from line 2, 0 rows were loaded by the previous run",
+		"lines 2-2 may have been committed by the previous run, and are written again idempotently",
+	}
+	if diff := cmp.Diff(wantWarnings, result.Warnings); diff != "" {
+		t.Errorf("warnings mismatch (-want +got):\n%s", diff)
+	}
+	if result.AffectedRows != 2 {
+		t.Errorf("load() after resume loaded %d rows, but want 2", result.AffectedRows)
+	}
+}
+
+func TestTableLoaderRejectedAfterResume(t *testing.T) {
+	ctx := context.Background()
+	session := newLoadTestSession(t, "CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)")
+
+	columns := []*loadColumn{
+		{Name: "Id", Type: &pb.Type{Code: pb.TypeCode_INT64}},
+		{Name: "Name", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+	}
+	input := "1,foo\n" + strings.Repeat("x,bar\n", loadMaxWarnings+2)
+	reader, err := newLoadReader(strings.NewReader(input), "CSV", columns, &systemVariables{})
+	if err != nil {
+		t.Fatalf("newLoadReader() got error: %v", err)
+	}
+	loader := &tableLoader{
+		session:      session,
+		table:        "Singers",
+		columns:      columns,
+		mutation:     spanner.InsertMap,
+		rowsPerBatch: 1,
+		bytesLimit:   loadBatchBytesLimit,
+		checkpoint:   &loadCheckpoint{Table: "Singers"},
+	}
+	if err := loader.resume(reader, &loadCheckpoint{Table: "Singers", Offset: int64(len("1,foo\n")), Line: 2, Loaded: 1}); err != nil {
+		t.Fatalf("resume() got error: %v", err)
+	}
+	result, err := loader.load(ctx, reader)
+	if err != nil {
+		t.Fatalf("load() got error: %v", err)
+	}
+
+	// The warning of the resumption doesn't take a place of the rejected rows.
+	if got, want := len(result.Warnings), 1+loadMaxWarnings+1; got != want {
+		t.Fatalf("number of warnings mismatch: got = %d, want = %d", got, want)
+	}
+	if got, want := result.Warnings[loadMaxWarnings], fmt.Sprintf(`line %d: column "Id": invalid INT64 value: "x"`, loadMaxWarnings+1); got != want {
+		t.Errorf("last listed row mismatch: got = %q, want = %q", got, want)
+	}
+	if got, want := result.Warnings[len(result.Warnings)-1], "2 more rows were rejected"; got != want {
+		t.Errorf("summary mismatch: got = %q, want = %q", got, want)
+	}
+}
+
+func TestTableLoaderCheckpoint(t *testing.T) {
+	columns := []*loadColumn{
+		{Name: "Id", Type: &pb.Type{Code: pb.TypeCode_INT64}},
+		{Name: "Name", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+	}
+	input := "{\"Id\": 1, \"Name\": \"foo\"}\n{\"Id\": 2, \"Name\": \"bar\"}\n\n{\"Id\": 3, \"Name\": \"baz\"}\n"
+	firstLine := int64(len("{\"Id\": 1, \"Name\": \"foo\"}\n"))
+
+	for _, tt := range []struct {
+		desc         string
+		checkpoint   *loadCheckpoint // checkpoint of the previous run to resume
+		wantIds      []int64
+		wantWarnings []string
+	}{
+		{
+			desc:    "load all rows",
+			wantIds: []int64{1, 2, 3},
+		},
+		{
+			desc:         "resume from line 2",
+			checkpoint:   &loadCheckpoint{Table: "Singers", Offset: firstLine, Line: 2, Loaded: 1},
+			wantIds:      []int64{2, 3},
+			wantWarnings: []string{"resumed from line 2, 1 rows were loaded by the previous run"},
+		},
+		{
+			desc:         "completed",
+			checkpoint:   &loadCheckpoint{Table: "Singers", Offset: int64(len(input)), Line: 5, Loaded: 3, Completed: true},
+			wantWarnings: []string{"all rows have been loaded by the previous run (3 rows)"},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx := context.Background()
+			session := newLoadTestSession(t, "CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)")
+
+			reader, err := newLoadReader(strings.NewReader(input), "JSONL", columns, &systemVariables{})
+			if err != nil {
+				t.Fatalf("newLoadReader() got error: %v", err)
+			}
+			loader := &tableLoader{
+				session:        session,
+				table:          "Singers",
+				columns:        columns,
+				mutation:       spanner.InsertMap,
+				rowsPerBatch:   1,
+				bytesLimit:     loadBatchBytesLimit,
+				checkpointPath: filepath.Join(t.TempDir(), "singers.jsonl.checkpoint"),
+				checkpoint:     &loadCheckpoint{Table: "Singers"},
+			}
+			if tt.checkpoint != nil {
+				if err := loader.resume(reader, tt.checkpoint); err != nil {
+					t.Fatalf("resume() got error: %v", err)
+				}
+			}
+			var warnings []string
+			if tt.checkpoint == nil || !tt.checkpoint.Completed {
+				result, err := loader.load(ctx, reader)
+				if err != nil {
+					t.Fatalf("load() got error: %v", err)
+				}
+				warnings = result.Warnings
+			} else {
+				warnings = loader.warnings
+			}
+			if diff := cmp.Diff(tt.wantWarnings, warnings); diff != "" {
+				t.Errorf("warnings mismatch (-want +got):\n%s", diff)
+			}
+
+			var gotIds []int64
+			err = session.client.Single().Query(ctx, spanner.NewStatement("SELECT Id FROM Singers ORDER BY Id")).Do(func(row *spanner.Row) error {
+				var id int64
+				if err := row.Columns(&id); err != nil {
+					return err
+				}
+				gotIds = append(gotIds, id)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to query: %v", err)
+			}
+			if diff := cmp.Diff(tt.wantIds, gotIds); diff != "" {
+				t.Errorf("rows mismatch (-want +got):\n%s", diff)
+			}
+
+			if tt.checkpoint != nil && tt.checkpoint.Completed {
+				return
+			}
+			checkpoint, err := readLoadCheckpoint(loader.checkpointPath)
+			if err != nil {
+				t.Fatalf("readLoadCheckpoint() got error: %v", err)
+			}
+			want := &loadCheckpoint{Table: "Singers", Offset: int64(len(input)), Line: 5, Loaded: 3, Completed: true}
+			if diff := cmp.Diff(want, checkpoint, cmpopts.IgnoreFields(loadCheckpoint{}, "CommitTimestamp")); diff != "" {
+				t.Errorf("checkpoint mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
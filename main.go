@@ -18,11 +18,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 
 	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	flags "github.com/jessevdk/go-flags"
@@ -33,21 +35,28 @@ type globalOptions struct {
 }
 
 type spannerOptions struct {
-	ProjectId     string `short:"p" long:"project" env:"SPANNER_PROJECT_ID" description:"(required) GCP Project ID."`
-	InstanceId    string `short:"i" long:"instance" env:"SPANNER_INSTANCE_ID" description:"(required) Cloud Spanner Instance ID"`
-	DatabaseId    string `short:"d" long:"database" env:"SPANNER_DATABASE_ID" description:"(required) Cloud Spanner Database ID."`
-	Execute       string `short:"e" long:"execute" description:"Execute SQL statement and quit."`
-	File          string `short:"f" long:"file" description:"Execute SQL statement from file and quit."`
-	Table         bool   `short:"t" long:"table" description:"Display output in table format for batch mode."`
-	Verbose       bool   `short:"v" long:"verbose" description:"Display verbose output."`
-	Credential    string `long:"credential" description:"Use the specific credential file"`
-	Prompt        string `long:"prompt" description:"Set the prompt to the specified format"`
-	HistoryFile   string `long:"history" description:"Set the history file to the specified path"`
-	Priority      string `long:"priority" description:"Set default request priority (HIGH|MEDIUM|LOW)"`
-	Role          string `long:"role" description:"Use the specific database role"`
-	Endpoint      string `long:"endpoint" description:"Set the Spanner API endpoint (host:port)"`
-	DirectedRead  string `long:"directed-read" description:"Directed read option (replica_location:replica_type). The replicat_type is optional and either READ_ONLY or READ_WRITE"`
-	SkipTLSVerify bool   `long:"skip-tls-verify" description:"Insecurely skip TLS verify"`
+	ProjectId      string            `short:"p" long:"project" env:"SPANNER_PROJECT_ID" description:"(required) GCP Project ID."`
+	InstanceId     string            `short:"i" long:"instance" env:"SPANNER_INSTANCE_ID" description:"(required) Cloud Spanner Instance ID"`
+	DatabaseId     string            `short:"d" long:"database" env:"SPANNER_DATABASE_ID" description:"(required) Cloud Spanner Database ID."`
+	Execute        string            `short:"e" long:"execute" description:"Execute SQL statement and quit."`
+	File           string            `short:"f" long:"file" description:"Execute SQL statement from file and quit."`
+	Table          bool              `short:"t" long:"table" description:"Display output in table format for batch mode."`
+	Format         string            `long:"format" description:"Output format (table|vertical|tab|csv|json|jsonl|markdown|html|template). It takes precedence over --table"`
+	Template       string            `long:"template" description:"Go text/template rendered for each row in template format"`
+	TemplateFile   string            `long:"template-file" description:"Read the row template from the file"`
+	TemplateHeader string            `long:"template-header" description:"Go text/template rendered before rows in template format"`
+	TemplateFooter string            `long:"template-footer" description:"Go text/template rendered after rows in template format"`
+	Verbose        bool              `short:"v" long:"verbose" description:"Display verbose output."`
+	Credential     string            `long:"credential" description:"Use the specific credential file"`
+	Prompt         string            `long:"prompt" description:"Set the prompt to the specified format"`
+	HistoryFile    string            `long:"history" description:"Set the history file to the specified path"`
+	Priority       string            `long:"priority" description:"Set default request priority (HIGH|MEDIUM|LOW)"`
+	Role           string            `long:"role" description:"Use the specific database role"`
+	Endpoint       string            `long:"endpoint" description:"Set the Spanner API endpoint (host:port)"`
+	DirectedRead   string            `long:"directed-read" description:"Directed read option (replica_location:replica_type[,...]). The replica_type is optional and either READ_ONLY or READ_WRITE. See README for exclusions and auto failover"`
+	SkipTLSVerify  bool              `long:"skip-tls-verify" description:"Insecurely skip TLS verify"`
+	Resume         bool              `long:"resume" description:"Resume LOAD DATA from the last committed batch recorded in the checkpoint file"`
+	Set            map[string]string `long:"set" description:"Set a system variable (NAME:VALUE). This option can be specified multiple times"`
 }
 
 func main() {
@@ -97,12 +106,8 @@ func main() {
 		}
 	}
 
-	cli, err := NewCli(opts.ProjectId, opts.InstanceId, opts.DatabaseId, opts.Prompt, opts.HistoryFile, cred, os.Stdin, os.Stdout, os.Stderr, opts.Verbose, priority, opts.Role, opts.Endpoint, directedRead, opts.SkipTLSVerify)
-	if err != nil {
-		exitf("Failed to connect to Spanner: %v", err)
-	}
-
 	var input string
+	var err error
 	if opts.Execute != "" {
 		input = opts.Execute
 	} else if opts.File == "-" {
@@ -124,9 +129,67 @@ func main() {
 		}
 	}
 
+	// Command line options are used as the initial values of system variables.
+	sysVars := &systemVariables{
+		RPCPriority:      priority,
+		DirectedRead:     directedRead,
+		CLIVerbose:       opts.Verbose,
+		CLIFormat:        DisplayModeTable,
+		TablePreviewRows: defaultTablePreviewRows,
+		CSVHeader:        true,
+		LoadResume:       opts.Resume,
+	}
+	if input != "" && !opts.Table {
+		sysVars.CLIFormat = DisplayModeTab
+	}
+	if opts.Format != "" {
+		mode, err := parseDisplayMode(opts.Format)
+		if err != nil {
+			exitf("Invalid format: %v\n", err)
+		}
+		sysVars.CLIFormat = mode
+	}
+	if opts.Template != "" && opts.TemplateFile != "" {
+		exitf("Invalid combination: --template, --template-file are exclusive\n")
+	}
+	if opts.TemplateFile != "" {
+		b, err := ioutil.ReadFile(opts.TemplateFile)
+		if err != nil {
+			exitf("Read from file %v failed: %v", opts.TemplateFile, err)
+		}
+		// The trailing newline of the file is trimmed because each rendered row is followed by a newline.
+		opts.Template = strings.TrimSuffix(string(b), "\n")
+	}
+	for name, value := range map[string]string{
+		"CLI_TEMPLATE":        opts.Template,
+		"CLI_TEMPLATE_HEADER": opts.TemplateHeader,
+		"CLI_TEMPLATE_FOOTER": opts.TemplateFooter,
+	} {
+		if err := sysVars.Set(name, value); err != nil {
+			exitf("Invalid template: %v\n", err)
+		}
+	}
+	for name, value := range opts.Set {
+		if err := sysVars.Set(name, value); err != nil {
+			exitf("Invalid system variable %s: %v\n", name, err)
+		}
+	}
+
+	cli, err := NewCli(opts.ProjectId, opts.InstanceId, opts.DatabaseId, opts.Prompt, opts.HistoryFile, cred, os.Stdin, os.Stdout, os.Stderr, sysVars, opts.Role, opts.Endpoint, opts.SkipTLSVerify)
+	if err != nil {
+		exitf("Failed to connect to Spanner: %v", err)
+	}
+	if sysVars.DirectedRead != nil {
+		if err := cli.Session.ValidateDirectedRead(context.Background()); err != nil {
+			exitf("Invalid directed read option: %v\n", err)
+		}
+		cli.PrintWarnings(cli.Session.TakeWarnings())
+	}
+
 	var exitCode int
 	if input != "" {
-		exitCode = cli.RunBatch(input, opts.Table)
+		// --table takes precedence over `\G` unless the format is given by --format.
+		exitCode = cli.RunBatch(input, opts.Table && opts.Format == "")
 	} else {
 		exitCode = cli.RunInteractive()
 	}
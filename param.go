@@ -0,0 +1,876 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// parseParamValue parses the value part of `SET PARAM` into a typed value.
+// The input is either a GoogleSQL literal expression (e.g. `42`, `TIMESTAMP '2024-01-01T00:00:00Z'`, `[1, 2]`,
+// `STRUCT<a INT64>(1)`) or a type name (e.g. `INT64`, `ARRAY<STRING>`) which is bound as a typed NULL.
+func parseParamValue(input string) (spanner.GenericColumnValue, error) {
+	tokens, err := tokenizeParam(input)
+	if err != nil {
+		return spanner.GenericColumnValue{}, err
+	}
+	p := &paramParser{tokens: tokens}
+
+	// Type only: bind as a typed NULL so that the parameter can be used by EXPLAIN or DESCRIBE.
+	if typ, err := p.parseType(); err == nil && p.peek().kind == paramTokenEOF {
+		return spanner.GenericColumnValue{Type: typ, Value: structpb.NewNullValue()}, nil
+	}
+
+	p.pos = 0
+	v, err := p.parseExpr()
+	if err != nil {
+		return spanner.GenericColumnValue{}, err
+	}
+	if tok := p.peek(); tok.kind != paramTokenEOF {
+		return spanner.GenericColumnValue{}, fmt.Errorf("unexpected %q in parameter value", tok.text)
+	}
+	if !isFullyTyped(v.Type) {
+		return spanner.GenericColumnValue{}, errors.New("type of parameter value can not be determined, use a typed literal such as CAST(NULL AS INT64) or ARRAY<INT64>[]")
+	}
+	return v, nil
+}
+
+type paramTokenKind int
+
+const (
+	paramTokenEOF paramTokenKind = iota
+	paramTokenIdent
+	paramTokenInt
+	paramTokenFloat
+	paramTokenString
+	paramTokenBytes
+	paramTokenSymbol
+)
+
+type paramToken struct {
+	kind paramTokenKind
+	text string // raw text for identifiers, numbers and symbols, unescaped content for strings and bytes
+}
+
+func tokenizeParam(input string) ([]paramToken, error) {
+	var tokens []paramToken
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.ContainsRune("[]()<>,-+", rune(c)):
+			tokens = append(tokens, paramToken{kind: paramTokenSymbol, text: string(c)})
+			i++
+		case c == '`':
+			end := strings.IndexByte(input[i+1:], '`')
+			if end < 0 {
+				return nil, errors.New("unclosed quoted identifier")
+			}
+			tokens = append(tokens, paramToken{kind: paramTokenIdent, text: input[i+1 : i+1+end]})
+			i += end + 2
+		case c == '\'' || c == '"':
+			s, n, err := unquoteStringLiteral(input[i:], false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, paramToken{kind: paramTokenString, text: s})
+			i += n
+		case isDigit(c) || (c == '.' && i+1 < len(input) && isDigit(input[i+1])):
+			tok, n := scanNumber(input[i:])
+			tokens = append(tokens, tok)
+			i += n
+		case isIdentStart(c):
+			j := i
+			for j < len(input) && isIdentPart(input[j]) {
+				j++
+			}
+			word := input[i:j]
+			// String and bytes literals with prefixes: r'', b'', rb'', br''
+			if j < len(input) && (input[j] == '\'' || input[j] == '"') && isStringPrefix(word) {
+				prefix := strings.ToLower(word)
+				raw := strings.Contains(prefix, "r")
+				s, n, err := unquoteStringLiteral(input[j:], raw)
+				if err != nil {
+					return nil, err
+				}
+				kind := paramTokenString
+				if strings.Contains(prefix, "b") {
+					kind = paramTokenBytes
+				}
+				tokens = append(tokens, paramToken{kind: kind, text: s})
+				i = j + n
+				continue
+			}
+			tokens = append(tokens, paramToken{kind: paramTokenIdent, text: word})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in parameter value", c)
+		}
+	}
+	return append(tokens, paramToken{kind: paramTokenEOF}), nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isStringPrefix(word string) bool {
+	switch strings.ToLower(word) {
+	case "r", "b", "rb", "br":
+		return true
+	default:
+		return false
+	}
+}
+
+func scanNumber(s string) (paramToken, int) {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		i := 2
+		for i < len(s) && strings.ContainsRune("0123456789abcdefABCDEF", rune(s[i])) {
+			i++
+		}
+		return paramToken{kind: paramTokenInt, text: s[:i]}, i
+	}
+
+	kind := paramTokenInt
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		kind = paramTokenFloat
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			kind = paramTokenFloat
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return paramToken{kind: kind, text: s[:i]}, i
+}
+
+// unquoteStringLiteral unquotes a GoogleSQL string literal at the beginning of s.
+// It returns the unescaped content and the length of the literal in s.
+// https://cloud.google.com/spanner/docs/reference/standard-sql/lexical#string_and_bytes_literals
+func unquoteStringLiteral(s string, raw bool) (string, int, error) {
+	quote := s[:1]
+	if strings.HasPrefix(s, strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+
+	var sb strings.Builder
+	i := len(quote)
+	for i < len(s) {
+		if strings.HasPrefix(s[i:], quote) {
+			return sb.String(), i + len(quote), nil
+		}
+		c := s[i]
+		if c == '\n' && len(quote) == 1 {
+			return "", 0, errors.New("unclosed string literal")
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 >= len(s) {
+			break
+		}
+		if raw {
+			sb.WriteString(s[i : i+2])
+			i += 2
+			continue
+		}
+		n, err := unescape(&sb, s[i+1:])
+		if err != nil {
+			return "", 0, err
+		}
+		i += 1 + n
+	}
+	return "", 0, errors.New("unclosed string literal")
+}
+
+// unescape writes the character of the escape sequence at the beginning of s (without the leading backslash)
+// and returns the length of the consumed sequence.
+func unescape(sb *strings.Builder, s string) (int, error) {
+	switch c := s[0]; c {
+	case 'a':
+		sb.WriteByte('\a')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case 'v':
+		sb.WriteByte('\v')
+	case '\\', '?', '"', '\'', '`':
+		sb.WriteByte(c)
+	case 'x', 'X':
+		if len(s) < 3 {
+			return 0, errors.New("invalid hex escape sequence")
+		}
+		v, err := strconv.ParseUint(s[1:3], 16, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid hex escape sequence: \\%s", s[:3])
+		}
+		sb.WriteByte(byte(v))
+		return 3, nil
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if len(s) < n+1 {
+			return 0, errors.New("invalid unicode escape sequence")
+		}
+		v, err := strconv.ParseUint(s[1:n+1], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			return 0, fmt.Errorf("invalid unicode escape sequence: \\%s", s[:n+1])
+		}
+		sb.WriteRune(rune(v))
+		return n + 1, nil
+	case '0', '1', '2', '3':
+		if len(s) < 3 {
+			return 0, errors.New("invalid octal escape sequence")
+		}
+		v, err := strconv.ParseUint(s[:3], 8, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid octal escape sequence: \\%s", s[:3])
+		}
+		sb.WriteByte(byte(v))
+		return 3, nil
+	default:
+		return 0, fmt.Errorf("invalid escape sequence: \\%c", c)
+	}
+	return 1, nil
+}
+
+type paramParser struct {
+	tokens []paramToken
+	pos    int
+}
+
+func (p *paramParser) peek() paramToken {
+	return p.tokens[p.pos]
+}
+
+func (p *paramParser) next() paramToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != paramTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *paramParser) peekKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == paramTokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *paramParser) peekSymbol(symbol string) bool {
+	tok := p.peek()
+	return tok.kind == paramTokenSymbol && tok.text == symbol
+}
+
+func (p *paramParser) expectSymbol(symbol string) error {
+	if tok := p.next(); tok.kind != paramTokenSymbol || tok.text != symbol {
+		return fmt.Errorf("expected %q, but got %q", symbol, tok.text)
+	}
+	return nil
+}
+
+func (p *paramParser) expectKeyword(keyword string) error {
+	if tok := p.next(); tok.kind != paramTokenIdent || !strings.EqualFold(tok.text, keyword) {
+		return fmt.Errorf("expected %s, but got %q", keyword, tok.text)
+	}
+	return nil
+}
+
+var scalarTypeCodes = map[string]sppb.TypeCode{
+	"BOOL":      sppb.TypeCode_BOOL,
+	"INT64":     sppb.TypeCode_INT64,
+	"FLOAT32":   sppb.TypeCode_FLOAT32,
+	"FLOAT64":   sppb.TypeCode_FLOAT64,
+	"NUMERIC":   sppb.TypeCode_NUMERIC,
+	"STRING":    sppb.TypeCode_STRING,
+	"BYTES":     sppb.TypeCode_BYTES,
+	"DATE":      sppb.TypeCode_DATE,
+	"TIMESTAMP": sppb.TypeCode_TIMESTAMP,
+	"JSON":      sppb.TypeCode_JSON,
+}
+
+// parseType parses a GoogleSQL type such as `INT64`, `STRING(MAX)`, `ARRAY<DATE>` or `STRUCT<a INT64, b STRING>`.
+func (p *paramParser) parseType() (*sppb.Type, error) {
+	tok := p.next()
+	if tok.kind != paramTokenIdent {
+		return nil, fmt.Errorf("expected type, but got %q", tok.text)
+	}
+
+	name := strings.ToUpper(tok.text)
+	switch name {
+	case "ARRAY":
+		if err := p.expectSymbol("<"); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(">"); err != nil {
+			return nil, err
+		}
+		return &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: elem}, nil
+	case "STRUCT":
+		if err := p.expectSymbol("<"); err != nil {
+			return nil, err
+		}
+		var fields []*sppb.StructType_Field
+		for !p.peekSymbol(">") {
+			if len(fields) > 0 {
+				if err := p.expectSymbol(","); err != nil {
+					return nil, err
+				}
+			}
+			field, err := p.parseStructTypeField()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field)
+		}
+		p.next()
+		return &sppb.Type{Code: sppb.TypeCode_STRUCT, StructType: &sppb.StructType{Fields: fields}}, nil
+	}
+
+	code, ok := scalarTypeCodes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type: %s", tok.text)
+	}
+	// Length of STRING and BYTES is accepted for convenience, but it has no effect.
+	if (code == sppb.TypeCode_STRING || code == sppb.TypeCode_BYTES) && p.peekSymbol("(") {
+		p.next()
+		if tok := p.next(); tok.kind != paramTokenInt && !(tok.kind == paramTokenIdent && strings.EqualFold(tok.text, "MAX")) {
+			return nil, fmt.Errorf("invalid length of %s: %q", name, tok.text)
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	return &sppb.Type{Code: code}, nil
+}
+
+func (p *paramParser) parseStructTypeField() (*sppb.StructType_Field, error) {
+	// A field is either `<name> <type>` or `<type>`.
+	start := p.pos
+	if tok := p.next(); tok.kind == paramTokenIdent {
+		if typ, err := p.parseType(); err == nil {
+			return &sppb.StructType_Field{Name: tok.text, Type: typ}, nil
+		}
+	}
+	p.pos = start
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	return &sppb.StructType_Field{Type: typ}, nil
+}
+
+// parseExpr parses a literal expression.
+// The returned value may be partially typed, e.g. an untyped NULL has a nil type
+// and an empty array has a nil element type. These are resolved by coerceParamValue.
+func (p *paramParser) parseExpr() (spanner.GenericColumnValue, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case paramTokenInt, paramTokenFloat:
+		p.next()
+		return parseNumberLiteral(tok, false)
+	case paramTokenString:
+		p.next()
+		return spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_STRING}, Value: structpb.NewStringValue(tok.text)}, nil
+	case paramTokenBytes:
+		p.next()
+		return spanner.GenericColumnValue{
+			Type:  &sppb.Type{Code: sppb.TypeCode_BYTES},
+			Value: structpb.NewStringValue(base64.StdEncoding.EncodeToString([]byte(tok.text))),
+		}, nil
+	case paramTokenSymbol:
+		switch tok.text {
+		case "-", "+":
+			p.next()
+			num := p.next()
+			if num.kind != paramTokenInt && num.kind != paramTokenFloat {
+				return spanner.GenericColumnValue{}, fmt.Errorf("expected number after %q, but got %q", tok.text, num.text)
+			}
+			return parseNumberLiteral(num, tok.text == "-")
+		case "[":
+			return p.parseArrayLiteral(nil)
+		case "(":
+			return p.parseTupleLiteral()
+		}
+	case paramTokenIdent:
+		return p.parseKeywordExpr()
+	}
+	return spanner.GenericColumnValue{}, fmt.Errorf("unexpected %q in parameter value", tok.text)
+}
+
+func (p *paramParser) parseKeywordExpr() (spanner.GenericColumnValue, error) {
+	tok := p.next()
+	switch keyword := strings.ToUpper(tok.text); keyword {
+	case "NULL":
+		return spanner.GenericColumnValue{Value: structpb.NewNullValue()}, nil
+	case "TRUE", "FALSE":
+		return spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_BOOL}, Value: structpb.NewBoolValue(keyword == "TRUE")}, nil
+	case "DATE", "TIMESTAMP", "NUMERIC", "JSON":
+		// Typed literals like DATE '2024-01-01'
+		s := p.next()
+		if s.kind != paramTokenString {
+			return spanner.GenericColumnValue{}, fmt.Errorf("expected string literal after %s, but got %q", keyword, s.text)
+		}
+		str := spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_STRING}, Value: structpb.NewStringValue(s.text)}
+		return coerceParamValue(str, &sppb.Type{Code: scalarTypeCodes[keyword]})
+	case "CAST":
+		if err := p.expectSymbol("("); err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		v, err := p.parseExpr()
+		if err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		if err := p.expectKeyword("AS"); err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		return coerceParamValue(v, typ)
+	case "ARRAY":
+		var typ *sppb.Type
+		if p.peekSymbol("<") {
+			p.pos--
+			var err error
+			if typ, err = p.parseType(); err != nil {
+				return spanner.GenericColumnValue{}, err
+			}
+		}
+		return p.parseArrayLiteral(typ)
+	case "STRUCT":
+		var typ *sppb.Type
+		if p.peekSymbol("<") {
+			p.pos--
+			var err error
+			if typ, err = p.parseType(); err != nil {
+				return spanner.GenericColumnValue{}, err
+			}
+		}
+		return p.parseStructLiteral(typ)
+	}
+	return spanner.GenericColumnValue{}, fmt.Errorf("unexpected %q in parameter value", tok.text)
+}
+
+func parseNumberLiteral(tok paramToken, negative bool) (spanner.GenericColumnValue, error) {
+	text := tok.text
+	if negative {
+		text = "-" + text
+	}
+
+	if tok.kind == paramTokenInt {
+		i, err := strconv.ParseInt(text, 0, 64)
+		if err != nil {
+			return spanner.GenericColumnValue{}, fmt.Errorf("invalid INT64 literal: %s", text)
+		}
+		return spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_INT64}, Value: structpb.NewStringValue(strconv.FormatInt(i, 10))}, nil
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return spanner.GenericColumnValue{}, fmt.Errorf("invalid FLOAT64 literal: %s", text)
+	}
+	return spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_FLOAT64}, Value: floatValue(f)}, nil
+}
+
+// parseArrayLiteral parses `[elem, ...]`. typ is the explicit array type if it is given.
+func (p *paramParser) parseArrayLiteral(typ *sppb.Type) (spanner.GenericColumnValue, error) {
+	if err := p.expectSymbol("["); err != nil {
+		return spanner.GenericColumnValue{}, err
+	}
+
+	var elems []spanner.GenericColumnValue
+	for !p.peekSymbol("]") {
+		if len(elems) > 0 {
+			if err := p.expectSymbol(","); err != nil {
+				return spanner.GenericColumnValue{}, err
+			}
+		}
+		elem, err := p.parseExpr()
+		if err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		elems = append(elems, elem)
+	}
+	p.next()
+
+	elemType := typ.GetArrayElementType()
+	if elemType == nil {
+		var err error
+		if elemType, err = commonSuperType(elems); err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+	}
+
+	values := make([]*structpb.Value, 0, len(elems))
+	for _, elem := range elems {
+		v, err := coerceParamValue(elem, elemType)
+		if err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		values = append(values, v.Value)
+	}
+	return spanner.GenericColumnValue{
+		Type:  &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: elemType},
+		Value: structpb.NewListValue(&structpb.ListValue{Values: values}),
+	}, nil
+}
+
+// parseTupleLiteral parses `(expr)` or a tuple syntax of STRUCT `(expr, expr, ...)`.
+func (p *paramParser) parseTupleLiteral() (spanner.GenericColumnValue, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return spanner.GenericColumnValue{}, err
+	}
+
+	var elems []spanner.GenericColumnValue
+	for !p.peekSymbol(")") {
+		if len(elems) > 0 {
+			if err := p.expectSymbol(","); err != nil {
+				return spanner.GenericColumnValue{}, err
+			}
+		}
+		elem, err := p.parseExpr()
+		if err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		elems = append(elems, elem)
+	}
+	p.next()
+
+	switch len(elems) {
+	case 0:
+		return spanner.GenericColumnValue{}, errors.New("empty parentheses in parameter value")
+	case 1:
+		return elems[0], nil
+	default:
+		fields := make([]*sppb.StructType_Field, len(elems))
+		for i, elem := range elems {
+			fields[i] = &sppb.StructType_Field{Type: elem.Type}
+		}
+		return newStructValue(fields, elems), nil
+	}
+}
+
+// parseStructLiteral parses `(expr [AS name], ...)` following STRUCT or STRUCT<...>.
+func (p *paramParser) parseStructLiteral(typ *sppb.Type) (spanner.GenericColumnValue, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return spanner.GenericColumnValue{}, err
+	}
+
+	var fields []*sppb.StructType_Field
+	var elems []spanner.GenericColumnValue
+	for !p.peekSymbol(")") {
+		if len(elems) > 0 {
+			if err := p.expectSymbol(","); err != nil {
+				return spanner.GenericColumnValue{}, err
+			}
+		}
+		elem, err := p.parseExpr()
+		if err != nil {
+			return spanner.GenericColumnValue{}, err
+		}
+		field := &sppb.StructType_Field{Type: elem.Type}
+		if p.peekKeyword("AS") {
+			p.next()
+			name := p.next()
+			if name.kind != paramTokenIdent {
+				return spanner.GenericColumnValue{}, fmt.Errorf("expected field name after AS, but got %q", name.text)
+			}
+			field.Name = name.text
+		}
+		fields = append(fields, field)
+		elems = append(elems, elem)
+	}
+	p.next()
+
+	if typ == nil {
+		return newStructValue(fields, elems), nil
+	}
+
+	if len(typ.GetStructType().GetFields()) != len(elems) {
+		return spanner.GenericColumnValue{}, fmt.Errorf("STRUCT type has %d fields, but %d values are given", len(typ.GetStructType().GetFields()), len(elems))
+	}
+	return coerceParamValue(newStructValue(fields, elems), typ)
+}
+
+func newStructValue(fields []*sppb.StructType_Field, elems []spanner.GenericColumnValue) spanner.GenericColumnValue {
+	values := make([]*structpb.Value, len(elems))
+	for i, elem := range elems {
+		values[i] = elem.Value
+	}
+	return spanner.GenericColumnValue{
+		Type:  &sppb.Type{Code: sppb.TypeCode_STRUCT, StructType: &sppb.StructType{Fields: fields}},
+		Value: structpb.NewListValue(&structpb.ListValue{Values: values}),
+	}
+}
+
+// commonSuperType determines the element type of an untyped array literal.
+// It returns nil if the type can't be determined, e.g. the array is empty.
+func commonSuperType(elems []spanner.GenericColumnValue) (*sppb.Type, error) {
+	var super *sppb.Type
+	for _, elem := range elems {
+		switch {
+		case !isFullyTyped(elem.Type):
+			continue
+		case super == nil:
+			super = elem.Type
+		case proto.Equal(super, elem.Type):
+			continue
+		case super.GetCode() == sppb.TypeCode_INT64 && elem.Type.GetCode() == sppb.TypeCode_FLOAT64:
+			super = elem.Type
+		case super.GetCode() == sppb.TypeCode_FLOAT64 && elem.Type.GetCode() == sppb.TypeCode_INT64:
+			continue
+		default:
+			return nil, fmt.Errorf("array elements of types %s and %s have no common supertype", formatTypeVerbose(super), formatTypeVerbose(elem.Type))
+		}
+	}
+	return super, nil
+}
+
+// isFullyTyped returns false if the type or any of its element or field types are unknown.
+func isFullyTyped(typ *sppb.Type) bool {
+	switch typ.GetCode() {
+	case sppb.TypeCode_TYPE_CODE_UNSPECIFIED:
+		return false
+	case sppb.TypeCode_ARRAY:
+		return isFullyTyped(typ.GetArrayElementType())
+	case sppb.TypeCode_STRUCT:
+		for _, field := range typ.GetStructType().GetFields() {
+			if !isFullyTyped(field.GetType()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// coerceParamValue converts v to the given type in the same manner as CAST.
+func coerceParamValue(v spanner.GenericColumnValue, typ *sppb.Type) (spanner.GenericColumnValue, error) {
+	if typ == nil || (v.Type != nil && proto.Equal(v.Type, typ)) {
+		return v, nil
+	}
+	if _, isNull := v.Value.GetKind().(*structpb.Value_NullValue); isNull {
+		return spanner.GenericColumnValue{Type: typ, Value: v.Value}, nil
+	}
+
+	from := v.Type.GetCode()
+	incompatible := fmt.Errorf("can not convert %s to %s", formatTypeVerbose(v.Type), formatTypeVerbose(typ))
+	switch typ.GetCode() {
+	case sppb.TypeCode_ARRAY:
+		if from != sppb.TypeCode_ARRAY {
+			return spanner.GenericColumnValue{}, incompatible
+		}
+		var values []*structpb.Value
+		for _, elem := range v.Value.GetListValue().GetValues() {
+			c, err := coerceParamValue(spanner.GenericColumnValue{Type: v.Type.GetArrayElementType(), Value: elem}, typ.GetArrayElementType())
+			if err != nil {
+				return spanner.GenericColumnValue{}, err
+			}
+			values = append(values, c.Value)
+		}
+		return spanner.GenericColumnValue{Type: typ, Value: structpb.NewListValue(&structpb.ListValue{Values: values})}, nil
+	case sppb.TypeCode_STRUCT:
+		fields := typ.GetStructType().GetFields()
+		elems := v.Value.GetListValue().GetValues()
+		if from != sppb.TypeCode_STRUCT || len(fields) != len(elems) {
+			return spanner.GenericColumnValue{}, incompatible
+		}
+		values := make([]*structpb.Value, len(elems))
+		for i, elem := range elems {
+			c, err := coerceParamValue(spanner.GenericColumnValue{Type: v.Type.GetStructType().GetFields()[i].GetType(), Value: elem}, fields[i].GetType())
+			if err != nil {
+				return spanner.GenericColumnValue{}, err
+			}
+			values[i] = c.Value
+		}
+		return spanner.GenericColumnValue{Type: typ, Value: structpb.NewListValue(&structpb.ListValue{Values: values})}, nil
+	}
+
+	switch to := typ.GetCode(); {
+	case from == sppb.TypeCode_BOOL && to != sppb.TypeCode_BOOL && to != sppb.TypeCode_STRING:
+		return spanner.GenericColumnValue{}, incompatible
+	case from != sppb.TypeCode_STRING && (to == sppb.TypeCode_BYTES || to == sppb.TypeCode_DATE || to == sppb.TypeCode_TIMESTAMP || to == sppb.TypeCode_JSON):
+		return spanner.GenericColumnValue{}, incompatible
+	}
+
+	// Scalar types are converted via their string representations.
+	var s string
+	switch from {
+	case sppb.TypeCode_STRING, sppb.TypeCode_INT64, sppb.TypeCode_NUMERIC:
+		s = v.Value.GetStringValue()
+	case sppb.TypeCode_FLOAT64, sppb.TypeCode_FLOAT32:
+		if n, ok := v.Value.GetKind().(*structpb.Value_NumberValue); ok {
+			s = strconv.FormatFloat(n.NumberValue, 'g', -1, 64)
+		} else {
+			s = v.Value.GetStringValue() // NaN, Infinity, -Infinity
+		}
+	case sppb.TypeCode_BOOL:
+		s = strconv.FormatBool(v.Value.GetBoolValue())
+	default:
+		return spanner.GenericColumnValue{}, incompatible
+	}
+
+	value, err := parseScalarParamValue(s, typ.GetCode())
+	if err != nil {
+		return spanner.GenericColumnValue{}, err
+	}
+	return spanner.GenericColumnValue{Type: typ, Value: value}, nil
+}
+
+// parseScalarParamValue converts a string representation into the wire format of the type.
+// See https://cloud.google.com/spanner/docs/reference/rest/v1/TypeCode for the format.
+func parseScalarParamValue(s string, code sppb.TypeCode) (*structpb.Value, error) {
+	switch code {
+	case sppb.TypeCode_STRING:
+		return structpb.NewStringValue(s), nil
+	case sppb.TypeCode_BOOL:
+		b, err := strconv.ParseBool(strings.ToLower(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid BOOL value: %q", s)
+		}
+		return structpb.NewBoolValue(b), nil
+	case sppb.TypeCode_INT64:
+		i, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INT64 value: %q", s)
+		}
+		return structpb.NewStringValue(strconv.FormatInt(i, 10)), nil
+	case sppb.TypeCode_FLOAT64, sppb.TypeCode_FLOAT32:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %q", code, s)
+		}
+		return floatValue(f), nil
+	case sppb.TypeCode_NUMERIC:
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid NUMERIC value: %q", s)
+		}
+		return structpb.NewStringValue(spanner.NumericString(r)), nil
+	case sppb.TypeCode_BYTES:
+		return structpb.NewStringValue(base64.StdEncoding.EncodeToString([]byte(s))), nil
+	case sppb.TypeCode_DATE:
+		d, err := civil.ParseDate(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DATE value: %q", s)
+		}
+		return structpb.NewStringValue(d.String()), nil
+	case sppb.TypeCode_TIMESTAMP:
+		t, err := parseTimestampLiteral(s)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStringValue(t.UTC().Format(time.RFC3339Nano)), nil
+	case sppb.TypeCode_JSON:
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid JSON value: %q", s)
+		}
+		return structpb.NewStringValue(s), nil
+	default:
+		return nil, fmt.Errorf("unsupported type: %s", code)
+	}
+}
+
+var timestampLiteralLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999Z07",
+}
+
+// parseTimestampLiteral parses the content of a TIMESTAMP literal.
+// Unlike GoogleSQL, the time zone offset is required to avoid depending on the default time zone.
+func parseTimestampLiteral(s string) (time.Time, error) {
+	for _, layout := range timestampLiteralLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid TIMESTAMP value: %q, it must be in RFC3339 format with a time zone offset", s)
+}
+
+func floatValue(f float64) *structpb.Value {
+	switch {
+	case math.IsNaN(f):
+		return structpb.NewStringValue("NaN")
+	case math.IsInf(f, 1):
+		return structpb.NewStringValue("Infinity")
+	case math.IsInf(f, -1):
+		return structpb.NewStringValue("-Infinity")
+	default:
+		return structpb.NewNumberValue(f)
+	}
+}
@@ -0,0 +1,242 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func listValue(vs ...*structpb.Value) *structpb.Value {
+	return structpb.NewListValue(&structpb.ListValue{Values: vs})
+}
+
+func TestParseParamValue(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		input string
+		want  spanner.GenericColumnValue
+	}{
+		{
+			desc:  "INT64",
+			input: "42",
+			want:  spanner.GenericColumnValue{Type: intType(), Value: structpb.NewStringValue("42")},
+		},
+		{
+			desc:  "negative INT64",
+			input: "-42",
+			want:  spanner.GenericColumnValue{Type: intType(), Value: structpb.NewStringValue("-42")},
+		},
+		{
+			desc:  "hex INT64",
+			input: "0x1F",
+			want:  spanner.GenericColumnValue{Type: intType(), Value: structpb.NewStringValue("31")},
+		},
+		{
+			desc:  "FLOAT64",
+			input: "1.5e3",
+			want:  spanner.GenericColumnValue{Type: floatType(), Value: structpb.NewNumberValue(1500)},
+		},
+		{
+			desc:  "BOOL",
+			input: "TRUE",
+			want:  spanner.GenericColumnValue{Type: boolType(), Value: structpb.NewBoolValue(true)},
+		},
+		{
+			desc:  "STRING",
+			input: `"foo\tbar"`,
+			want:  spanner.GenericColumnValue{Type: stringType(), Value: structpb.NewStringValue("foo\tbar")},
+		},
+		{
+			desc:  "raw STRING",
+			input: `r'foo\tbar'`,
+			want:  spanner.GenericColumnValue{Type: stringType(), Value: structpb.NewStringValue(`foo\tbar`)},
+		},
+		{
+			desc:  "triple-quoted STRING",
+			input: `'''it's'''`,
+			want:  spanner.GenericColumnValue{Type: stringType(), Value: structpb.NewStringValue("it's")},
+		},
+		{
+			desc:  "BYTES",
+			input: `b"\x00\x01"`,
+			want:  spanner.GenericColumnValue{Type: bytesType(), Value: structpb.NewStringValue("AAE=")},
+		},
+		{
+			desc:  "DATE",
+			input: "DATE '2024-01-01'",
+			want:  spanner.GenericColumnValue{Type: dateType(), Value: structpb.NewStringValue("2024-01-01")},
+		},
+		{
+			desc:  "TIMESTAMP",
+			input: "TIMESTAMP '2024-01-01T09:00:00+09:00'",
+			want:  spanner.GenericColumnValue{Type: tsType(), Value: structpb.NewStringValue("2024-01-01T00:00:00Z")},
+		},
+		{
+			desc:  "NUMERIC",
+			input: "NUMERIC '1.5'",
+			want:  spanner.GenericColumnValue{Type: numericType(), Value: structpb.NewStringValue("1.500000000")},
+		},
+		{
+			desc:  "JSON",
+			input: `JSON '{"a": 1}'`,
+			want:  spanner.GenericColumnValue{Type: jsonType(), Value: structpb.NewStringValue(`{"a": 1}`)},
+		},
+		{
+			desc:  "CAST",
+			input: "CAST('10' AS INT64)",
+			want:  spanner.GenericColumnValue{Type: intType(), Value: structpb.NewStringValue("10")},
+		},
+		{
+			desc:  "CAST NULL",
+			input: "CAST(NULL AS STRING)",
+			want:  spanner.GenericColumnValue{Type: stringType(), Value: structpb.NewNullValue()},
+		},
+		{
+			desc:  "typed NULL",
+			input: "INT64",
+			want:  spanner.GenericColumnValue{Type: intType(), Value: structpb.NewNullValue()},
+		},
+		{
+			desc:  "typed NULL of ARRAY",
+			input: "ARRAY<STRING(MAX)>",
+			want:  spanner.GenericColumnValue{Type: listType(stringType()), Value: structpb.NewNullValue()},
+		},
+		{
+			desc:  "ARRAY",
+			input: "[1, 2, NULL]",
+			want: spanner.GenericColumnValue{
+				Type:  listType(intType()),
+				Value: listValue(structpb.NewStringValue("1"), structpb.NewStringValue("2"), structpb.NewNullValue()),
+			},
+		},
+		{
+			desc:  "ARRAY with supertype",
+			input: "[1, 2.5]",
+			want: spanner.GenericColumnValue{
+				Type:  listType(floatType()),
+				Value: listValue(structpb.NewNumberValue(1), structpb.NewNumberValue(2.5)),
+			},
+		},
+		{
+			desc:  "typed empty ARRAY",
+			input: "ARRAY<DATE>[]",
+			want:  spanner.GenericColumnValue{Type: listType(dateType()), Value: listValue()},
+		},
+		{
+			desc:  "typed ARRAY",
+			input: "ARRAY<TIMESTAMP>['2024-01-01T00:00:00Z']",
+			want: spanner.GenericColumnValue{
+				Type:  listType(tsType()),
+				Value: listValue(structpb.NewStringValue("2024-01-01T00:00:00Z")),
+			},
+		},
+		{
+			desc:  "STRUCT",
+			input: "STRUCT(1 AS a, 'x')",
+			want: spanner.GenericColumnValue{
+				Type: structType(
+					&sppb.StructType_Field{Name: "a", Type: intType()},
+					&sppb.StructType_Field{Type: stringType()},
+				),
+				Value: listValue(structpb.NewStringValue("1"), structpb.NewStringValue("x")),
+			},
+		},
+		{
+			desc:  "typed STRUCT",
+			input: "STRUCT<a FLOAT64, b STRING>(1, NULL)",
+			want: spanner.GenericColumnValue{
+				Type: structType(
+					&sppb.StructType_Field{Name: "a", Type: floatType()},
+					&sppb.StructType_Field{Name: "b", Type: stringType()},
+				),
+				Value: listValue(structpb.NewNumberValue(1), structpb.NewNullValue()),
+			},
+		},
+		{
+			desc:  "tuple STRUCT",
+			input: "(1, true)",
+			want: spanner.GenericColumnValue{
+				Type: structType(
+					&sppb.StructType_Field{Type: intType()},
+					&sppb.StructType_Field{Type: boolType()},
+				),
+				Value: listValue(structpb.NewStringValue("1"), structpb.NewBoolValue(true)),
+			},
+		},
+		{
+			desc:  "ARRAY of STRUCT",
+			input: "[STRUCT(1 AS a)]",
+			want: spanner.GenericColumnValue{
+				Type:  listType(structType(&sppb.StructType_Field{Name: "a", Type: intType()})),
+				Value: listValue(listValue(structpb.NewStringValue("1"))),
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := parseParamValue(tt.input)
+			if err != nil {
+				t.Fatalf("parseParamValue(%q) got error: %v", tt.input, err)
+			}
+			if diff := cmp.Diff(tt.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("parseParamValue(%q) mismatch (-want +got):\n%s", tt.input, diff)
+			}
+		})
+	}
+
+	// invalid tests
+	for _, input := range []string{
+		"NULL",
+		"[]",
+		"[1, 'a']",
+		"DATE '2024-13-01'",
+		"TIMESTAMP '2024-01-01 00:00:00'",
+		"JSON '{'",
+		"CAST(TRUE AS DATE)",
+		"STRUCT<a INT64>(1, 2)",
+		"1 2",
+		"'unclosed",
+		"UNKNOWN_TYPE",
+	} {
+		if got, err := parseParamValue(input); err == nil {
+			t.Errorf("parseParamValue(%q) = %v, but want error", input, got)
+		}
+	}
+}
+
+func intType() *sppb.Type     { return &sppb.Type{Code: sppb.TypeCode_INT64} }
+func floatType() *sppb.Type   { return &sppb.Type{Code: sppb.TypeCode_FLOAT64} }
+func boolType() *sppb.Type    { return &sppb.Type{Code: sppb.TypeCode_BOOL} }
+func stringType() *sppb.Type  { return &sppb.Type{Code: sppb.TypeCode_STRING} }
+func bytesType() *sppb.Type   { return &sppb.Type{Code: sppb.TypeCode_BYTES} }
+func dateType() *sppb.Type    { return &sppb.Type{Code: sppb.TypeCode_DATE} }
+func tsType() *sppb.Type      { return &sppb.Type{Code: sppb.TypeCode_TIMESTAMP} }
+func numericType() *sppb.Type { return &sppb.Type{Code: sppb.TypeCode_NUMERIC} }
+func jsonType() *sppb.Type    { return &sppb.Type{Code: sppb.TypeCode_JSON} }
+
+func listType(t *sppb.Type) *sppb.Type {
+	return &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: t}
+}
+
+func structType(fields ...*sppb.StructType_Field) *sppb.Type {
+	return &sppb.Type{Code: sppb.TypeCode_STRUCT, StructType: &sppb.StructType{Fields: fields}}
+}
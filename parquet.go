@@ -0,0 +1,552 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/parquet-go/parquet-go/format"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// parquetRowGroupBytes bounds the memory to buffer rows of a row group.
+	parquetRowGroupBytes = 32 << 20
+
+	// parquetDecimalBytes is the size of NUMERIC values, which have 38 digits at most.
+	parquetDecimalBytes = 16
+	numericPrecision    = 38
+	numericScale        = 9
+
+	// parquetReadRows is the number of rows read from a Parquet file at a time.
+	parquetReadRows = 64
+)
+
+// parquetWriter writes rows to a Parquet file. Rows are buffered until they fill a row group.
+// ARRAY columns are written as the three-level LIST of the format.
+type parquetWriter struct {
+	out           io.Writer
+	rowGroupBytes int
+
+	writer  *parquet.Writer
+	columns []*parquetColumn
+	row     parquet.Row
+	size    int // bytes of values in the current row group
+}
+
+func newParquetWriter(out io.Writer) *parquetWriter {
+	return &parquetWriter{out: out, rowGroupBytes: parquetRowGroupBytes}
+}
+
+func (w *parquetWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	if err := validateExportColumnNames(columnNames); err != nil {
+		return err
+	}
+	var root parquetGroup
+	for i, field := range columnTypes {
+		column, err := newParquetColumn(columnNames[i], i, field.GetType())
+		if err != nil {
+			return err
+		}
+		w.columns = append(w.columns, column)
+		root.fields = append(root.fields, &parquetGroupField{Node: column.node(), name: column.name})
+	}
+	w.writer = parquet.NewWriter(w.out,
+		parquet.NewSchema("schema", root),
+		parquet.Compression(&parquet.Snappy),
+		parquetCreatedBy("spanner-cli"),
+	)
+	return nil
+}
+
+func (w *parquetWriter) WriteRow(row Row) error {
+	w.row = w.row[:0]
+	for i, value := range row.Values {
+		values, size, err := w.columns[i].appendValues(w.row, value)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", w.columns[i].name, err)
+		}
+		w.row = values
+		w.size += size
+	}
+	if _, err := w.writer.WriteRows([]parquet.Row{w.row}); err != nil {
+		return err
+	}
+	if w.size >= w.rowGroupBytes {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// Flush writes the last row group and the footer.
+func (w *parquetWriter) Flush() error {
+	if len(w.columns) == 0 {
+		// A file must have columns. The result has no columns only if the query is interrupted before the metadata,
+		// and then the file is removed.
+		return nil
+	}
+	return w.writer.Close()
+}
+
+func (w *parquetWriter) flushRowGroup() error {
+	w.size = 0
+	return w.writer.Flush()
+}
+
+// parquetCreatedBy is the option to write the application to the footer.
+// parquet.CreatedBy requires the version and the build, which spanner-cli doesn't have.
+type parquetCreatedBy string
+
+func (s parquetCreatedBy) ConfigureWriter(config *parquet.WriterConfig) {
+	config.CreatedBy = string(s)
+}
+
+// parquetGroup is the root of the schema. Unlike parquet.Group, which sorts fields by names,
+// the fields are in the order of the columns.
+type parquetGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g parquetGroup) Fields() []parquet.Field {
+	return g.fields
+}
+
+// parquetGroupField is a field of parquetGroup.
+type parquetGroupField struct {
+	parquet.Node
+	name string
+}
+
+func (f *parquetGroupField) Name() string {
+	return f.name
+}
+
+func (f *parquetGroupField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
+
+// parquetColumn converts values of a column to the values of its leaf column.
+type parquetColumn struct {
+	name     string
+	index    int // index of the leaf column
+	array    bool
+	elemType *pb.Type // type of values, which is the element type for ARRAY
+	leaf     parquet.Node
+}
+
+func newParquetColumn(name string, index int, typ *pb.Type) (*parquetColumn, error) {
+	column := &parquetColumn{name: name, index: index, elemType: typ}
+	if typ.GetCode() == pb.TypeCode_ARRAY {
+		column.array = true
+		column.elemType = typ.GetArrayElementType()
+	}
+	leaf, err := parquetLeafOf(column.elemType)
+	if err != nil {
+		return nil, fmt.Errorf("column %q: %w", name, err)
+	}
+	column.leaf = leaf
+	return column, nil
+}
+
+func parquetLeafOf(typ *pb.Type) (parquet.Node, error) {
+	switch typ.GetCode() {
+	case pb.TypeCode_BOOL:
+		return parquet.Leaf(parquet.BooleanType), nil
+	case pb.TypeCode_INT64, pb.TypeCode_ENUM:
+		return parquet.Leaf(parquet.Int64Type), nil
+	case pb.TypeCode_FLOAT32:
+		return parquet.Leaf(parquet.FloatType), nil
+	case pb.TypeCode_FLOAT64:
+		return parquet.Leaf(parquet.DoubleType), nil
+	case pb.TypeCode_NUMERIC:
+		return parquet.Decimal(numericScale, numericPrecision, parquet.FixedLenByteArrayType(parquetDecimalBytes)), nil
+	case pb.TypeCode_STRING:
+		return parquet.String(), nil
+	case pb.TypeCode_JSON:
+		return parquet.JSON(), nil
+	case pb.TypeCode_BYTES, pb.TypeCode_PROTO:
+		return parquet.Leaf(parquet.ByteArrayType), nil
+	case pb.TypeCode_DATE:
+		return parquet.Date(), nil
+	case pb.TypeCode_TIMESTAMP:
+		// Timestamps are adjusted to UTC and have microsecond precision.
+		return parquet.Timestamp(parquet.Microsecond), nil
+	default:
+		return nil, fmt.Errorf("type %s is not supported", formatTypeSimple(typ))
+	}
+}
+
+// node returns the node of the column, where all values are optional.
+func (c *parquetColumn) node() parquet.Node {
+	if c.array {
+		return parquet.Optional(parquet.List(parquet.Optional(c.leaf)))
+	}
+	return parquet.Optional(c.leaf)
+}
+
+// appendValues appends the values of the leaf column with their levels, and returns the bytes of the values.
+// A scalar is NULL (0) or defined (1). An ARRAY is NULL (0), empty (1), has a NULL element (2) or an element (3).
+func (c *parquetColumn) appendValues(row parquet.Row, value spanner.GenericColumnValue) (parquet.Row, int, error) {
+	if isNullValue(value) {
+		return append(row, parquet.NullValue().Level(0, 0, c.index)), 1, nil
+	}
+	if !c.array {
+		v, err := c.leafValue(value.Value)
+		if err != nil {
+			return nil, 0, err
+		}
+		return append(row, v.Level(0, 1, c.index)), 1 + len(v.Bytes()), nil
+	}
+
+	elems := value.Value.GetListValue().GetValues()
+	if len(elems) == 0 {
+		return append(row, parquet.NullValue().Level(0, 1, c.index)), 1, nil
+	}
+	var size int
+	for i, elem := range elems {
+		rep := 1
+		if i == 0 {
+			rep = 0
+		}
+		if _, ok := elem.GetKind().(*structpb.Value_NullValue); ok {
+			row = append(row, parquet.NullValue().Level(rep, 2, c.index))
+			size += 2
+			continue
+		}
+		v, err := c.leafValue(elem)
+		if err != nil {
+			return nil, 0, err
+		}
+		row = append(row, v.Level(rep, 3, c.index))
+		size += 2 + len(v.Bytes())
+	}
+	return row, size, nil
+}
+
+func (c *parquetColumn) leafValue(value *structpb.Value) (parquet.Value, error) {
+	v, err := exportValue(c.elemType, value)
+	if err != nil {
+		return parquet.Value{}, err
+	}
+	switch v := v.(type) {
+	case bool:
+		return parquet.BooleanValue(v), nil
+	case int64:
+		return parquet.Int64Value(v), nil
+	case float64:
+		if c.elemType.GetCode() == pb.TypeCode_FLOAT32 {
+			return parquet.FloatValue(float32(v)), nil
+		}
+		return parquet.DoubleValue(v), nil
+	case *big.Rat:
+		return parquet.FixedLenByteArrayValue(decimalBytes(v, numericScale, parquetDecimalBytes)), nil
+	case string:
+		return parquet.ByteArrayValue([]byte(v)), nil
+	case []byte:
+		return parquet.ByteArrayValue(v), nil
+	case civil.Date:
+		return parquet.Int32Value(int32(v.DaysSince(unixEpochDate))), nil
+	case time.Time:
+		return parquet.Int64Value(v.UnixMicro()), nil
+	default:
+		return parquet.Value{}, fmt.Errorf("unexpected value %T", v)
+	}
+}
+
+// decimalBytes returns the value scaled by the scale as a big-endian two's complement integer of the size.
+func decimalBytes(r *big.Rat, scale int, size int) []byte {
+	n := new(big.Int).Mul(r.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	n.Quo(n, r.Denom())
+	if n.Sign() < 0 {
+		n.Add(n, new(big.Int).Lsh(big.NewInt(1), uint(size*8)))
+	}
+	return n.FillBytes(make([]byte, size))
+}
+
+// parquetLoadReader reads rows of a Parquet file as records of LOAD DATA.
+// Line numbers of the records are the 1-based row numbers.
+type parquetLoadReader struct {
+	size    int64
+	reader  *parquet.Reader
+	fields  []*parquetField
+	numRows int64
+
+	rows     []parquet.Row // buffered rows
+	buffered int
+	next     int // index of the next row in rows
+	line     int // row number of the next row
+}
+
+// parquetField is a top-level field of the schema, which is a scalar or a list of scalars.
+type parquetField struct {
+	name  string
+	leaf  *parquet.Column
+	array bool
+	// nullDef is the definition level where the field is not NULL, and elemDef is the level where an element exists.
+	nullDef int
+	elemDef int
+}
+
+func newParquetLoadReader(r io.ReaderAt, size int64) (*parquetLoadReader, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet file: %w", err)
+	}
+	// Columns of groups don't have the LIST annotation, which is in the schema elements.
+	elems := file.Metadata().Schema
+	var fields []*parquetField
+	pos := 1
+	for _, column := range file.Root().Columns() {
+		if pos >= len(elems) {
+			return nil, errors.New("invalid Parquet schema")
+		}
+		field, err := newParquetField(column, isParquetList(elems[pos]))
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		pos = skipParquetSchemaElement(elems, pos)
+	}
+	return &parquetLoadReader{
+		size:    size,
+		reader:  parquet.NewReader(file),
+		fields:  fields,
+		numRows: file.NumRows(),
+		rows:    make([]parquet.Row, parquetReadRows),
+		line:    1,
+	}, nil
+}
+
+func isParquetList(elem format.SchemaElement) bool {
+	return (elem.LogicalType != nil && elem.LogicalType.List != nil) ||
+		(elem.ConvertedType != nil && *elem.ConvertedType == deprecated.List)
+}
+
+// skipParquetSchemaElement returns the position of the next sibling of the schema element at the position.
+func skipParquetSchemaElement(elems []format.SchemaElement, pos int) int {
+	next := pos + 1
+	for i := int32(0); i < elems[pos].NumChildren && next < len(elems); i++ {
+		next = skipParquetSchemaElement(elems, next)
+	}
+	return next
+}
+
+func newParquetField(column *parquet.Column, isList bool) (*parquetField, error) {
+	field := &parquetField{name: column.Name()}
+	var def int
+	if column.Optional() {
+		def = 1
+	}
+
+	children := column.Columns()
+	switch {
+	case column.Leaf() && column.Repeated():
+		// A repeated primitive is a list of non-NULL elements, which is never NULL.
+		field.leaf, field.array = column, true
+		field.nullDef, field.elemDef = 0, 1
+	case column.Leaf():
+		field.leaf = column
+		field.nullDef = def
+	case isList && len(children) == 1 && children[0].Repeated():
+		repeated := children[0]
+		field.array = true
+		field.nullDef, field.elemDef = def, def+1
+		switch elems := repeated.Columns(); {
+		case repeated.Leaf():
+			// The two-level list has repeated elements.
+			field.leaf = repeated
+		case len(elems) == 1 && elems[0].Leaf():
+			field.leaf = elems[0]
+		default:
+			return nil, fmt.Errorf("column %q has an unsupported list type", field.name)
+		}
+	default:
+		return nil, fmt.Errorf("column %q has an unsupported nested type", field.name)
+	}
+	return field, nil
+}
+
+func (r *parquetLoadReader) Read() (*loadRecord, error) {
+	if r.next >= r.buffered {
+		n, err := r.reader.ReadRows(r.rows)
+		if n == 0 {
+			if err == nil || errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		r.buffered, r.next = n, 0
+	}
+
+	columns := make([][]parquet.Value, len(r.rows[r.next]))
+	r.rows[r.next].Range(func(i int, values []parquet.Value) bool {
+		columns[i] = values
+		return true
+	})
+	values := make(map[string]interface{}, len(r.fields))
+	for _, field := range r.fields {
+		value, err := field.assemble(columns[field.leaf.Index()])
+		if err != nil {
+			return nil, fmt.Errorf("column %q of row %d: %w", field.name, r.line, err)
+		}
+		values[field.name] = value
+	}
+	record := &loadRecord{Line: r.line, NextLine: r.line + 1, Values: values}
+	r.next++
+	r.line++
+	record.Offset = r.offset()
+	return record, nil
+}
+
+// offset estimates the byte offset of the next row by the number of rows
+// to show the progress and to limit the size of batches.
+func (r *parquetLoadReader) offset() int64 {
+	if r.numRows == 0 {
+		return r.size
+	}
+	return r.size * int64(r.line-1) / r.numRows
+}
+
+func (r *parquetLoadReader) Position() loadPosition {
+	return loadPosition{Offset: r.offset(), Line: r.line}
+}
+
+// Skip skips rows before the line, which is the row number. Pages before the row are not read.
+func (r *parquetLoadReader) Skip(offset int64, line int) error {
+	if line < r.line {
+		return fmt.Errorf("can't skip back to row %d from %d", line, r.line)
+	}
+	if int64(line-1) > r.numRows {
+		return fmt.Errorf("can't skip to row %d beyond the end of the file", line)
+	}
+	if line == r.line {
+		return nil
+	}
+	if err := r.reader.SeekToRow(int64(line - 1)); err != nil {
+		return err
+	}
+	r.buffered, r.next, r.line = 0, 0, line
+	return nil
+}
+
+// assemble builds the value of the field from the values of its leaf column in a row.
+func (f *parquetField) assemble(values []parquet.Value) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, errors.New("no values")
+	}
+	if !f.array {
+		if values[0].IsNull() {
+			return nil, nil
+		}
+		return f.convert(values[0]), nil
+	}
+
+	switch def := values[0].DefinitionLevel(); {
+	case def < f.nullDef:
+		return nil, nil
+	case def < f.elemDef:
+		return []interface{}{}, nil
+	}
+	elems := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if v.IsNull() {
+			elems = append(elems, nil)
+			continue
+		}
+		elems = append(elems, f.convert(v))
+	}
+	return elems, nil
+}
+
+// convert converts a value of the physical type by the logical type.
+// It returns bool, int64, float64, *big.Rat for DECIMAL, string for STRING, JSON, ENUM and DATE,
+// time.Time for TIMESTAMP, or []byte.
+func (f *parquetField) convert(v parquet.Value) interface{} {
+	logical := f.leaf.Type().LogicalType()
+	if logical == nil {
+		logical = &parquetLogicalTypeNone
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32, parquet.Int64:
+		n := v.Int64()
+		if v.Kind() == parquet.Int32 {
+			n = int64(v.Int32())
+		}
+		switch {
+		case logical.Decimal != nil:
+			return new(big.Rat).SetFrac(big.NewInt(n), scaleOf(logical.Decimal.Scale))
+		case logical.Date != nil:
+			return unixEpochDate.AddDays(int(n)).String()
+		case logical.Timestamp != nil:
+			switch unit := logical.Timestamp.Unit; {
+			case unit.Millis != nil:
+				return time.UnixMilli(n).UTC()
+			case unit.Nanos != nil:
+				return time.Unix(0, n).UTC()
+			default:
+				return time.UnixMicro(n).UTC()
+			}
+		}
+		return n
+	case parquet.Int96:
+		// INT96 is the legacy timestamp of nanoseconds in a Julian day.
+		i := v.Int96()
+		nanos := int64(uint64(i[1])<<32 | uint64(i[0]))
+		days := int64(i[2]) - 2440588
+		return time.Unix(days*24*60*60, nanos).UTC()
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	default:
+		b := v.ByteArray()
+		switch {
+		case logical.Decimal != nil:
+			n := new(big.Int).SetBytes(b)
+			if len(b) > 0 && b[0]&0x80 != 0 {
+				n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+			}
+			return new(big.Rat).SetFrac(n, scaleOf(logical.Decimal.Scale))
+		case logical.UTF8 != nil || logical.Enum != nil || logical.Json != nil:
+			return string(b)
+		}
+		return bytes.Clone(b)
+	}
+}
+
+// parquetLogicalTypeNone is the logical type of columns which aren't annotated.
+var parquetLogicalTypeNone = format.LogicalType{}
+
+// scaleOf returns 10^scale of the DECIMAL type.
+func scaleOf(scale int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+}
@@ -0,0 +1,278 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// parquetGolden is the expected content of a golden Parquet file, which is also checked by testdata/parquet/verify.py with pyarrow.
+// Values are formatted as strings except BOOL, and the schema has the types of pyarrow.
+type parquetGolden struct {
+	NumRowGroups int `json:"num_row_groups"`
+	Schema       []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"schema"`
+	Rows [][]interface{} `json:"rows"`
+}
+
+// parquetGoldenColumns are the columns of testdata/parquet/types.json.
+var parquetGoldenColumns = []*loadColumn{
+	{Name: "Bool", Type: &pb.Type{Code: pb.TypeCode_BOOL}},
+	{Name: "Int64", Type: &pb.Type{Code: pb.TypeCode_INT64}},
+	{Name: "Numeric", Type: &pb.Type{Code: pb.TypeCode_NUMERIC}},
+	{Name: "String", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+	{Name: "Date", Type: &pb.Type{Code: pb.TypeCode_DATE}},
+	{Name: "Timestamp", Type: &pb.Type{Code: pb.TypeCode_TIMESTAMP}},
+	{Name: "Int64Array", Type: &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: &pb.Type{Code: pb.TypeCode_INT64}}},
+	{Name: "StringArray", Type: &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: &pb.Type{Code: pb.TypeCode_STRING}}},
+}
+
+func readParquetGolden(t *testing.T) *parquetGolden {
+	t.Helper()
+	b, err := os.ReadFile("testdata/parquet/types.json")
+	if err != nil {
+		t.Fatalf("failed to read the expected values: %v", err)
+	}
+	var golden parquetGolden
+	if err := json.Unmarshal(b, &golden); err != nil {
+		t.Fatalf("failed to parse the expected values: %v", err)
+	}
+	if len(golden.Schema) != len(parquetGoldenColumns) {
+		t.Fatalf("schema has %d columns, but want %d", len(golden.Schema), len(parquetGoldenColumns))
+	}
+	for i, field := range golden.Schema {
+		if field.Name != parquetGoldenColumns[i].Name {
+			t.Fatalf("column %d is %q, but want %q", i, field.Name, parquetGoldenColumns[i].Name)
+		}
+	}
+	return &golden
+}
+
+// TestParquetGolden checks that the writer writes the golden file, and the reader reads the expected values from it.
+// Run `go test -run TestParquetGolden -update` and testdata/parquet/verify.py after changing the writer.
+func TestParquetGolden(t *testing.T) {
+	golden := readParquetGolden(t)
+
+	var buf bytes.Buffer
+	w := newParquetWriter(&buf)
+	var columnNames []string
+	var columnTypes []*pb.StructType_Field
+	for _, column := range parquetGoldenColumns {
+		columnNames = append(columnNames, column.Name)
+		columnTypes = append(columnTypes, &pb.StructType_Field{Name: column.Name, Type: column.Type})
+	}
+	if err := w.WriteHeader(columnNames, columnTypes); err != nil {
+		t.Fatalf("WriteHeader() got error: %v", err)
+	}
+	rowsPerGroup := (len(golden.Rows) + golden.NumRowGroups - 1) / golden.NumRowGroups
+	for i, row := range golden.Rows {
+		var values []spanner.GenericColumnValue
+		for j, v := range row {
+			value, err := structpb.NewValue(v)
+			if err != nil {
+				t.Fatalf("invalid value of row %d: %v", i+1, err)
+			}
+			values = append(values, spanner.GenericColumnValue{Type: parquetGoldenColumns[j].Type, Value: value})
+		}
+		if err := w.WriteRow(Row{Values: values}); err != nil {
+			t.Fatalf("WriteRow() got error: %v", err)
+		}
+		if (i+1)%rowsPerGroup == 0 {
+			if err := w.flushRowGroup(); err != nil {
+				t.Fatalf("flushRowGroup() got error: %v", err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() got error: %v", err)
+	}
+
+	if *updateGolden {
+		if err := os.WriteFile("testdata/parquet/types.parquet", buf.Bytes(), 0644); err != nil {
+			t.Fatalf("failed to update the golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile("testdata/parquet/types.parquet")
+	if err != nil {
+		t.Fatalf("failed to read the golden file: %v", err)
+	}
+	if !bytes.Equal(want, buf.Bytes()) {
+		t.Errorf("written file differs from the golden file, run with -update and check it by testdata/parquet/verify.py")
+	}
+
+	reader, err := newParquetLoadReader(bytes.NewReader(want), int64(len(want)))
+	if err != nil {
+		t.Fatalf("newParquetLoadReader() got error: %v", err)
+	}
+	file, err := parquet.OpenFile(bytes.NewReader(want), int64(len(want)))
+	if err != nil {
+		t.Fatalf("failed to open the golden file: %v", err)
+	}
+	if got := len(file.RowGroups()); got != golden.NumRowGroups {
+		t.Errorf("number of row groups mismatch: got = %d, want = %d", got, golden.NumRowGroups)
+	}
+	var got [][]interface{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read() got error: %v", err)
+		}
+		var row []interface{}
+		for _, column := range parquetGoldenColumns {
+			row = append(row, formatParquetGoldenValue(record.Values[column.Name]))
+		}
+		got = append(got, row)
+	}
+	if diff := cmp.Diff(golden.Rows, got); diff != "" {
+		t.Errorf("rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// formatParquetGoldenValue formats a value read by parquetLoadReader in the same way as the golden JSON files.
+func formatParquetGoldenValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case *big.Rat:
+		return v.FloatString(numericScale)
+	case time.Time:
+		return v.UTC().Format("2006-01-02T15:04:05.000000Z")
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, elem := range v {
+			values[i] = formatParquetGoldenValue(elem)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// TestParquetFooter checks the metadata of the golden file, which other tools read to know the types.
+// https://github.com/apache/parquet-format/blob/master/LogicalTypes.md
+func TestParquetFooter(t *testing.T) {
+	b, err := os.ReadFile("testdata/parquet/types.parquet")
+	if err != nil {
+		t.Fatalf("failed to read the golden file: %v", err)
+	}
+	file, err := parquet.OpenFile(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("failed to open the golden file: %v", err)
+	}
+	meta := file.Metadata()
+	if got := meta.NumRows; got != 4 {
+		t.Errorf("num_rows = %d, want = 4", got)
+	}
+	if got := meta.CreatedBy; got != "spanner-cli" {
+		t.Errorf("created_by = %q, want = %q", got, "spanner-cli")
+	}
+
+	// Converted types are the legacy annotations: UTF8 = 0, LIST = 3, DECIMAL = 5, DATE = 6, TIMESTAMP_MICROS = 10.
+	wantSchema := []string{
+		"schema children=8",
+		"Bool BOOLEAN OPTIONAL",
+		"Int64 INT64 OPTIONAL",
+		"Numeric FIXED_LEN_BYTE_ARRAY(16) OPTIONAL converted=5(38,9) DECIMAL(38,9)",
+		"String BYTE_ARRAY OPTIONAL converted=0 STRING",
+		"Date INT32 OPTIONAL converted=6 DATE",
+		"Timestamp INT64 OPTIONAL converted=10 TIMESTAMP(isAdjustedToUTC=true,unit=MICROS)",
+		"Int64Array OPTIONAL children=1 converted=3 LIST",
+		"list REPEATED children=1",
+		"element INT64 OPTIONAL",
+		"StringArray OPTIONAL children=1 converted=3 LIST",
+		"list REPEATED children=1",
+		"element BYTE_ARRAY OPTIONAL converted=0 STRING",
+	}
+	var gotSchema []string
+	for _, elem := range meta.Schema {
+		gotSchema = append(gotSchema, formatParquetSchemaElement(elem))
+	}
+	if diff := cmp.Diff(wantSchema, gotSchema); diff != "" {
+		t.Errorf("schema mismatch (-want +got):\n%s", diff)
+	}
+
+	if len(meta.RowGroups) != 2 {
+		t.Fatalf("number of row groups = %d, want = 2", len(meta.RowGroups))
+	}
+	wantPaths := [][]string{{"Bool"}, {"Int64"}, {"Numeric"}, {"String"}, {"Date"}, {"Timestamp"},
+		{"Int64Array", "list", "element"}, {"StringArray", "list", "element"}}
+	for i, group := range meta.RowGroups {
+		if got := group.NumRows; got != 2 {
+			t.Errorf("row group %d: num_rows = %d, want = 2", i, got)
+		}
+		var paths [][]string
+		for j, column := range group.Columns {
+			paths = append(paths, column.MetaData.PathInSchema)
+			if got := column.MetaData.Codec; got != format.Snappy {
+				t.Errorf("row group %d, column %d: codec = %s, want = %s", i, j, got, format.Snappy)
+			}
+		}
+		if diff := cmp.Diff(wantPaths, paths); diff != "" {
+			t.Errorf("row group %d: path_in_schema mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+// formatParquetSchemaElement formats the type and the annotations of a schema element.
+func formatParquetSchemaElement(elem format.SchemaElement) string {
+	s := elem.Name
+	if elem.Type != nil {
+		s += " " + elem.Type.String()
+		if *elem.Type == format.FixedLenByteArray {
+			s += fmt.Sprintf("(%d)", *elem.TypeLength)
+		}
+	}
+	if elem.RepetitionType != nil {
+		s += " " + elem.RepetitionType.String()
+	}
+	if elem.NumChildren > 0 {
+		s += fmt.Sprintf(" children=%d", elem.NumChildren)
+	}
+	if elem.ConvertedType != nil {
+		s += fmt.Sprintf(" converted=%d", *elem.ConvertedType)
+	}
+	if elem.Scale != nil && elem.Precision != nil {
+		s += fmt.Sprintf("(%d,%d)", *elem.Precision, *elem.Scale)
+	}
+	if elem.LogicalType != nil {
+		s += " " + elem.LogicalType.String()
+	}
+	return s
+}
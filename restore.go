@@ -0,0 +1,347 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// RestoreRowsStatement writes rows of the table back to their values at an earlier read timestamp within the version retention period.
+// Only rows which existed at the timestamp are written, so rows inserted after the timestamp are kept.
+type RestoreRowsStatement struct {
+	Schema    string
+	Table     string
+	Timestamp readOnlyStaleness
+	Where     string
+}
+
+// Preview returns the differences between the current rows and the rows to be restored, and the number of rows to be restored.
+// It pins the timestamp to the read timestamp of the preview, so that Execute restores the same rows even if the timestamp is relative.
+func (s *RestoreRowsStatement) Preview(ctx context.Context, session *Session) (*Result, int, error) {
+	restorer, err := newRowRestorer(ctx, session, s.Schema, s.Table)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	collector := &rowCollector{}
+	dw := &rowDiffWriter{w: collector}
+	var restoring int
+	readTs, err := restorer.scan(ctx, s.Timestamp, s.Where, func(rows [][]spanner.GenericColumnValue) error {
+		diffs, err := restorer.diff(ctx, session.client.Single(), rows)
+		if err != nil {
+			return err
+		}
+		for _, diff := range diffs {
+			if err := dw.write(diff); err != nil {
+				return err
+			}
+		}
+		restoring += len(diffs)
+		session.SetProgress(fmt.Sprintf("%d rows to restore", restoring))
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if !readTs.IsZero() {
+		s.Timestamp = readOnlyStaleness{typ: readTimestamp, timestamp: readTs}
+	}
+
+	fields := diffFields(restorer.fields)
+	return &Result{
+		ColumnNames:  extractColumnNames(fields),
+		ColumnTypes:  fields,
+		Rows:         collector.rows,
+		AffectedRows: dw.count,
+		Timestamp:    readTs,
+	}, restoring, nil
+}
+
+func (s *RestoreRowsStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if session.InReadWriteTransaction() {
+		return nil, errors.New(`"RESTORE ROWS" can not be used in a read-write transaction`)
+	}
+
+	restorer, err := newRowRestorer(ctx, session, s.Schema, s.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored int
+	var timestamp time.Time
+	_, err = restorer.scan(ctx, s.Timestamp, s.Where, func(rows [][]spanner.GenericColumnValue) error {
+		// Differences are found again in the transaction, so that rows changed after the preview are compared with their latest values.
+		var n int
+		resp, err := session.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+			diffs, err := restorer.diff(ctx, txn, rows)
+			if err != nil {
+				return err
+			}
+			mutations := make([]*spanner.Mutation, len(diffs))
+			for i, diff := range diffs {
+				mutations[i] = restorer.mutation(diff.Right)
+			}
+			n = len(mutations)
+			return txn.BufferWrite(mutations)
+		}, spanner.TransactionOptions{CommitPriority: session.currentPriority()})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			restored += n
+			timestamp = resp.CommitTs
+		}
+		session.SetProgress(fmt.Sprintf("%d rows restored", restored))
+		return nil
+	})
+	if err != nil {
+		// Committed batches are not rolled back.
+		return nil, fmt.Errorf("%w (%d rows were restored before the failure)", err, restored)
+	}
+
+	return &Result{
+		IsMutation:   true,
+		AffectedRows: restored,
+		Timestamp:    timestamp,
+	}, nil
+}
+
+// rowRestorer reads rows at an earlier timestamp in batches, and finds the rows which differ from the current rows.
+type rowRestorer struct {
+	session      *Session
+	table        *diffTable
+	columnNames  []string
+	rowsPerBatch int
+	bytesLimit   int
+
+	fields []*pb.StructType_Field // columns of the table, which are set by scan
+}
+
+func newRowRestorer(ctx context.Context, session *Session, schema, name string) (*rowRestorer, error) {
+	table, err := loadDiffTable(ctx, session.client.Single(), schema, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.Keys) == 0 {
+		return nil, fmt.Errorf("table %q doesn't have the primary key", table.Name)
+	}
+	_, indexes, err := loadTableColumns(ctx, session, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	columnNames := make([]string, len(table.Columns))
+	var writable int
+	for i, column := range table.Columns {
+		columnNames[i] = column.Name
+		if !column.Generated {
+			writable++
+		}
+	}
+	return &rowRestorer{
+		session:      session,
+		table:        table,
+		columnNames:  columnNames,
+		rowsPerBatch: max(1, mutationLimit/(writable*(1+indexes))),
+		bytesLimit:   loadBatchBytesLimit,
+	}, nil
+}
+
+// scan reads the rows matching the condition at the timestamp, and calls f with batches of the rows.
+// It returns the read timestamp, or zero if it is unavailable.
+func (r *rowRestorer) scan(ctx context.Context, timestamp readOnlyStaleness, where string, f func(rows [][]spanner.GenericColumnValue) error) (time.Time, error) {
+	txn := r.session.client.Single().WithTimestampBound(timestamp.timestampBound())
+	iter := txn.QueryWithOptions(ctx, r.session.newStatement(r.table.scanQuery(where)), diffQueryOptions(r.session))
+	defer iter.Stop()
+
+	var batch [][]spanner.GenericColumnValue
+	var batchBytes int
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		values, err := rowValues(row)
+		if err != nil {
+			return time.Time{}, err
+		}
+		batch = append(batch, values)
+		for _, value := range values {
+			batchBytes += proto.Size(value.Value)
+		}
+		if len(batch) >= r.rowsPerBatch || batchBytes >= r.bytesLimit {
+			if err := f(batch); err != nil {
+				return time.Time{}, err
+			}
+			batch, batchBytes = nil, 0
+		}
+	}
+	if len(batch) > 0 {
+		if err := f(batch); err != nil {
+			return time.Time{}, err
+		}
+	}
+	r.fields = iter.Metadata.GetRowType().GetFields()
+	ts, _ := txn.Timestamp()
+	return ts, nil
+}
+
+// rowReader is a transaction which can read rows by keys.
+type rowReader interface {
+	ReadWithOptions(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts *spanner.ReadOptions) *spanner.RowIterator
+}
+
+// diff reads the current rows of the same keys as the rows to restore, and returns the differences,
+// where Left is the current row, or nil if it has been deleted, and Right is the row to restore.
+func (r *rowRestorer) diff(ctx context.Context, txn rowReader, rows [][]spanner.GenericColumnValue) ([]*rowDiff, error) {
+	keys := make([]spanner.Key, len(rows))
+	for i, row := range rows {
+		key, err := r.key(row)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+
+	current := make(map[string][]spanner.GenericColumnValue, len(rows))
+	opts := &spanner.ReadOptions{Priority: r.session.currentPriority(), RequestTag: r.session.currentRequestTag()}
+	err := txn.ReadWithOptions(ctx, r.table.qualifiedName(), spanner.KeySetFromKeys(keys...), r.columnNames, opts).Do(func(row *spanner.Row) error {
+		values, err := rowValues(row)
+		if err != nil {
+			return err
+		}
+		id, err := r.keyID(values)
+		if err != nil {
+			return err
+		}
+		current[id] = values
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []*rowDiff
+	for _, row := range rows {
+		id, err := r.keyID(row)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := current[id]
+		if !ok {
+			diffs = append(diffs, &rowDiff{Right: row})
+			continue
+		}
+		if changed := r.table.changedColumns(values, row); len(changed) > 0 {
+			diffs = append(diffs, &rowDiff{Left: values, Right: row, Changed: changed})
+		}
+	}
+	return diffs, nil
+}
+
+// key returns the primary key of the row for Read API.
+func (r *rowRestorer) key(row []spanner.GenericColumnValue) (spanner.Key, error) {
+	key := make(spanner.Key, len(r.table.Keys))
+	for i, k := range r.table.Keys {
+		part, err := keyPart(row[k.Column])
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", r.table.Columns[k.Column].Name, err)
+		}
+		key[i] = part
+	}
+	return key, nil
+}
+
+// keyID returns the primary key of the row as a string to look up rows.
+// Values read from Cloud Spanner have the canonical encodings, so the same keys have the same strings.
+func (r *rowRestorer) keyID(row []spanner.GenericColumnValue) (string, error) {
+	list := &structpb.ListValue{}
+	for _, k := range r.table.Keys {
+		list.Values = append(list.Values, row[k.Column].Value)
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(list)
+	return string(b), err
+}
+
+// mutation returns the mutation which writes the row except generated columns.
+func (r *rowRestorer) mutation(row []spanner.GenericColumnValue) *spanner.Mutation {
+	var columns []string
+	var values []interface{}
+	for i, column := range r.table.Columns {
+		if column.Generated {
+			continue
+		}
+		columns = append(columns, column.Name)
+		values = append(values, row[i])
+	}
+	return spanner.InsertOrUpdate(r.table.qualifiedName(), columns, values)
+}
+
+// keyPart converts a value of a key column into a part of spanner.Key, which doesn't accept spanner.GenericColumnValue.
+func keyPart(value spanner.GenericColumnValue) (interface{}, error) {
+	if isNullValue(value) {
+		switch value.Type.GetCode() {
+		case pb.TypeCode_BOOL:
+			return spanner.NullBool{}, nil
+		case pb.TypeCode_INT64, pb.TypeCode_ENUM:
+			return spanner.NullInt64{}, nil
+		case pb.TypeCode_FLOAT32:
+			return spanner.NullFloat32{}, nil
+		case pb.TypeCode_FLOAT64:
+			return spanner.NullFloat64{}, nil
+		case pb.TypeCode_NUMERIC:
+			return spanner.NullNumeric{}, nil
+		case pb.TypeCode_STRING:
+			return spanner.NullString{}, nil
+		case pb.TypeCode_BYTES, pb.TypeCode_PROTO:
+			return []byte(nil), nil
+		case pb.TypeCode_DATE:
+			return spanner.NullDate{}, nil
+		case pb.TypeCode_TIMESTAMP:
+			return spanner.NullTime{}, nil
+		default:
+			return nil, fmt.Errorf("type %s is not supported", formatTypeSimple(value.Type))
+		}
+	}
+
+	v, err := exportValue(value.Type, value.Value)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case *big.Rat:
+		return *v, nil
+	case float64:
+		if value.Type.GetCode() == pb.TypeCode_FLOAT32 {
+			return float32(v), nil
+		}
+	}
+	return v, nil
+}
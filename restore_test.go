@@ -0,0 +1,148 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRowRestorer(t *testing.T) {
+	ctx := context.Background()
+	session := newLoadTestSession(t,
+		"CREATE TABLE Singers (Id INT64 NOT NULL, Name STRING(MAX), Age INT64) PRIMARY KEY (Id DESC)",
+	)
+	columns := []string{"Id", "Name", "Age"}
+	_, err := session.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert("Singers", columns, []interface{}{1, "deleted", 10}),
+		spanner.Insert("Singers", columns, []interface{}{2, "updated", 20}),
+		spanner.Insert("Singers", columns, []interface{}{3, "same", 30}),
+		spanner.Insert("Singers", columns, []interface{}{4, "not matched", 40}),
+	})
+	if err != nil {
+		t.Fatalf("failed to insert rows: %v", err)
+	}
+
+	restorer := &rowRestorer{
+		session: session,
+		table: &diffTable{
+			dumpTableName: dumpTableName{Name: "Singers"},
+			Columns:       []diffColumn{{Name: "Id"}, {Name: "Name"}, {Name: "Age"}},
+			Keys:          []diffKey{{Column: 0, Desc: true}},
+		},
+		columnNames:  columns,
+		rowsPerBatch: 2,
+		bytesLimit:   loadBatchBytesLimit,
+	}
+
+	// The test server doesn't keep old versions, so rows are read before they are changed.
+	var batches [][][]spanner.GenericColumnValue
+	_, err = restorer.scan(ctx, readOnlyStaleness{typ: strong}, "Id < 4", func(rows [][]spanner.GenericColumnValue) error {
+		batches = append(batches, rows)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scan() got error: %v", err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("scan() got unexpected batches: %v", batches)
+	}
+	if diff := cmp.Diff([]string{"Id", "Name", "Age"}, extractColumnNames(restorer.fields)); diff != "" {
+		t.Errorf("fields mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = session.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Delete("Singers", spanner.Key{1}),
+		spanner.Update("Singers", []string{"Id", "Age"}, []interface{}{2, nil}),
+		spanner.Update("Singers", []string{"Id", "Age"}, []interface{}{4, 41}),
+	})
+	if err != nil {
+		t.Fatalf("failed to change rows: %v", err)
+	}
+
+	var diffs []*rowDiff
+	for _, rows := range batches {
+		d, err := restorer.diff(ctx, session.client.Single(), rows)
+		if err != nil {
+			t.Fatalf("diff() got error: %v", err)
+		}
+		diffs = append(diffs, d...)
+	}
+
+	w := &rowCollector{}
+	dw := &rowDiffWriter{w: w}
+	var mutations []*spanner.Mutation
+	for _, diff := range diffs {
+		if err := dw.write(diff); err != nil {
+			t.Fatalf("write() got error: %v", err)
+		}
+		mutations = append(mutations, restorer.mutation(diff.Right))
+	}
+	want := [][]string{
+		{"-", "Age", "2", "updated", "NULL"},
+		{"+", "Age", "2", "updated", "20"},
+		{"+", "NULL", "1", "deleted", "10"},
+	}
+	if diff := cmp.Diff(want, decodeRows(t, w.rows)); diff != "" {
+		t.Errorf("differences mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := session.client.Apply(ctx, mutations); err != nil {
+		t.Fatalf("failed to restore rows: %v", err)
+	}
+	var got [][]string
+	err = session.client.Single().Query(ctx, spanner.Statement{SQL: restorer.table.scanQuery("")}).Do(func(row *spanner.Row) error {
+		values, err := rowValues(row)
+		if err != nil {
+			return err
+		}
+		got = append(got, decodeRows(t, []Row{{Values: values}})...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read rows: %v", err)
+	}
+	want = [][]string{
+		{"4", "not matched", "41"},
+		{"3", "same", "30"},
+		{"2", "updated", "20"},
+		{"1", "deleted", "10"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("restored rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func decodeRows(t *testing.T, rows []Row) [][]string {
+	t.Helper()
+	var decoded [][]string
+	for _, row := range rows {
+		var values []string
+		for _, value := range row.Values {
+			s, err := DecodeColumn(value)
+			if err != nil {
+				t.Fatalf("DecodeColumn() got error: %v", err)
+			}
+			values = append(values, s)
+		}
+		decoded = append(decoded, values)
+	}
+	return decoded
+}
@@ -16,7 +16,12 @@
 
 package main
 
-import "github.com/apstndb/gsqlsep"
+import (
+	"regexp"
+	"strings"
+
+	"github.com/apstndb/gsqlsep"
+)
 
 const (
 	delimiterUndefined  = ""
@@ -30,14 +35,36 @@ type inputStatement struct {
 	delim                    string
 }
 
+// leadingAsyncHintRe matches the `--async` comment at the beginning of a statement, which belongs to
+// the previous statement if it is on the same line as the delimiter of the previous statement.
+var leadingAsyncHintRe = regexp.MustCompile(`(?i)^--\s*async[ \t]*(?:\n\s*|$)`)
+
 func separateInput(input string) []inputStatement {
 	var result []inputStatement
+	var end int // end of the previous statement in the input
 	for _, stmt := range gsqlsep.SeparateInputPreserveComments(input, delimiterVertical) {
-		result = append(result, inputStatement{
+		statement := inputStatement{
 			statement:                stmt.Statement,
 			statementWithoutComments: stmt.StripComments().Statement,
 			delim:                    stmt.Terminator,
-		})
+		}
+
+		// Statements are trimmed, so that they are found in the input to know whether a comment is on the same line as the delimiter.
+		if i := strings.Index(input[end:], stmt.Statement); i >= 0 {
+			start := end + i
+			if len(result) > 0 && !strings.Contains(input[end:start], "\n") {
+				if hint := leadingAsyncHintRe.FindString(statement.statement); hint != "" {
+					// `ALTER TABLE ...; --async` is the same as the comment before the delimiter.
+					result[len(result)-1].statement += " --async"
+					statement.statement = statement.statement[len(hint):]
+				}
+			}
+			end = start + len(stmt.Statement)
+		}
+		if statement.statement == "" && statement.statementWithoutComments == "" && statement.delim == delimiterUndefined {
+			continue
+		}
+		result = append(result, statement)
 	}
 	return result
 }
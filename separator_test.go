@@ -134,6 +134,32 @@ func TestSeparateInput(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc:  "async comment after delim",
+			input: "CREATE INDEX i1 ON t1(col); --async\nCREATE INDEX i2 ON t1(col);\n--async\nSELECT 1; --async is only a comment",
+			want: []inputStatement{
+				{
+					statement:                "CREATE INDEX i1 ON t1(col) --async",
+					statementWithoutComments: "CREATE INDEX i1 ON t1(col)",
+					delim:                    delimiterHorizontal,
+				},
+				{
+					statement:                "CREATE INDEX i2 ON t1(col)",
+					statementWithoutComments: "CREATE INDEX i2 ON t1(col)",
+					delim:                    delimiterHorizontal,
+				},
+				{
+					statement:                "--async\nSELECT 1",
+					statementWithoutComments: "SELECT 1",
+					delim:                    delimiterHorizontal,
+				},
+				{
+					statement:                "--async is only a comment",
+					statementWithoutComments: "",
+					delim:                    delimiterUndefined,
+				},
+			},
+		},
 		{
 			desc:  "new line just after delim",
 			input: "SELECT 1;\n SELECT 2\\G\n",
@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,6 +31,8 @@ import (
 	"google.golang.org/grpc/codes"
 
 	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	instanceapi "cloud.google.com/go/spanner/admin/instance/apiv1"
+	instancepb "cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
 	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
 )
 
@@ -52,12 +56,17 @@ type Session struct {
 	databaseId      string
 	client          *spanner.Client
 	adminClient     *adminapi.DatabaseAdminClient
+	instanceClient  *instanceapi.InstanceAdminClient
 	clientConfig    spanner.ClientConfig
 	clientOpts      []option.ClientOption
-	defaultPriority pb.RequestOptions_Priority
-	directedRead    *pb.DirectedReadOptions
+	systemVariables *systemVariables
+	params          map[string]spanner.GenericColumnValue // Query parameters set by SET PARAM.
+	batch           *batchContext                         // Statements buffered by START BATCH.
 	tc              *transactionContext
 	tcMutex         sync.Mutex // Guard a critical section for transaction.
+	progress        string     // Progress of the running long-running operation.
+	progressMutex   sync.Mutex
+	warnings        []string // Warnings of the running statement, which are printed with its result.
 }
 
 type transactionContext struct {
@@ -68,12 +77,24 @@ type transactionContext struct {
 	roTxn         *spanner.ReadOnlyTransaction
 }
 
-func NewSession(projectId string, instanceId string, databaseId string, priority pb.RequestOptions_Priority, role string, directedRead *pb.DirectedReadOptions, opts ...option.ClientOption) (*Session, error) {
+type batchMode int
+
+const (
+	batchModeDDL batchMode = iota
+	batchModeDML
+)
+
+type batchContext struct {
+	mode batchMode
+	ddls []string
+	dmls []spanner.Statement
+}
+
+func NewSession(projectId string, instanceId string, databaseId string, sysVars *systemVariables, role string, opts ...option.ClientOption) (*Session, error) {
 	ctx := context.Background()
 	dbPath := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectId, instanceId, databaseId)
 	clientConfig := defaultClientConfig
 	clientConfig.DatabaseRole = role
-	clientConfig.DirectedReadOptions = directedRead
 	opts = append(opts, defaultClientOpts...)
 	client, err := spanner.NewClientWithConfig(ctx, dbPath, clientConfig, opts...)
 	if err != nil {
@@ -82,11 +103,19 @@ func NewSession(projectId string, instanceId string, databaseId string, priority
 
 	adminClient, err := adminapi.NewDatabaseAdminClient(ctx, opts...)
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
 
-	if priority == pb.RequestOptions_PRIORITY_UNSPECIFIED {
-		priority = defaultPriority
+	instanceClient, err := instanceapi.NewInstanceAdminClient(ctx, opts...)
+	if err != nil {
+		client.Close()
+		adminClient.Close()
+		return nil, err
+	}
+
+	if sysVars.RPCPriority == pb.RequestOptions_PRIORITY_UNSPECIFIED {
+		sysVars.RPCPriority = defaultPriority
 	}
 
 	session := &Session{
@@ -97,14 +126,24 @@ func NewSession(projectId string, instanceId string, databaseId string, priority
 		clientConfig:    clientConfig,
 		clientOpts:      opts,
 		adminClient:     adminClient,
-		defaultPriority: priority,
-		directedRead:    directedRead,
+		instanceClient:  instanceClient,
+		systemVariables: sysVars,
+		params:          make(map[string]spanner.GenericColumnValue),
 	}
 	go session.startHeartbeat()
 
 	return session, nil
 }
 
+// newStatement creates a statement with the query parameters of the session.
+func (s *Session) newStatement(sql string) spanner.Statement {
+	stmt := spanner.NewStatement(sql)
+	for name, value := range s.params {
+		stmt.Params[name] = value
+	}
+	return stmt
+}
+
 // InReadWriteTransaction returns true if the session is running read-write transaction.
 func (s *Session) InReadWriteTransaction() bool {
 	return s.tc != nil && s.tc.rwTxn != nil
@@ -115,6 +154,16 @@ func (s *Session) InReadOnlyTransaction() bool {
 	return s.tc != nil && s.tc.roTxn != nil
 }
 
+// InBatch returns true if the session is buffering statements by START BATCH.
+func (s *Session) InBatch() bool {
+	return s.batch != nil
+}
+
+// InBatchMode returns true if the session is buffering statements of the given mode.
+func (s *Session) InBatchMode(mode batchMode) bool {
+	return s.batch != nil && s.batch.mode == mode
+}
+
 // BeginReadWriteTransaction starts read-write transaction.
 func (s *Session) BeginReadWriteTransaction(ctx context.Context, priority pb.RequestOptions_Priority, tag string) error {
 	if s.InReadWriteTransaction() {
@@ -123,7 +172,7 @@ func (s *Session) BeginReadWriteTransaction(ctx context.Context, priority pb.Req
 
 	// Use session's priority if transaction priority is not set.
 	if priority == pb.RequestOptions_PRIORITY_UNSPECIFIED {
-		priority = s.defaultPriority
+		priority = s.systemVariables.RPCPriority
 	}
 
 	opts := spanner.TransactionOptions{
@@ -177,21 +226,30 @@ func (s *Session) BeginReadOnlyTransaction(ctx context.Context, typ timestampBou
 		return time.Time{}, errors.New("read-only transaction is already running")
 	}
 
-	txn := s.client.ReadOnlyTransaction()
-	switch typ {
-	case strong:
-		txn = txn.WithTimestampBound(spanner.StrongRead())
-	case exactStaleness:
-		txn = txn.WithTimestampBound(spanner.ExactStaleness(staleness))
-	case readTimestamp:
-		txn = txn.WithTimestampBound(spanner.ReadTimestamp(timestamp))
-	}
-
 	// Use session's priority if transaction priority is not set.
 	if priority == pb.RequestOptions_PRIORITY_UNSPECIFIED {
-		priority = s.defaultPriority
+		priority = s.systemVariables.RPCPriority
+	}
+
+	bound := readOnlyStaleness{typ: typ, staleness: staleness, timestamp: timestamp}
+	if typ == maxStaleness || typ == minReadTimestamp {
+		// Bounded staleness is only supported in single-use transactions,
+		// so we resolve the read timestamp by a single-use query and start the transaction at that timestamp.
+		single := s.client.Single().WithTimestampBound(bound.timestampBound())
+		if err := single.QueryWithOptions(ctx, spanner.NewStatement("SELECT 1"), spanner.QueryOptions{Priority: priority}).Do(func(r *spanner.Row) error {
+			return nil
+		}); err != nil {
+			return time.Time{}, err
+		}
+		ts, err := single.Timestamp()
+		if err != nil {
+			return time.Time{}, err
+		}
+		bound = readOnlyStaleness{typ: readTimestamp, timestamp: ts}
 	}
 
+	txn := s.client.ReadOnlyTransaction().WithTimestampBound(bound.timestampBound())
+
 	// Because google-cloud-go/spanner defers calling BeginTransaction RPC until an actual query is run,
 	// we explicitly run a "SELECT 1" query so that we can determine the timestamp of read-only transaction.
 	opts := spanner.QueryOptions{Priority: priority}
@@ -261,21 +319,20 @@ func (s *Session) RunAnalyzeQuery(ctx context.Context, stmt spanner.Statement) (
 }
 
 func (s *Session) runQueryWithOptions(ctx context.Context, stmt spanner.Statement, opts spanner.QueryOptions) (*spanner.RowIterator, *spanner.ReadOnlyTransaction) {
+	opts.Options = s.systemVariables.queryOptions()
+	opts.RequestTag = s.currentRequestTag()
 	if s.InReadWriteTransaction() {
-		// The current Go Spanner client library does not apply client-level directed read options to read-write transactions.
-		// Therefore, we explicitly set query-level options here to fail the query during a read-write transaction.
-		opts.DirectedReadOptions = s.clientConfig.DirectedReadOptions
-		opts.RequestTag = s.tc.tag
+		s.warnIgnoredDirectedRead()
 		iter := s.tc.rwTxn.QueryWithOptions(ctx, stmt, opts)
 		s.tc.sendHeartbeat = true
 		return iter, nil
 	}
+	opts.DirectedReadOptions = s.systemVariables.DirectedRead
 	if s.InReadOnlyTransaction() {
-		opts.RequestTag = s.tc.tag
 		return s.tc.roTxn.QueryWithOptions(ctx, stmt, opts), s.tc.roTxn
 	}
 
-	txn := s.client.Single()
+	txn := s.client.Single().WithTimestampBound(s.systemVariables.ReadOnlyStaleness.timestampBound())
 	return txn.QueryWithOptions(ctx, stmt, opts), txn
 }
 
@@ -288,10 +345,13 @@ func (s *Session) RunUpdate(ctx context.Context, stmt spanner.Statement, useUpda
 	}
 
 	opts := spanner.QueryOptions{
+		Options:    s.systemVariables.queryOptions(),
 		Priority:   s.currentPriority(),
-		RequestTag: s.tc.tag,
+		RequestTag: s.currentRequestTag(),
 	}
 
+	s.warnIgnoredDirectedRead()
+
 	// Workaround: Usually, we can execute DMLs using Query(ExecuteStreamingSql RPC),
 	// but spannertest doesn't support DMLs execution using ExecuteStreamingSql RPC.
 	// It enforces to use ExecuteSql RPC.
@@ -308,9 +368,68 @@ func (s *Session) RunUpdate(ctx context.Context, stmt spanner.Statement, useUpda
 	return result, columnNames, rowIter.RowCount, rowIter.Metadata, err
 }
 
+// RunBatchUpdate executes DML statements in a single BatchUpdate call on the running read-write transaction.
+// On failure, it returns the affected row counts of the statements which succeeded before the failed one.
+func (s *Session) RunBatchUpdate(ctx context.Context, stmts []spanner.Statement) ([]int64, error) {
+	if !s.InReadWriteTransaction() {
+		return nil, errors.New("read-write transaction is not running")
+	}
+
+	opts := spanner.QueryOptions{
+		Options:    s.systemVariables.queryOptions(),
+		Priority:   s.currentPriority(),
+		RequestTag: s.currentRequestTag(),
+	}
+	s.warnIgnoredDirectedRead()
+	rowCounts, err := s.tc.rwTxn.BatchUpdateWithOptions(ctx, stmts, opts)
+	s.tc.sendHeartbeat = true
+	return rowCounts, err
+}
+
+// warnIgnoredDirectedRead warns that the directed read option is not sent,
+// because directed reads are not supported in read-write transactions.
+func (s *Session) warnIgnoredDirectedRead() {
+	if s.systemVariables.DirectedRead != nil {
+		s.addWarning("directed read option is ignored in read-write transaction")
+	}
+}
+
+// addWarning adds a warning of the running statement unless it has been added.
+func (s *Session) addWarning(warning string) {
+	if !slices.Contains(s.warnings, warning) {
+		s.warnings = append(s.warnings, warning)
+	}
+}
+
+// TakeWarnings returns the warnings added since the last call.
+func (s *Session) TakeWarnings() []string {
+	warnings := s.warnings
+	s.warnings = nil
+	return warnings
+}
+
+// SetProgress sets the progress of the running long-running operation to be shown instead of the progressing mark.
+func (s *Session) SetProgress(progress string) {
+	s.progressMutex.Lock()
+	defer s.progressMutex.Unlock()
+	s.progress = progress
+}
+
+// Progress returns the progress of the running long-running operation, or empty string if there is no progress.
+func (s *Session) Progress() string {
+	s.progressMutex.Lock()
+	defer s.progressMutex.Unlock()
+	return s.progress
+}
+
 func (s *Session) Close() {
 	s.client.Close()
 	s.adminClient.Close()
+	s.instanceClient.Close()
+}
+
+func (s *Session) ProjectPath() string {
+	return fmt.Sprintf("projects/%s", s.projectId)
 }
 
 func (s *Session) DatabasePath() string {
@@ -345,6 +464,70 @@ func (s *Session) DatabaseExists() (bool, error) {
 	}
 }
 
+// ValidateDirectedRead checks that each replica selection of the directed read option matches replicas of the instance configuration,
+// because Cloud Spanner silently ignores directed read options which don't match any replica.
+// If the instance configuration can't be read, e.g. for lack of permission, the check is skipped with a warning.
+func (s *Session) ValidateDirectedRead(ctx context.Context) error {
+	directedRead := s.systemVariables.DirectedRead
+	var selections []*pb.DirectedReadOptions_ReplicaSelection
+	selections = append(selections, directedRead.GetIncludeReplicas().GetReplicaSelections()...)
+	selections = append(selections, directedRead.GetExcludeReplicas().GetReplicaSelections()...)
+	if len(selections) == 0 {
+		return nil
+	}
+
+	instance, err := s.instanceClient.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: s.InstancePath()})
+	if err != nil {
+		s.addWarning(fmt.Sprintf("directed read option is not checked because the instance can't be read: %v", err))
+		return nil
+	}
+	config, err := s.instanceClient.GetInstanceConfig(ctx, &instancepb.GetInstanceConfigRequest{Name: instance.GetConfig()})
+	if err != nil {
+		s.addWarning(fmt.Sprintf("directed read option is not checked because the instance config can't be read: %v", err))
+		return nil
+	}
+	if len(config.GetReplicas()) == 0 {
+		return nil
+	}
+
+	for _, selection := range selections {
+		if !replicasContain(config.GetReplicas(), selection) {
+			return fmt.Errorf("no replica matches %s in instance config %q", formatReplicaSelection(selection), lastPathSegment(config.GetName()))
+		}
+	}
+	return nil
+}
+
+func replicasContain(replicas []*instancepb.ReplicaInfo, selection *pb.DirectedReadOptions_ReplicaSelection) bool {
+	for _, replica := range replicas {
+		if selection.GetLocation() != "" && selection.GetLocation() != replica.GetLocation() {
+			continue
+		}
+		if selection.GetType() != pb.DirectedReadOptions_ReplicaSelection_TYPE_UNSPECIFIED && selection.GetType().String() != replica.GetType().String() {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func formatReplicaSelection(selection *pb.DirectedReadOptions_ReplicaSelection) string {
+	switch {
+	case selection.GetType() == pb.DirectedReadOptions_ReplicaSelection_TYPE_UNSPECIFIED:
+		return selection.GetLocation()
+	case selection.GetLocation() == "":
+		return selection.GetType().String()
+	default:
+		return fmt.Sprintf("%s:%s", selection.GetLocation(), selection.GetType())
+	}
+}
+
+// NewDatabaseClient creates a client for another database in the same instance with the same options as the session.
+func (s *Session) NewDatabaseClient(ctx context.Context, databaseId string) (*spanner.Client, error) {
+	dbPath := fmt.Sprintf("%s/databases/%s", s.InstancePath(), databaseId)
+	return spanner.NewClientWithConfig(ctx, dbPath, s.clientConfig, s.clientOpts...)
+}
+
 // RecreateClient closes the current client and creates a new client for the session.
 func (s *Session) RecreateClient() error {
 	ctx := context.Background()
@@ -361,7 +544,18 @@ func (s *Session) currentPriority() pb.RequestOptions_Priority {
 	if s.tc != nil {
 		return s.tc.priority
 	}
-	return s.defaultPriority
+	return s.systemVariables.RPCPriority
+}
+
+// currentRequestTag returns STATEMENT_TAG if it is set, otherwise the tag of the running transaction.
+func (s *Session) currentRequestTag() string {
+	if s.systemVariables.StatementTag != "" {
+		return s.systemVariables.StatementTag
+	}
+	if s.tc != nil {
+		return s.tc.tag
+	}
+	return ""
 }
 
 // startHeartbeat starts heartbeat for read-write transaction.
@@ -398,33 +592,139 @@ func heartbeat(txn *spanner.ReadWriteStmtBasedTransaction, priority pb.RequestOp
 	return err
 }
 
+// parseDirectedReadOption parses a directed read option in the following form.
+//
+//	[include=]<selection>[,<selection>...][;auto_failover=<bool>]
+//	exclude=<selection>[,<selection>...]
+//
+// <selection> is either <replica_location>[:<replica_type>] or <replica_type>.
+// Empty string disables directed reads.
 func parseDirectedReadOption(directedReadOptionText string) (*pb.DirectedReadOptions, error) {
-	directedReadOption := strings.Split(directedReadOptionText, ":")
-	if len(directedReadOption) > 2 {
-		return nil, fmt.Errorf("directed read option must be in the form of <replica_location>:<replica_type>, but got %q", directedReadOptionText)
+	if strings.TrimSpace(directedReadOptionText) == "" {
+		return nil, nil
+	}
+
+	var include, exclude []*pb.DirectedReadOptions_ReplicaSelection
+	autoFailover := false
+	var autoFailoverSet bool
+	for i, clause := range strings.Split(directedReadOptionText, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(clause), "=")
+		if !found {
+			if i != 0 {
+				return nil, fmt.Errorf("directed read option clause must be in the form of <key>=<value>, but got %q", clause)
+			}
+			key, value = "include", key
+		}
+
+		var err error
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "include":
+			include, err = parseReplicaSelections(value)
+		case "exclude":
+			exclude, err = parseReplicaSelections(value)
+		case "auto_failover":
+			autoFailover, err = strconv.ParseBool(strings.TrimSpace(value))
+			autoFailoverSet = true
+		default:
+			return nil, fmt.Errorf("unknown directed read option: %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	replicaSelection := pb.DirectedReadOptions_ReplicaSelection{
-		Location: directedReadOption[0],
+	switch {
+	case len(include) > 0 && len(exclude) > 0:
+		return nil, errors.New("include and exclude can't be specified at the same time")
+	case len(exclude) > 0 && autoFailoverSet:
+		return nil, errors.New("auto_failover can be specified only with include")
+	case len(exclude) > 0:
+		return &pb.DirectedReadOptions{
+			Replicas: &pb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &pb.DirectedReadOptions_ExcludeReplicas{
+					ReplicaSelections: exclude,
+				},
+			},
+		}, nil
+	case len(include) > 0:
+		return &pb.DirectedReadOptions{
+			Replicas: &pb.DirectedReadOptions_IncludeReplicas_{
+				IncludeReplicas: &pb.DirectedReadOptions_IncludeReplicas{
+					ReplicaSelections:    include,
+					AutoFailoverDisabled: !autoFailover,
+				},
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("directed read option must have at least one replica selection, but got %q", directedReadOptionText)
 	}
+}
 
-	if len(directedReadOption) == 2 {
-		switch strings.ToUpper(directedReadOption[1]) {
-		case "READ_ONLY":
-			replicaSelection.Type = pb.DirectedReadOptions_ReplicaSelection_READ_ONLY
-		case "READ_WRITE":
-			replicaSelection.Type = pb.DirectedReadOptions_ReplicaSelection_READ_WRITE
-		default:
-			return nil, fmt.Errorf("<replica_type> must be either READ_WRITE or READ_ONLY, but got %q", directedReadOption[1])
+func parseReplicaSelections(text string) ([]*pb.DirectedReadOptions_ReplicaSelection, error) {
+	var selections []*pb.DirectedReadOptions_ReplicaSelection
+	for _, selectionText := range strings.Split(text, ",") {
+		selection, err := parseReplicaSelection(strings.TrimSpace(selectionText))
+		if err != nil {
+			return nil, err
 		}
+		selections = append(selections, selection)
 	}
+	return selections, nil
+}
 
-	return &pb.DirectedReadOptions{
-		Replicas: &pb.DirectedReadOptions_IncludeReplicas_{
-			IncludeReplicas: &pb.DirectedReadOptions_IncludeReplicas{
-				ReplicaSelections:    []*pb.DirectedReadOptions_ReplicaSelection{&replicaSelection},
-				AutoFailoverDisabled: true,
-			},
-		},
-	}, nil
+func parseReplicaSelection(text string) (*pb.DirectedReadOptions_ReplicaSelection, error) {
+	// <replica_type> alone selects replicas of the type in any location.
+	if typ, err := parseReplicaType(text); err == nil {
+		return &pb.DirectedReadOptions_ReplicaSelection{Type: typ}, nil
+	}
+
+	location, typeText, found := strings.Cut(text, ":")
+	if location == "" || strings.Contains(typeText, ":") {
+		return nil, fmt.Errorf("replica selection must be in the form of <replica_location>:<replica_type>, but got %q", text)
+	}
+
+	selection := &pb.DirectedReadOptions_ReplicaSelection{Location: location}
+	if found {
+		typ, err := parseReplicaType(typeText)
+		if err != nil {
+			return nil, err
+		}
+		selection.Type = typ
+	}
+	return selection, nil
+}
+
+func parseReplicaType(text string) (pb.DirectedReadOptions_ReplicaSelection_Type, error) {
+	switch strings.ToUpper(text) {
+	case "READ_ONLY":
+		return pb.DirectedReadOptions_ReplicaSelection_READ_ONLY, nil
+	case "READ_WRITE":
+		return pb.DirectedReadOptions_ReplicaSelection_READ_WRITE, nil
+	default:
+		return pb.DirectedReadOptions_ReplicaSelection_TYPE_UNSPECIFIED, fmt.Errorf("<replica_type> must be either READ_WRITE or READ_ONLY, but got %q", text)
+	}
+}
+
+// formatDirectedReadOption formats the directed read option in the form accepted by parseDirectedReadOption.
+func formatDirectedReadOption(opts *pb.DirectedReadOptions) string {
+	if exclude := opts.GetExcludeReplicas(); exclude != nil {
+		return "exclude=" + formatReplicaSelections(exclude.GetReplicaSelections())
+	}
+	include := opts.GetIncludeReplicas()
+	if include == nil {
+		return ""
+	}
+	s := formatReplicaSelections(include.GetReplicaSelections())
+	if !include.GetAutoFailoverDisabled() {
+		s += ";auto_failover=true"
+	}
+	return s
+}
+
+func formatReplicaSelections(selections []*pb.DirectedReadOptions_ReplicaSelection) string {
+	var strs []string
+	for _, selection := range selections {
+		strs = append(strs, formatReplicaSelection(selection))
+	}
+	return strings.Join(strs, ",")
 }
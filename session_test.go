@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,8 +13,15 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instancepb "cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
 	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
 )
 
@@ -66,7 +75,7 @@ func TestRequestPriority(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			defer recorder.flush()
 
-			session, err := NewSession("project", "instance", "database", test.sessionPriority, "role", nil, option.WithGRPCConn(conn))
+			session, err := NewSession("project", "instance", "database", &systemVariables{RPCPriority: test.sessionPriority}, "role", option.WithGRPCConn(conn))
 			if err != nil {
 				t.Fatalf("failed to create spanner-cli session: %v", err)
 			}
@@ -119,6 +128,322 @@ func TestRequestPriority(t *testing.T) {
 	}
 }
 
+func TestQueryParameters(t *testing.T) {
+	server := setupTestServer(t)
+
+	var recorder requestRecorder
+	unaryInterceptor, streamInterceptor := recordRequestsInterceptors(&recorder)
+	opts := []grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(unaryInterceptor),
+		grpc.WithStreamInterceptor(streamInterceptor),
+	}
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, server.Addr, opts...)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	session, err := NewSession("project", "instance", "database", &systemVariables{}, "role", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create spanner-cli session: %v", err)
+	}
+
+	for _, input := range []string{"SET PARAM id = 1", "SET PARAM unused STRING", "UNSET PARAM unused"} {
+		stmt, err := BuildStatement(input)
+		if err != nil {
+			t.Fatalf("BuildStatement(%q) got error: %v", input, err)
+		}
+		if _, err := stmt.Execute(ctx, session); err != nil {
+			t.Fatalf("failed to execute %q: %v", input, err)
+		}
+	}
+
+	recorder.flush()
+	iter, _ := session.RunQuery(ctx, session.newStatement("SELECT * FROM t1 WHERE Id = @id"))
+	if err := iter.Do(func(r *spanner.Row) error {
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to run query: %v", err)
+	}
+
+	var found bool
+	for _, r := range recorder.requests {
+		req, ok := r.(*pb.ExecuteSqlRequest)
+		if !ok {
+			continue
+		}
+		found = true
+		wantParams := &structpb.Struct{Fields: map[string]*structpb.Value{"id": structpb.NewStringValue("1")}}
+		if diff := cmp.Diff(wantParams, req.GetParams(), protocmp.Transform()); diff != "" {
+			t.Errorf("params mismatch (-want +got):\n%s", diff)
+		}
+		wantTypes := map[string]*pb.Type{"id": {Code: pb.TypeCode_INT64}}
+		if diff := cmp.Diff(wantTypes, req.GetParamTypes(), protocmp.Transform()); diff != "" {
+			t.Errorf("param types mismatch (-want +got):\n%s", diff)
+		}
+	}
+	if !found {
+		t.Errorf("ExecuteSqlRequest is not sent")
+	}
+}
+
+func TestBatchDml(t *testing.T) {
+	server := setupTestServer(t)
+
+	// spannertest doesn't support ExecuteBatchDml RPC, so we intercept it and return a fake response.
+	var requests []*pb.ExecuteBatchDmlRequest
+	batchDmlInterceptor := func(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		batchReq, ok := req.(*pb.ExecuteBatchDmlRequest)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		requests = append(requests, batchReq)
+		resp := reply.(*pb.ExecuteBatchDmlResponse)
+		for i := range batchReq.GetStatements() {
+			resp.ResultSets = append(resp.ResultSets, &pb.ResultSet{
+				Stats: &pb.ResultSetStats{RowCount: &pb.ResultSetStats_RowCountExact{RowCountExact: int64(i + 1)}},
+			})
+		}
+		return nil
+	}
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, server.Addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(batchDmlInterceptor))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	session, err := NewSession("project", "instance", "database", &systemVariables{}, "role", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create spanner-cli session: %v", err)
+	}
+
+	dmls := []string{"INSERT INTO t1 (Id) VALUES (1)", "UPDATE t1 SET Id = 2 WHERE Id = 1"}
+	for _, input := range append([]string{"START BATCH DML"}, dmls...) {
+		stmt, err := BuildStatement(input)
+		if err != nil {
+			t.Fatalf("BuildStatement(%q) got error: %v", input, err)
+		}
+		if _, err := stmt.Execute(ctx, session); err != nil {
+			t.Fatalf("failed to execute %q: %v", input, err)
+		}
+	}
+	if len(requests) > 0 {
+		t.Errorf("DMLs in batch must be buffered, but ExecuteBatchDml is called")
+	}
+
+	result, err := (&RunBatchStatement{}).Execute(ctx, session)
+	if err != nil {
+		t.Fatalf("failed to run batch: %v", err)
+	}
+	if session.InBatch() || session.InReadWriteTransaction() {
+		t.Errorf("batch and implicit transaction must be finished after RUN BATCH")
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("ExecuteBatchDml must be called once, but called %d times", len(requests))
+	}
+	var gotDmls []string
+	for _, stmt := range requests[0].GetStatements() {
+		gotDmls = append(gotDmls, stmt.GetSql())
+	}
+	if diff := cmp.Diff(dmls, gotDmls); diff != "" {
+		t.Errorf("statements mismatch (-want +got):\n%s", diff)
+	}
+
+	wantRows := []Row{stringRow(dmls[0], "1"), stringRow(dmls[1], "2")}
+	if diff := cmp.Diff(wantRows, result.Rows, protocmp.Transform()); diff != "" {
+		t.Errorf("rows mismatch (-want +got):\n%s", diff)
+	}
+	if result.AffectedRows != 3 {
+		t.Errorf("affected rows mismatch: got = %d, want = 3", result.AffectedRows)
+	}
+}
+
+func TestBatchDdl(t *testing.T) {
+	server := setupTestServer(t)
+
+	var recorder requestRecorder
+	unaryInterceptor, streamInterceptor := recordRequestsInterceptors(&recorder)
+	opts := []grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(unaryInterceptor),
+		grpc.WithStreamInterceptor(streamInterceptor),
+	}
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, server.Addr, opts...)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	session, err := NewSession("project", "instance", "database", &systemVariables{}, "role", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create spanner-cli session: %v", err)
+	}
+
+	ddls := []string{"CREATE TABLE t2 (Id INT64) PRIMARY KEY (Id)", "CREATE INDEX idx ON t2 (Id)"}
+	for _, input := range append([]string{"START BATCH DDL"}, ddls...) {
+		stmt, err := BuildStatement(input)
+		if err != nil {
+			t.Fatalf("BuildStatement(%q) got error: %v", input, err)
+		}
+		if _, err := stmt.Execute(ctx, session); err != nil {
+			t.Fatalf("failed to execute %q: %v", input, err)
+		}
+	}
+
+	result, err := (&ShowBatchStatement{}).Execute(ctx, session)
+	if err != nil {
+		t.Fatalf("failed to show batch: %v", err)
+	}
+	wantRows := []Row{stringRow(ddls[0]), stringRow(ddls[1])}
+	if diff := cmp.Diff(wantRows, result.Rows, protocmp.Transform()); diff != "" {
+		t.Errorf("SHOW BATCH mismatch (-want +got):\n%s", diff)
+	}
+
+	recorder.flush()
+	if _, err := (&RunBatchStatement{}).Execute(ctx, session); err != nil {
+		t.Fatalf("failed to run batch: %v", err)
+	}
+	if session.InBatch() {
+		t.Errorf("batch must be finished after RUN BATCH")
+	}
+
+	var found bool
+	for _, r := range recorder.requests {
+		req, ok := r.(*adminpb.UpdateDatabaseDdlRequest)
+		if !ok {
+			continue
+		}
+		found = true
+		if diff := cmp.Diff(ddls, req.GetStatements()); diff != "" {
+			t.Errorf("statements mismatch (-want +got):\n%s", diff)
+		}
+	}
+	if !found {
+		t.Errorf("UpdateDatabaseDdlRequest is not sent")
+	}
+}
+
+func TestBackupSchedules(t *testing.T) {
+	server := setupTestServer(t)
+
+	// spannertest doesn't support backup schedule RPCs, so we intercept them and keep schedules in a map.
+	schedules := make(map[string]*adminpb.BackupSchedule)
+	updateTime := timestamppb.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	backupScheduleInterceptor := func(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch req := req.(type) {
+		case *adminpb.CreateBackupScheduleRequest:
+			schedule := proto.Clone(req.GetBackupSchedule()).(*adminpb.BackupSchedule)
+			schedule.Name = req.GetParent() + "/backupSchedules/" + req.GetBackupScheduleId()
+			schedule.UpdateTime = updateTime
+			schedules[schedule.GetName()] = schedule
+			proto.Merge(reply.(*adminpb.BackupSchedule), schedule)
+		case *adminpb.UpdateBackupScheduleRequest:
+			schedule, ok := schedules[req.GetBackupSchedule().GetName()]
+			if !ok {
+				return status.Errorf(codes.NotFound, "backup schedule not found: %s", req.GetBackupSchedule().GetName())
+			}
+			for _, path := range req.GetUpdateMask().GetPaths() {
+				switch path {
+				case "spec.cron_spec.text":
+					schedule.Spec = req.GetBackupSchedule().GetSpec()
+				case "retention_duration":
+					schedule.RetentionDuration = req.GetBackupSchedule().GetRetentionDuration()
+				default:
+					return status.Errorf(codes.InvalidArgument, "unsupported path: %s", path)
+				}
+			}
+			proto.Merge(reply.(*adminpb.BackupSchedule), schedule)
+		case *adminpb.DeleteBackupScheduleRequest:
+			if _, ok := schedules[req.GetName()]; !ok {
+				return status.Errorf(codes.NotFound, "backup schedule not found: %s", req.GetName())
+			}
+			delete(schedules, req.GetName())
+		case *adminpb.ListBackupSchedulesRequest:
+			resp := reply.(*adminpb.ListBackupSchedulesResponse)
+			for name, schedule := range schedules {
+				if strings.HasPrefix(name, req.GetParent()+"/") {
+					resp.BackupSchedules = append(resp.BackupSchedules, schedule)
+				}
+			}
+			sort.Slice(resp.BackupSchedules, func(i, j int) bool {
+				return resp.BackupSchedules[i].GetName() < resp.BackupSchedules[j].GetName()
+			})
+		default:
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return nil
+	}
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, server.Addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(backupScheduleInterceptor))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	session, err := NewSession("project", "instance", "database", &systemVariables{}, "role", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create spanner-cli session: %v", err)
+	}
+
+	for _, test := range []struct {
+		input string
+		want  *Result
+	}{
+		{
+			input: "CREATE BACKUP SCHEDULE s1 CRON '0 2 * * *' RETENTION 7d",
+			want:  &Result{IsMutation: true},
+		},
+		{
+			input: "CREATE BACKUP SCHEDULE s2 CRON '0 */4 * * *' RETENTION 36h INCREMENTAL",
+			want:  &Result{IsMutation: true},
+		},
+		{
+			input: "ALTER BACKUP SCHEDULE s1 CRON '30 1 * * *' RETENTION 14d",
+			want:  &Result{IsMutation: true},
+		},
+		{
+			input: "SHOW BACKUP SCHEDULES",
+			want: &Result{
+				ColumnNames: showBackupSchedulesColumnNames,
+				Rows: []Row{
+					stringRow("s1", "30 1 * * *", "14d", "FULL", "2024-01-01T00:00:00Z"),
+					stringRow("s2", "0 */4 * * *", "36h0m0s", "INCREMENTAL", "2024-01-01T00:00:00Z"),
+				},
+				AffectedRows: 2,
+			},
+		},
+		{
+			input: "DROP BACKUP SCHEDULE s2",
+			want:  &Result{IsMutation: true},
+		},
+		{
+			input: "SHOW BACKUP SCHEDULES",
+			want: &Result{
+				ColumnNames:  showBackupSchedulesColumnNames,
+				Rows:         []Row{stringRow("s1", "30 1 * * *", "14d", "FULL", "2024-01-01T00:00:00Z")},
+				AffectedRows: 1,
+			},
+		},
+	} {
+		stmt, err := BuildStatement(test.input)
+		if err != nil {
+			t.Fatalf("BuildStatement(%q) got error: %v", test.input, err)
+		}
+		got, err := stmt.Execute(ctx, session)
+		if err != nil {
+			t.Fatalf("failed to execute %q: %v", test.input, err)
+		}
+		if diff := cmp.Diff(test.want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("%q result mismatch (-want +got):\n%s", test.input, diff)
+		}
+	}
+
+	if _, err := (&DropBackupScheduleStatement{Schedule: "s2"}).Execute(ctx, session); status.Code(err) != codes.NotFound {
+		t.Errorf("DROP BACKUP SCHEDULE of a missing schedule got error %v, but want NotFound", err)
+	}
+}
+
 func TestParseDirectedReadOption(t *testing.T) {
 	for _, tt := range []struct {
 		desc   string
@@ -180,6 +505,80 @@ func TestParseDirectedReadOption(t *testing.T) {
 			option: "us-central1:READONLY",
 			want:   nil,
 		},
+		{
+			desc:   "use multiple replica selections",
+			option: "us-central1:READ_WRITE,READ_ONLY",
+			want: &pb.DirectedReadOptions{
+				Replicas: &pb.DirectedReadOptions_IncludeReplicas_{
+					IncludeReplicas: &pb.DirectedReadOptions_IncludeReplicas{
+						ReplicaSelections: []*pb.DirectedReadOptions_ReplicaSelection{
+							{
+								Location: "us-central1",
+								Type:     pb.DirectedReadOptions_ReplicaSelection_READ_WRITE,
+							},
+							{
+								Type: pb.DirectedReadOptions_ReplicaSelection_READ_ONLY,
+							},
+						},
+						AutoFailoverDisabled: true,
+					},
+				},
+			},
+		},
+		{
+			desc:   "use include with auto failover",
+			option: "include=us-central1;auto_failover=true",
+			want: &pb.DirectedReadOptions{
+				Replicas: &pb.DirectedReadOptions_IncludeReplicas_{
+					IncludeReplicas: &pb.DirectedReadOptions_IncludeReplicas{
+						ReplicaSelections: []*pb.DirectedReadOptions_ReplicaSelection{
+							{
+								Location: "us-central1",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:   "use exclude",
+			option: "exclude=us-east1,us-west1:READ_ONLY",
+			want: &pb.DirectedReadOptions{
+				Replicas: &pb.DirectedReadOptions_ExcludeReplicas_{
+					ExcludeReplicas: &pb.DirectedReadOptions_ExcludeReplicas{
+						ReplicaSelections: []*pb.DirectedReadOptions_ReplicaSelection{
+							{
+								Location: "us-east1",
+							},
+							{
+								Location: "us-west1",
+								Type:     pb.DirectedReadOptions_ReplicaSelection_READ_ONLY,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:   "use empty option",
+			option: "",
+			want:   nil,
+		},
+		{
+			desc:   "use include and exclude",
+			option: "include=us-central1;exclude=us-east1",
+			want:   nil,
+		},
+		{
+			desc:   "use exclude with auto failover",
+			option: "exclude=us-east1;auto_failover=true",
+			want:   nil,
+		},
+		{
+			desc:   "use unknown key",
+			option: "us-central1;failover=true",
+			want:   nil,
+		},
 	} {
 		t.Run(tt.desc, func(t *testing.T) {
 			got, _ := parseDirectedReadOption(tt.option)
@@ -236,3 +635,110 @@ func (s *recordRequestsStream) SendMsg(m interface{}) error {
 	s.recorder.requests = append(s.recorder.requests, m)
 	return s.ClientStream.SendMsg(m)
 }
+
+func TestReplicasContain(t *testing.T) {
+	replicas := []*instancepb.ReplicaInfo{
+		{Location: "us-central1", Type: instancepb.ReplicaInfo_READ_WRITE},
+		{Location: "us-east1", Type: instancepb.ReplicaInfo_READ_ONLY},
+	}
+	for _, tt := range []struct {
+		desc      string
+		selection *pb.DirectedReadOptions_ReplicaSelection
+		want      bool
+	}{
+		{
+			desc:      "location only",
+			selection: &pb.DirectedReadOptions_ReplicaSelection{Location: "us-east1"},
+			want:      true,
+		},
+		{
+			desc:      "location and type",
+			selection: &pb.DirectedReadOptions_ReplicaSelection{Location: "us-central1", Type: pb.DirectedReadOptions_ReplicaSelection_READ_WRITE},
+			want:      true,
+		},
+		{
+			desc:      "type only",
+			selection: &pb.DirectedReadOptions_ReplicaSelection{Type: pb.DirectedReadOptions_ReplicaSelection_READ_ONLY},
+			want:      true,
+		},
+		{
+			desc:      "unknown location",
+			selection: &pb.DirectedReadOptions_ReplicaSelection{Location: "asia-northeast1"},
+			want:      false,
+		},
+		{
+			desc:      "type mismatch",
+			selection: &pb.DirectedReadOptions_ReplicaSelection{Location: "us-east1", Type: pb.DirectedReadOptions_ReplicaSelection_READ_WRITE},
+			want:      false,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := replicasContain(replicas, tt.selection); got != tt.want {
+				t.Errorf("replicasContain(%v) = %v, but want = %v", tt.selection, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectedReadWarnings(t *testing.T) {
+	server := setupTestServer(t)
+
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, server.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	directedRead, err := parseDirectedReadOption("us-east1")
+	if err != nil {
+		t.Fatalf("failed to parse directed read option: %v", err)
+	}
+	session, err := NewSession("project", "instance", "database", &systemVariables{DirectedRead: directedRead}, "role", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create spanner-cli session: %v", err)
+	}
+
+	// The test server doesn't serve the instance admin API, so the option can't be checked.
+	if err := session.ValidateDirectedRead(ctx); err != nil {
+		t.Fatalf("ValidateDirectedRead() got error: %v", err)
+	}
+	if warnings := session.TakeWarnings(); len(warnings) != 1 || !strings.HasPrefix(warnings[0], "directed read option is not checked because the instance can't be read") {
+		t.Errorf("ValidateDirectedRead() warnings = %q, but want a warning about the unchecked option", warnings)
+	}
+
+	for _, tt := range []struct {
+		input string
+		want  []string
+	}{
+		{input: "SELECT * FROM t1", want: nil},
+		{input: "BEGIN", want: nil},
+		{input: "SELECT * FROM t1", want: []string{"directed read option is ignored in read-write transaction"}},
+		{input: "ROLLBACK", want: nil},
+	} {
+		stmt, err := BuildStatement(tt.input)
+		if err != nil {
+			t.Fatalf("BuildStatement(%q) got error: %v", tt.input, err)
+		}
+		if _, err := stmt.Execute(ctx, session); err != nil {
+			t.Fatalf("failed to execute %q: %v", tt.input, err)
+		}
+		if diff := cmp.Diff(tt.want, session.TakeWarnings()); diff != "" {
+			t.Errorf("%q: warnings mismatch (-want +got):\n%s", tt.input, diff)
+		}
+	}
+
+	// The test server doesn't run DML with ExecuteStreamingSql, so use ExecuteSql.
+	if err := session.BeginReadWriteTransaction(ctx, pb.RequestOptions_PRIORITY_UNSPECIFIED, ""); err != nil {
+		t.Fatalf("failed to begin read-write transaction: %v", err)
+	}
+	defer session.RollbackReadWriteTransaction(ctx)
+	for i := 0; i < 2; i++ {
+		if _, _, _, _, err := session.RunUpdate(ctx, spanner.NewStatement("DELETE FROM t1 WHERE Id = 1"), true); err != nil {
+			t.Fatalf("failed to run update: %v", err)
+		}
+	}
+	want := []string{"directed read option is ignored in read-write transaction"}
+	if diff := cmp.Diff(want, session.TakeWarnings()); diff != "" {
+		t.Errorf("RunUpdate() warnings mismatch (-want +got):\n%s", diff)
+	}
+}
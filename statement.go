@@ -20,16 +20,27 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
 	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instancepb "cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
 	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/gsqlsep"
 	"google.golang.org/api/iterator"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // Partitioned DML tends to take long time to be finished.
@@ -40,6 +51,18 @@ type Statement interface {
 	Execute(ctx context.Context, session *Session) (*Result, error)
 }
 
+// streamingStatement is a statement which can write rows to the output as they arrive instead of keeping them in Result.
+type streamingStatement interface {
+	Statement
+	ExecuteStream(ctx context.Context, session *Session, w resultWriter) (*Result, error)
+}
+
+// scriptStatement is a statement which writes an SQL script to the output instead of rows.
+type scriptStatement interface {
+	Statement
+	ExecuteScript(ctx context.Context, session *Session, out io.Writer) (*Result, error)
+}
+
 // rowCountType is type of modified rows count by DML.
 type rowCountType int
 
@@ -64,10 +87,30 @@ type Result struct {
 
 	// ColumnTypes will be printed in `--verbose` mode if it is not empty
 	ColumnTypes []*pb.StructType_Field
+
+	// Warnings are printed to stderr before the result
+	Warnings []string
+
+	// Summary is printed after the rows, for example, the counts of rows compared by COMPARE TABLE
+	Summary string
+
+	// Streamed is true if rows have been written to the output instead of being kept in Rows
+	Streamed bool
 }
 
+// Row is a row of a result.
+// Values keep their Cloud Spanner types, and they are rendered by a valueFormatter on output.
 type Row struct {
-	Columns []string
+	Values []spanner.GenericColumnValue
+}
+
+// stringRow creates a row of STRING values for statements which don't return query results.
+func stringRow(columns ...string) Row {
+	values := make([]spanner.GenericColumnValue, len(columns))
+	for i, column := range columns {
+		values[i] = stringValue(column)
+	}
+	return Row{Values: values}
 }
 
 // QueryStats contains query statistics.
@@ -99,6 +142,27 @@ var (
 	truncateTableRe  = regexp.MustCompile(`(?is)^TRUNCATE\s+TABLE\s+(.+)$`)
 	analyzeRe        = regexp.MustCompile(`(?is)^ANALYZE$`)
 
+	// Backup
+	createBackupRe         = regexp.MustCompile(`(?is)^CREATE\s+BACKUP\s+(\S+)(?:\s+EXPIRE\s+(IN|AT)\s+(\S+))?(?:\s+VERSION_TIME\s+(\S+))?$`)
+	copyBackupRe           = regexp.MustCompile(`(?is)^COPY\s+BACKUP\s+(\S+)\s+TO\s+(\S+)(?:\s+EXPIRE\s+(IN|AT)\s+(\S+))?$`)
+	alterBackupRe          = regexp.MustCompile(`(?is)^ALTER\s+BACKUP\s+(\S+)\s+EXPIRE\s+(IN|AT)\s+(\S+)$`)
+	dropBackupRe           = regexp.MustCompile(`(?is)^DROP\s+BACKUP\s+(\S+)$`)
+	showBackupsRe          = regexp.MustCompile(`(?is)^SHOW\s+BACKUPS$`)
+	showBackupOperationsRe = regexp.MustCompile(`(?is)^SHOW\s+BACKUP\s+OPERATIONS$`)
+	restoreDatabaseRe      = regexp.MustCompile(`(?is)^RESTORE\s+DATABASE\s+(\S+)\s+FROM\s+BACKUP\s+(\S+)$`)
+
+	// Backup schedule
+	createBackupScheduleRe = regexp.MustCompile(`(?is)^CREATE\s+BACKUP\s+SCHEDULE\s+(\S+)\s+CRON\s+('.*'|".*")\s+RETENTION\s+(\S+)(?:\s+(FULL|INCREMENTAL))?$`)
+	alterBackupScheduleRe  = regexp.MustCompile(`(?is)^ALTER\s+BACKUP\s+SCHEDULE\s+(\S+)(?:\s+CRON\s+('.*'|".*"))?(?:\s+RETENTION\s+(\S+))?$`)
+	dropBackupScheduleRe   = regexp.MustCompile(`(?is)^DROP\s+BACKUP\s+SCHEDULE\s+(\S+)$`)
+	showBackupSchedulesRe  = regexp.MustCompile(`(?is)^SHOW\s+BACKUP\s+SCHEDULES$`)
+
+	// A trailing `--async` comment of DDL statement requests not to wait for the operation.
+	// The comment after the delimiter on the same line is moved before the delimiter by separateInput.
+	asyncDdlRe = regexp.MustCompile(`(?i)--\s*async\s*$`)
+	// `--async;` comments out the delimiter, so that the statement is joined with the next one.
+	commentedAsyncDelimiterRe = regexp.MustCompile(`(?im)--[ \t]*async[ \t]*(;|\\G)[ \t]*$`)
+
 	// DML
 	dmlRe = regexp.MustCompile(`(?is)^(INSERT|UPDATE|DELETE)\s+.+$`)
 
@@ -107,29 +171,72 @@ var (
 	// https://cloud.google.com/spanner/docs/dml-partitioned#features_that_arent_supported
 	pdmlRe = regexp.MustCompile(`(?is)^PARTITIONED\s+((?:INSERT|UPDATE|DELETE)\s+.+$)`)
 
+	// Partitioned export, which executes partitions of the query in parallel
+	partitionedExportRe = regexp.MustCompile(`(?is)^PARTITIONED\s+EXPORT\s+QUERY\s+(.+)\s+TO\s+('.*'|".*")(?:\s+FORMAT\s+(PARQUET|AVRO))?(?:\s+WORKERS\s+(\d+))?(\s+WITH\s+DATA\s+BOOST)?(\s+FILE\s+PER\s+PARTITION)?$`)
+
 	// Transaction
 	beginRwRe  = regexp.MustCompile(`(?is)^BEGIN(?:\s+RW)?(?:\s+PRIORITY\s+(HIGH|MEDIUM|LOW))?(?:\s+TAG\s+(.+))?$`)
-	beginRoRe  = regexp.MustCompile(`(?is)^BEGIN\s+RO(?:\s+([^\s]+))?(?:\s+PRIORITY\s+(HIGH|MEDIUM|LOW))?(?:\s+TAG\s+(.+))?$`)
+	beginRoRe  = regexp.MustCompile(`(?is)^BEGIN\s+RO(?:\s+(?:(EXACT_STALENESS|MAX_STALENESS|READ_TIMESTAMP|MIN_READ_TIMESTAMP)\s+)?([^\s]+))?(?:\s+PRIORITY\s+(HIGH|MEDIUM|LOW))?(?:\s+TAG\s+(.+))?$`)
 	commitRe   = regexp.MustCompile(`(?is)^COMMIT$`)
 	rollbackRe = regexp.MustCompile(`(?is)^ROLLBACK$`)
 	closeRe    = regexp.MustCompile(`(?is)^CLOSE$`)
 
+	// Batch
+	startBatchRe = regexp.MustCompile(`(?is)^START\s+BATCH\s+(DDL|DML)$`)
+	runBatchRe   = regexp.MustCompile(`(?is)^RUN\s+BATCH$`)
+	abortBatchRe = regexp.MustCompile(`(?is)^ABORT\s+BATCH$`)
+	showBatchRe  = regexp.MustCompile(`(?is)^SHOW\s+BATCH$`)
+
+	// Long-running operation
+	showOperationsRe  = regexp.MustCompile(`(?is)^SHOW\s+OPERATIONS$`)
+	showOperationRe   = regexp.MustCompile(`(?is)^SHOW\s+OPERATION\s+(\S+)$`)
+	waitOperationRe   = regexp.MustCompile(`(?is)^WAIT\s+OPERATION\s+(\S+)$`)
+	cancelOperationRe = regexp.MustCompile(`(?is)^CANCEL\s+OPERATION\s+(\S+)$`)
+
 	// Other
 	exitRe            = regexp.MustCompile(`(?is)^EXIT$`)
 	useRe             = regexp.MustCompile(`(?is)^USE\s+([^\s]+)(?:\s+ROLE\s+(.+))?$`)
 	showDatabasesRe   = regexp.MustCompile(`(?is)^SHOW\s+DATABASES$`)
+	showInstancesRe   = regexp.MustCompile(`(?is)^SHOW\s+INSTANCES$`)
+	showConfigsRe     = regexp.MustCompile(`(?is)^SHOW\s+INSTANCE\s+CONFIGS$`)
+	showInstanceRe    = regexp.MustCompile(`(?is)^SHOW\s+INSTANCE(?:\s+(\S+))?$`)
 	showCreateTableRe = regexp.MustCompile(`(?is)^SHOW\s+CREATE\s+TABLE\s+(.+)$`)
 	showTablesRe      = regexp.MustCompile(`(?is)^SHOW\s+TABLES(?:\s+(.+))?$`)
 	showColumnsRe     = regexp.MustCompile(`(?is)^(?:SHOW\s+COLUMNS\s+FROM)\s+(.+)$`)
 	showIndexRe       = regexp.MustCompile(`(?is)^SHOW\s+(?:INDEX|INDEXES|KEYS)\s+FROM\s+(.+)$`)
 	explainRe         = regexp.MustCompile(`(?is)^EXPLAIN\s+(ANALYZE\s+)?(.+)$`)
 	describeRe        = regexp.MustCompile(`(?is)^DESCRIBE\s+(.+)$`)
+	setParamRe        = regexp.MustCompile(`(?is)^SET\s+PARAM\s+@?(\w+)(?:\s*=\s*|\s+)(.+)$`)
+	unsetParamRe      = regexp.MustCompile(`(?is)^UNSET\s+PARAM\s+@?(\w+)$`)
+	showParamsRe      = regexp.MustCompile(`(?is)^SHOW\s+PARAMS$`)
+	setRe             = regexp.MustCompile(`(?is)^SET\s+(\w+)\s*=\s*(.+)$`)
+	showVariableRe    = regexp.MustCompile(`(?is)^SHOW\s+VARIABLE\s+(\w+)$`)
+	showVariablesRe   = regexp.MustCompile(`(?is)^SHOW\s+VARIABLES$`)
+	dumpDatabaseRe    = regexp.MustCompile(`(?is)^DUMP\s+DATABASE$`)
+	dumpTablesRe      = regexp.MustCompile(`(?is)^DUMP\s+TABLES?\s+(.+)$`)
+	exportQueryRe     = regexp.MustCompile(`(?is)^EXPORT\s+QUERY\s+(.+)\s+TO\s+('.*'|".*")(?:\s+FORMAT\s+(PARQUET|AVRO))?$`)
+	diffTableRe       = regexp.MustCompile(`(?is)^DIFF\s+TABLE\s+(\S+)\s+BETWEEN\s+(.+?)\s+AND\s+(.+)$`)
+	restoreRowsRe     = regexp.MustCompile(`(?is)^RESTORE\s+ROWS\s+FROM\s+(\S+)\s+AS\s+OF\s+(.+?)(?:\s+WHERE\s+(.+))?$`)
+	compareTableRe    = regexp.MustCompile(`(?is)^COMPARE\s+TABLE\s+(\S+)\s+WITH\s+(\S+)$`)
+	compareQueryRe    = regexp.MustCompile(`(?is)^COMPARE\s+QUERY\s+(.+)\s+WITH\s+(\S+)\s+KEY\s*\((.+)\)$`)
+	loadDataRe        = regexp.MustCompile(`(?is)^LOAD\s+DATA\s+FROM\s+('.*'|".*")\s+INTO\s+TABLE\s+(\S+)(?:\s+FORMAT\s+(CSV|JSONL|PARQUET|AVRO))?(?:\s+MODE\s+(INSERT|INSERT_OR_UPDATE|REPLACE))?$`)
 )
 
 var (
-	explainColumnNames        = []string{"ID", "Query_Execution_Plan"}
-	explainAnalyzeColumnNames = []string{"ID", "Query_Execution_Plan", "Rows_Returned", "Executions", "Total_Latency"}
-	describeColumnNames       = []string{"Column_Name", "Column_Type"}
+	explainColumnNames             = []string{"ID", "Query_Execution_Plan"}
+	explainAnalyzeColumnNames      = []string{"ID", "Query_Execution_Plan", "Rows_Returned", "Executions", "Total_Latency"}
+	describeColumnNames            = []string{"Column_Name", "Column_Type"}
+	showParamsColumnNames          = []string{"Param_Name", "Param_Type", "Param_Value"}
+	showVariablesColumnNames       = []string{"Variable_Name", "Value"}
+	batchDmlColumnNames            = []string{"DML", "Rows_Affected"}
+	showBatchColumnNames           = []string{"Statement"}
+	asyncDdlColumnNames            = []string{"Operation_Id"}
+	showBackupsColumnNames         = []string{"Backup", "Database", "State", "Create_Time", "Version_Time", "Expire_Time", "Size_Bytes"}
+	showBackupSchedulesColumnNames = []string{"Backup_Schedule", "Cron", "Retention", "Backup_Type", "Update_Time"}
+	showInstancesColumnNames       = []string{"Instance", "Display_Name", "Config", "Nodes", "Processing_Units", "Autoscaling", "State"}
+	showConfigsColumnNames         = []string{"Instance_Config", "Display_Name", "Replicas"}
+	showOperationsColumnNames      = []string{"Operation_Id", "Statements", "Done", "Progress", "Error"}
+	showOperationColumnNames       = []string{"Statement", "Done", "Progress", "Commit_Timestamp", "Error"}
 )
 
 func BuildStatement(input string) (Statement, error) {
@@ -137,38 +244,115 @@ func BuildStatement(input string) (Statement, error) {
 }
 
 func BuildStatementWithComments(stripped, raw string) (Statement, error) {
+	stmt, err := buildStatementWithComments(stripped, raw)
+	if err != nil {
+		return nil, err
+	}
+	switch stmt.(type) {
+	case *DdlStatement, *RestoreDatabaseStatement, *CreateBackupStatement, *CopyBackupStatement:
+		if hasCommentedAsyncDelimiter(raw) {
+			return nil, errors.New(`the delimiter after "--async" is a part of the comment, put the delimiter before the comment`)
+		}
+	}
+	return stmt, nil
+}
+
+// hasCommentedAsyncDelimiter reports whether the statement has the `--async;` comment.
+// `--async;` in string literals is not a comment, so it is found by whether removing the delimiter
+// changes the statement without comments.
+func hasCommentedAsyncDelimiter(raw string) bool {
+	stripped := stripComments(raw)
+	for _, loc := range commentedAsyncDelimiterRe.FindAllStringSubmatchIndex(raw, -1) {
+		if stripComments(raw[:loc[2]]+raw[loc[3]:]) == stripped {
+			return true
+		}
+	}
+	return false
+}
+
+func stripComments(statement string) string {
+	return (&gsqlsep.InputStatement{Statement: statement}).StripComments().Statement
+}
+
+func buildStatementWithComments(stripped, raw string) (Statement, error) {
 	switch {
 	case exitRe.MatchString(stripped):
 		return &ExitStatement{}, nil
 	case useRe.MatchString(stripped):
 		matched := useRe.FindStringSubmatch(stripped)
-		return &UseStatement{Database: unquoteIdentifier(matched[1]), Role: unquoteIdentifier(matched[2])}, nil
+		// Database can be qualified by instance as `<instance>.<database>`.
+		instance, database, found := strings.Cut(matched[1], ".")
+		if !found {
+			instance, database = "", matched[1]
+		}
+		return &UseStatement{Instance: unquoteIdentifier(instance), Database: unquoteIdentifier(database), Role: unquoteIdentifier(matched[2])}, nil
 	case selectRe.MatchString(stripped):
 		return &SelectStatement{Query: raw}, nil
+	case createBackupScheduleRe.MatchString(stripped):
+		return newCreateBackupScheduleStatement(stripped)
+	case alterBackupScheduleRe.MatchString(stripped):
+		return newAlterBackupScheduleStatement(stripped)
+	case dropBackupScheduleRe.MatchString(stripped):
+		matched := dropBackupScheduleRe.FindStringSubmatch(stripped)
+		return &DropBackupScheduleStatement{Schedule: unquoteIdentifier(matched[1])}, nil
+	case showBackupSchedulesRe.MatchString(stripped):
+		return &ShowBackupSchedulesStatement{}, nil
+	case createBackupRe.MatchString(stripped):
+		return newCreateBackupStatement(stripped, raw)
+	case copyBackupRe.MatchString(stripped):
+		return newCopyBackupStatement(stripped, raw)
+	case alterBackupRe.MatchString(stripped):
+		matched := alterBackupRe.FindStringSubmatch(stripped)
+		expireIn, expireAt, err := parseBackupExpiration(matched[2], matched[3])
+		if err != nil {
+			return nil, err
+		}
+		return &AlterBackupStatement{Backup: unquoteIdentifier(matched[1]), ExpireIn: expireIn, ExpireAt: expireAt}, nil
+	case dropBackupRe.MatchString(stripped):
+		matched := dropBackupRe.FindStringSubmatch(stripped)
+		return &DropBackupStatement{Backup: unquoteIdentifier(matched[1])}, nil
+	case showBackupsRe.MatchString(stripped):
+		return &ShowBackupsStatement{}, nil
+	case showBackupOperationsRe.MatchString(stripped):
+		return &ShowBackupOperationsStatement{}, nil
+	case restoreDatabaseRe.MatchString(stripped):
+		matched := restoreDatabaseRe.FindStringSubmatch(stripped)
+		return &RestoreDatabaseStatement{
+			DatabaseId: unquoteIdentifier(matched[1]),
+			Backup:     unquoteIdentifier(matched[2]),
+			Async:      asyncDdlRe.MatchString(raw),
+		}, nil
 	case createDatabaseRe.MatchString(stripped):
 		return &CreateDatabaseStatement{CreateStatement: stripped}, nil
 	case createRe.MatchString(stripped):
-		return &DdlStatement{Ddl: stripped}, nil
+		return &DdlStatement{Ddl: stripped, Async: asyncDdlRe.MatchString(raw)}, nil
 	case dropDatabaseRe.MatchString(stripped):
 		matched := dropDatabaseRe.FindStringSubmatch(stripped)
 		return &DropDatabaseStatement{DatabaseId: unquoteIdentifier(matched[1])}, nil
 	case dropRe.MatchString(stripped):
-		return &DdlStatement{Ddl: stripped}, nil
+		return &DdlStatement{Ddl: stripped, Async: asyncDdlRe.MatchString(raw)}, nil
 	case alterRe.MatchString(stripped):
-		return &DdlStatement{Ddl: stripped}, nil
+		return &DdlStatement{Ddl: stripped, Async: asyncDdlRe.MatchString(raw)}, nil
 	case renameRe.MatchString(stripped):
-		return &DdlStatement{Ddl: stripped}, nil
+		return &DdlStatement{Ddl: stripped, Async: asyncDdlRe.MatchString(raw)}, nil
 	case grantRe.MatchString(stripped):
-		return &DdlStatement{Ddl: stripped}, nil
+		return &DdlStatement{Ddl: stripped, Async: asyncDdlRe.MatchString(raw)}, nil
 	case revokeRe.MatchString(stripped):
-		return &DdlStatement{Ddl: stripped}, nil
+		return &DdlStatement{Ddl: stripped, Async: asyncDdlRe.MatchString(raw)}, nil
 	case truncateTableRe.MatchString(stripped):
 		matched := truncateTableRe.FindStringSubmatch(stripped)
 		return &TruncateTableStatement{Table: unquoteIdentifier(matched[1])}, nil
 	case analyzeRe.MatchString(stripped):
-		return &DdlStatement{Ddl: stripped}, nil
+		return &DdlStatement{Ddl: stripped, Async: asyncDdlRe.MatchString(raw)}, nil
 	case showDatabasesRe.MatchString(stripped):
 		return &ShowDatabasesStatement{}, nil
+	case showInstancesRe.MatchString(stripped):
+		return &ShowInstancesStatement{}, nil
+	case showConfigsRe.MatchString(stripped):
+		return &ShowInstanceConfigsStatement{}, nil
+	case showInstanceRe.MatchString(stripped):
+		matched := showInstanceRe.FindStringSubmatch(stripped)
+		return &ShowInstanceStatement{Instance: unquoteIdentifier(matched[1])}, nil
 	case showCreateTableRe.MatchString(stripped):
 		matched := showCreateTableRe.FindStringSubmatch(stripped)
 		schema, table := extractSchemaAndTable(unquoteIdentifier(matched[1]))
@@ -207,6 +391,31 @@ func BuildStatementWithComments(stripped, raw string) (Statement, error) {
 		return &ShowIndexStatement{Schema: schema, Table: table}, nil
 	case dmlRe.MatchString(stripped):
 		return &DmlStatement{Dml: raw}, nil
+	case partitionedExportRe.MatchString(stripped):
+		matched := partitionedExportRe.FindStringSubmatch(stripped)
+		query, n, err := unquoteStringLiteral(matched[1], false)
+		if err != nil || n != len(matched[1]) {
+			return nil, fmt.Errorf("invalid query: %s", matched[1])
+		}
+		path, n, err := unquoteStringLiteral(matched[2], false)
+		if err != nil || n != len(matched[2]) {
+			return nil, fmt.Errorf("invalid file path: %s", matched[2])
+		}
+		var workers int
+		if matched[4] != "" {
+			workers, err = strconv.Atoi(matched[4])
+			if err != nil || workers == 0 {
+				return nil, fmt.Errorf("invalid number of workers: %s", matched[4])
+			}
+		}
+		return &PartitionedExportStatement{
+			Query:            query,
+			Path:             path,
+			Format:           strings.ToUpper(matched[3]),
+			Workers:          workers,
+			DataBoost:        matched[5] != "",
+			FilePerPartition: matched[6] != "",
+		}, nil
 	case pdmlRe.MatchString(stripped):
 		matched := pdmlRe.FindStringSubmatch(stripped)
 		return &PartitionedDmlStatement{Dml: matched[1]}, nil
@@ -220,6 +429,123 @@ func BuildStatementWithComments(stripped, raw string) (Statement, error) {
 		return &RollbackStatement{}, nil
 	case closeRe.MatchString(stripped):
 		return &CloseStatement{}, nil
+	case startBatchRe.MatchString(stripped):
+		matched := startBatchRe.FindStringSubmatch(stripped)
+		if strings.EqualFold(matched[1], "DDL") {
+			return &StartBatchStatement{Mode: batchModeDDL}, nil
+		}
+		return &StartBatchStatement{Mode: batchModeDML}, nil
+	case runBatchRe.MatchString(stripped):
+		return &RunBatchStatement{}, nil
+	case abortBatchRe.MatchString(stripped):
+		return &AbortBatchStatement{}, nil
+	case showBatchRe.MatchString(stripped):
+		return &ShowBatchStatement{}, nil
+	case showOperationsRe.MatchString(stripped):
+		return &ShowOperationsStatement{}, nil
+	case showOperationRe.MatchString(stripped):
+		matched := showOperationRe.FindStringSubmatch(stripped)
+		return &ShowOperationStatement{OperationId: matched[1]}, nil
+	case waitOperationRe.MatchString(stripped):
+		matched := waitOperationRe.FindStringSubmatch(stripped)
+		return &WaitOperationStatement{OperationId: matched[1]}, nil
+	case cancelOperationRe.MatchString(stripped):
+		matched := cancelOperationRe.FindStringSubmatch(stripped)
+		return &CancelOperationStatement{OperationId: matched[1]}, nil
+	case setParamRe.MatchString(stripped):
+		matched := setParamRe.FindStringSubmatch(stripped)
+		return &SetParamStatement{Name: matched[1], Value: matched[2]}, nil
+	case unsetParamRe.MatchString(stripped):
+		matched := unsetParamRe.FindStringSubmatch(stripped)
+		return &UnsetParamStatement{Name: matched[1]}, nil
+	case showParamsRe.MatchString(stripped):
+		return &ShowParamsStatement{}, nil
+	case setRe.MatchString(stripped):
+		matched := setRe.FindStringSubmatch(stripped)
+		value, err := unquoteVariableValue(matched[2])
+		if err != nil {
+			return nil, err
+		}
+		return &SetStatement{Name: strings.ToUpper(matched[1]), Value: value}, nil
+	case showVariableRe.MatchString(stripped):
+		matched := showVariableRe.FindStringSubmatch(stripped)
+		return &ShowVariableStatement{Name: strings.ToUpper(matched[1])}, nil
+	case showVariablesRe.MatchString(stripped):
+		return &ShowVariablesStatement{}, nil
+	case dumpDatabaseRe.MatchString(stripped):
+		return &DumpStatement{}, nil
+	case dumpTablesRe.MatchString(stripped):
+		matched := dumpTablesRe.FindStringSubmatch(stripped)
+		var tables []dumpTableName
+		for _, name := range strings.Split(matched[1], ",") {
+			schema, table := extractSchemaAndTable(unquoteIdentifier(strings.TrimSpace(name)))
+			tables = append(tables, dumpTableName{Schema: schema, Name: table})
+		}
+		return &DumpStatement{Tables: tables}, nil
+	case loadDataRe.MatchString(stripped):
+		matched := loadDataRe.FindStringSubmatch(stripped)
+		path, n, err := unquoteStringLiteral(matched[1], false)
+		if err != nil || n != len(matched[1]) {
+			return nil, fmt.Errorf("invalid file path: %s", matched[1])
+		}
+		schema, table := extractSchemaAndTable(unquoteIdentifier(matched[2]))
+		mode := "INSERT"
+		if matched[4] != "" {
+			mode = strings.ToUpper(matched[4])
+		}
+		return &LoadDataStatement{Path: path, Schema: schema, Table: table, Format: strings.ToUpper(matched[3]), Mode: mode}, nil
+	case diffTableRe.MatchString(stripped):
+		matched := diffTableRe.FindStringSubmatch(stripped)
+		schema, table := extractSchemaAndTable(unquoteIdentifier(matched[1]))
+		from, err := parseDiffTimestamp(matched[2])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseDiffTimestamp(matched[3])
+		if err != nil {
+			return nil, err
+		}
+		return &DiffTableStatement{Schema: schema, Table: table, From: from, To: to}, nil
+	case restoreRowsRe.MatchString(stripped):
+		matched := restoreRowsRe.FindStringSubmatch(stripped)
+		schema, table := extractSchemaAndTable(unquoteIdentifier(matched[1]))
+		timestamp, err := parseDiffTimestamp(matched[2])
+		if err != nil {
+			return nil, err
+		}
+		return &RestoreRowsStatement{Schema: schema, Table: table, Timestamp: timestamp, Where: matched[3]}, nil
+	case compareTableRe.MatchString(stripped):
+		matched := compareTableRe.FindStringSubmatch(stripped)
+		schema, table := extractSchemaAndTable(unquoteIdentifier(matched[1]))
+		// The other table can be qualified by database as `<database>.<table>`, and it is the same table by default.
+		database, target, found := strings.Cut(matched[2], ".")
+		targetSchema, targetTable := schema, table
+		if found {
+			targetSchema, targetTable = extractSchemaAndTable(unquoteIdentifier(target))
+		}
+		return &CompareTableStatement{Schema: schema, Table: table, Database: unquoteIdentifier(database), TargetSchema: targetSchema, TargetTable: targetTable}, nil
+	case compareQueryRe.MatchString(stripped):
+		matched := compareQueryRe.FindStringSubmatch(stripped)
+		query, n, err := unquoteStringLiteral(matched[1], false)
+		if err != nil || n != len(matched[1]) {
+			return nil, fmt.Errorf("invalid query: %s", matched[1])
+		}
+		var keys []string
+		for _, name := range strings.Split(matched[3], ",") {
+			keys = append(keys, unquoteIdentifier(name))
+		}
+		return &CompareQueryStatement{Query: query, Database: unquoteIdentifier(matched[2]), Keys: keys}, nil
+	case exportQueryRe.MatchString(stripped):
+		matched := exportQueryRe.FindStringSubmatch(stripped)
+		query, n, err := unquoteStringLiteral(matched[1], false)
+		if err != nil || n != len(matched[1]) {
+			return nil, fmt.Errorf("invalid query: %s", matched[1])
+		}
+		path, n, err := unquoteStringLiteral(matched[2], false)
+		if err != nil || n != len(matched[2]) {
+			return nil, fmt.Errorf("invalid file path: %s", matched[2])
+		}
+		return &ExportQueryStatement{Query: query, Path: path, Format: strings.ToUpper(matched[3])}, nil
 	}
 
 	return nil, errors.New("invalid statement")
@@ -229,40 +555,106 @@ func unquoteIdentifier(input string) string {
 	return strings.Trim(strings.TrimSpace(input), "`")
 }
 
+// unquoteVariableValue unquotes the value of `SET <name> = <value>` if it is a string literal.
+func unquoteVariableValue(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "'") && !strings.HasPrefix(input, `"`) {
+		return input, nil
+	}
+	value, n, err := unquoteStringLiteral(input, false)
+	if err != nil {
+		return "", err
+	}
+	if n != len(input) {
+		return "", fmt.Errorf("invalid value: %s", input)
+	}
+	return value, nil
+}
+
 type SelectStatement struct {
 	Query string
 }
 
 func (s *SelectStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	stmt := spanner.NewStatement(s.Query)
+	collector := &rowCollector{}
+	result, err := s.ExecuteStream(ctx, session, collector)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = collector.rows
+	return result, nil
+}
+
+// ExecuteStream writes rows to w as they arrive.
+// If ctx is canceled, e.g. by Ctrl-C, it stops reading rows and returns the result of the rows written so far.
+func (s *SelectStatement) ExecuteStream(ctx context.Context, session *Session, w resultWriter) (*Result, error) {
+	stmt := session.newStatement(s.Query)
 
 	iter, roTxn := session.RunQueryWithStats(ctx, stmt)
 	defer iter.Stop()
 
-	rows, columnNames, err := parseQueryResult(iter)
-	if err != nil {
-		if session.InReadWriteTransaction() && spanner.ErrCode(err) == codes.Aborted {
-			// Need to call rollback to free the acquired session in underlying google-cloud-go/spanner.
-			rollback := &RollbackStatement{}
-			rollback.Execute(ctx, session)
+	var headerWritten, interrupted bool
+	writeHeader := func() error {
+		headerWritten = true
+		fields := iter.Metadata.GetRowType().GetFields()
+		return w.WriteHeader(extractColumnNames(fields), fields)
+	}
+
+	var count int
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				interrupted = true
+				break
+			}
+			if session.InReadWriteTransaction() && spanner.ErrCode(err) == codes.Aborted {
+				// Need to call rollback to free the acquired session in underlying google-cloud-go/spanner.
+				rollback := &RollbackStatement{}
+				rollback.Execute(ctx, session)
+			}
+			return nil, err
+		}
+
+		if !headerWritten {
+			if err := writeHeader(); err != nil {
+				return nil, err
+			}
+		}
+		values, err := rowValues(row)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.WriteRow(Row{Values: values}); err != nil {
+			return nil, err
 		}
+		count++
+	}
+
+	if !headerWritten {
+		if err := writeHeader(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
 		return nil, err
 	}
+
+	fields := iter.Metadata.GetRowType().GetFields()
 	result := &Result{
-		ColumnNames: columnNames,
-		Rows:        rows,
+		ColumnNames:  extractColumnNames(fields),
+		ColumnTypes:  fields,
+		AffectedRows: count,
+		Stats:        parseQueryStats(iter.QueryStats),
 	}
-	result.ColumnTypes = iter.Metadata.GetRowType().GetFields()
 
-	queryStats := parseQueryStats(iter.QueryStats)
-	rowsReturned, err := strconv.Atoi(queryStats.RowsReturned)
-	if err != nil {
-		return nil, fmt.Errorf("rowsReturned is invalid: %v", err)
+	if interrupted {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("query was interrupted after %d rows", count))
 	}
 
-	result.AffectedRows = rowsReturned
-	result.Stats = queryStats
-
 	// ReadOnlyTransaction.Timestamp() is invalid until read.
 	if roTxn != nil {
 		result.Timestamp, _ = roTxn.Timestamp()
@@ -293,13 +685,11 @@ func parseQueryResult(iter *spanner.RowIterator) ([]Row, []string, error) {
 			return nil, nil, err
 		}
 
-		columns, err := DecodeRow(row)
+		values, err := rowValues(row)
 		if err != nil {
 			return nil, nil, err
 		}
-		rows = append(rows, Row{
-			Columns: columns,
-		})
+		rows = append(rows, Row{Values: values})
 	}
 	return rows, extractColumnNames(iter.Metadata.GetRowType().GetFields()), nil
 }
@@ -387,11 +777,17 @@ func (s *DropDatabaseStatement) Execute(ctx context.Context, session *Session) (
 }
 
 type DdlStatement struct {
-	Ddl string
+	Ddl   string
+	Async bool
 }
 
 func (s *DdlStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	return executeDdlStatements(ctx, session, []string{s.Ddl})
+	// DDL is only buffered in a batch, and sent by RUN BATCH.
+	if session.InBatchMode(batchModeDDL) {
+		session.batch.ddls = append(session.batch.ddls, s.Ddl)
+		return &Result{IsMutation: true}, nil
+	}
+	return executeDdlStatements(ctx, session, []string{s.Ddl}, s.Async)
 }
 
 type BulkDdlStatement struct {
@@ -399,10 +795,16 @@ type BulkDdlStatement struct {
 }
 
 func (s *BulkDdlStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	return executeDdlStatements(ctx, session, s.Ddls)
+	if session.InBatchMode(batchModeDDL) {
+		session.batch.ddls = append(session.batch.ddls, s.Ddls...)
+		return &Result{IsMutation: true}, nil
+	}
+	return executeDdlStatements(ctx, session, s.Ddls, false)
 }
 
-func executeDdlStatements(ctx context.Context, session *Session, ddls []string) (*Result, error) {
+// executeDdlStatements executes DDL statements in a single schema update operation.
+// If async is true or CLI_ASYNC_DDL is set, it returns the operation ID without waiting for the operation.
+func executeDdlStatements(ctx context.Context, session *Session, ddls []string, async bool) (*Result, error) {
 	op, err := session.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
 		Database:   session.DatabasePath(),
 		Statements: ddls,
@@ -410,13 +812,108 @@ func executeDdlStatements(ctx context.Context, session *Session, ddls []string)
 	if err != nil {
 		return nil, err
 	}
-	if err := op.Wait(ctx); err != nil {
-		return nil, err
+
+	if async || session.systemVariables.AsyncDDL {
+		return &Result{
+			IsMutation:  true,
+			ColumnNames: asyncDdlColumnNames,
+			Rows:        []Row{stringRow(lastPathSegment(op.Name()))},
+		}, nil
 	}
 
+	if err := waitDdlOperation(ctx, session, op); err != nil {
+		return nil, err
+	}
 	return &Result{IsMutation: true}, nil
 }
 
+// operationPollInterval is an interval to poll the long-running operation for showing its progress.
+const operationPollInterval = 2 * time.Second
+
+// waitDdlOperation waits for the schema update operation to be finished while reporting its progress to the session.
+func waitDdlOperation(ctx context.Context, session *Session, op *adminapi.UpdateDatabaseDdlOperation) error {
+	if op.Done() {
+		return op.Wait(ctx)
+	}
+	return waitOperation(ctx, session, op.Name())
+}
+
+// waitOperation waits for the long-running operation to be finished while reporting its progress to the session.
+func waitOperation(ctx context.Context, session *Session, name string) error {
+	for {
+		op, err := session.adminClient.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: name})
+		if err != nil {
+			return err
+		}
+		if op.GetDone() {
+			if opErr := op.GetError(); opErr != nil {
+				return status.ErrorProto(opErr)
+			}
+			return nil
+		}
+		session.SetProgress(formatOperationProgress(op))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(operationPollInterval):
+		}
+	}
+}
+
+// formatOperationProgress formats the progress of the long-running operation by its metadata.
+func formatOperationProgress(op *longrunningpb.Operation) string {
+	metadata, err := op.GetMetadata().UnmarshalNew()
+	if err != nil {
+		return ""
+	}
+	if ddlMetadata, ok := metadata.(*adminpb.UpdateDatabaseDdlMetadata); ok {
+		return formatDdlProgress(ddlMetadata)
+	}
+	if description, progress, ok := describeBackupOperation(metadata); ok {
+		return fmt.Sprintf("%3d%% %s", progress.GetProgressPercent(), description)
+	}
+	return ""
+}
+
+// formatDdlProgress formats the progress of the statement being executed by the schema update operation.
+func formatDdlProgress(metadata *adminpb.UpdateDatabaseDdlMetadata) string {
+	statements := metadata.GetStatements()
+	// Statements are executed in order, and a commit timestamp is added to metadata when each statement is committed.
+	i := len(metadata.GetCommitTimestamps())
+	if i >= len(statements) {
+		return ""
+	}
+
+	var percent int32
+	if progress := metadata.GetProgress(); i < len(progress) {
+		percent = progress[i].GetProgressPercent()
+	}
+	stmt := statements[i]
+	if len(stmt) > 50 {
+		stmt = stmt[:47] + "..."
+	}
+	return fmt.Sprintf("[%d/%d] %3d%% %s", i+1, len(statements), percent, stmt)
+}
+
+// lastPathSegment returns the last segment of the resource name such as an operation ID or a backup ID.
+func lastPathSegment(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// operationName returns the full operation name from either a full name, a name relative to the instance,
+// or an operation ID of the current database.
+func (s *Session) operationName(id string) string {
+	switch {
+	case strings.HasPrefix(id, "projects/"):
+		return id
+	case strings.Contains(id, "/"):
+		return fmt.Sprintf("%s/%s", s.InstancePath(), id)
+	default:
+		return fmt.Sprintf("%s/operations/%s", s.DatabasePath(), id)
+	}
+}
+
 type ShowDatabasesStatement struct {
 }
 
@@ -439,10 +936,7 @@ func (s *ShowDatabasesStatement) Execute(ctx context.Context, session *Session)
 		re := regexp.MustCompile(`projects/[^/]+/instances/[^/]+/databases/(.+)`)
 		matched := re.FindStringSubmatch(database.GetName())
 		dbname := matched[1]
-		resultRow := Row{
-			Columns: []string{dbname},
-		}
-		result.Rows = append(result.Rows, resultRow)
+		result.Rows = append(result.Rows, stringRow(dbname))
 	}
 
 	result.AffectedRows = len(result.Rows)
@@ -473,10 +967,7 @@ func (s *ShowCreateTableStatement) Execute(ctx context.Context, session *Session
 				fqn = fmt.Sprintf("%s.%s", s.Schema, s.Table)
 			}
 
-			resultRow := Row{
-				Columns: []string{fqn, stmt},
-			}
-			result.Rows = append(result.Rows, resultRow)
+			result.Rows = append(result.Rows, stringRow(fqn, stmt))
 			break
 		}
 	}
@@ -537,7 +1028,7 @@ type ExplainStatement struct {
 
 // Execute processes `EXPLAIN` statement for queries and DMLs.
 func (s *ExplainStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	queryPlan, timestamp, _, err := runAnalyzeQuery(ctx, session, spanner.NewStatement(s.Explain), s.IsDML)
+	queryPlan, timestamp, _, err := runAnalyzeQuery(ctx, session, session.newStatement(s.Explain), s.IsDML)
 	if err != nil {
 		return nil, err
 	}
@@ -569,14 +1060,14 @@ type DescribeStatement struct {
 
 // Execute processes `DESCRIBE` statement for queries and DMLs.
 func (s *DescribeStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	_, timestamp, metadata, err := runAnalyzeQuery(ctx, session, spanner.NewStatement(s.Statement), s.IsDML)
+	_, timestamp, metadata, err := runAnalyzeQuery(ctx, session, session.newStatement(s.Statement), s.IsDML)
 	if err != nil {
 		return nil, err
 	}
 
 	var rows []Row
 	for _, field := range metadata.GetRowType().GetFields() {
-		rows = append(rows, Row{Columns: []string{field.GetName(), formatTypeVerbose(field.GetType())}})
+		rows = append(rows, stringRow(field.GetName(), formatTypeVerbose(field.GetType())))
 	}
 
 	result := &Result{
@@ -607,7 +1098,7 @@ type ExplainAnalyzeStatement struct {
 }
 
 func (s *ExplainAnalyzeStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	stmt := spanner.NewStatement(s.Query)
+	stmt := session.newStatement(s.Query)
 
 	iter, roTxn := session.RunQueryWithStats(ctx, stmt)
 
@@ -681,9 +1172,9 @@ func processPlanImpl(plan *pb.QueryPlan, withStats bool) (rows []Row, predicates
 			formattedID = fmt.Sprintf("%*d", widthOfNodeIDWithIndicator, row.ID)
 		}
 		if withStats {
-			rows = append(rows, Row{[]string{formattedID, row.Text, row.RowsTotal, row.Execution, row.LatencyTotal}})
+			rows = append(rows, stringRow(formattedID, row.Text, row.RowsTotal, row.Execution, row.LatencyTotal))
 		} else {
-			rows = append(rows, Row{[]string{formattedID, row.Text}})
+			rows = append(rows, stringRow(formattedID, row.Text))
 		}
 		for i, predicate := range row.Predicates {
 			var prefix string
@@ -835,7 +1326,13 @@ type DmlStatement struct {
 }
 
 func (s *DmlStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	stmt := spanner.NewStatement(s.Dml)
+	stmt := session.newStatement(s.Dml)
+
+	// DML is only buffered in a batch, and sent by RUN BATCH.
+	if session.InBatchMode(batchModeDML) {
+		session.batch.dmls = append(session.batch.dmls, stmt)
+		return &Result{IsMutation: true}, nil
+	}
 
 	result := &Result{IsMutation: true}
 
@@ -898,7 +1395,7 @@ func (s *PartitionedDmlStatement) Execute(ctx context.Context, session *Session)
 		return nil, errors.New(`Partitioned DML statement can not be run in a read-only transaction`)
 	}
 
-	stmt := spanner.NewStatement(s.Dml)
+	stmt := session.newStatement(s.Dml)
 	ctx, cancel := context.WithTimeout(ctx, pdmlTimeout)
 	defer cancel()
 
@@ -918,7 +1415,7 @@ type ExplainAnalyzeDmlStatement struct {
 }
 
 func (s *ExplainAnalyzeDmlStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	stmt := spanner.NewStatement(s.Dml)
+	stmt := session.newStatement(s.Dml)
 
 	affectedRows, timestamp, queryPlan, _, err := runInNewOrExistRwTxForExplain(ctx, session, func() (int64, *pb.QueryPlan, *pb.ResultSetMetadata, error) {
 		iter, _ := session.RunQueryWithStats(ctx, stmt)
@@ -1029,6 +1526,9 @@ type CommitStatement struct{}
 
 func (s *CommitStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
 	result := &Result{IsMutation: true}
+	if session.InBatch() {
+		return nil, errors.New("you're in batch. Please finish the batch by 'RUN BATCH;' or 'ABORT BATCH;'")
+	}
 	if session.InReadOnlyTransaction() {
 		return nil, errors.New("you're in read-only transaction. Please finish the transaction by 'CLOSE;'")
 	}
@@ -1050,6 +1550,9 @@ type RollbackStatement struct{}
 
 func (s *RollbackStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
 	result := &Result{IsMutation: true}
+	if session.InBatch() {
+		return nil, errors.New("you're in batch. Please finish the batch by 'RUN BATCH;' or 'ABORT BATCH;'")
+	}
 	if session.InReadOnlyTransaction() {
 		return nil, errors.New("you're in read-only transaction. Please finish the transaction by 'CLOSE;'")
 	}
@@ -1064,99 +1567,1087 @@ func (s *RollbackStatement) Execute(ctx context.Context, session *Session) (*Res
 	return result, nil
 }
 
-type timestampBoundType int
+type StartBatchStatement struct {
+	Mode batchMode
+}
 
-const (
-	strong timestampBoundType = iota
-	exactStaleness
-	readTimestamp
-)
+func (s *StartBatchStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if session.InBatch() {
+		return nil, errors.New("you're already in batch. Please finish the batch by 'RUN BATCH;' or 'ABORT BATCH;'")
+	}
+	if s.Mode == batchModeDML && session.InReadOnlyTransaction() {
+		return nil, errors.New("you're in read-only transaction. Please finish the transaction by 'CLOSE;'")
+	}
 
-type BeginRoStatement struct {
-	TimestampBoundType timestampBoundType
-	Staleness          time.Duration
-	Timestamp          time.Time
-	Priority           pb.RequestOptions_Priority
-	Tag                string
+	session.batch = &batchContext{mode: s.Mode}
+	return &Result{IsMutation: true}, nil
 }
 
-func newBeginRoStatement(input string) (*BeginRoStatement, error) {
-	stmt := &BeginRoStatement{
-		TimestampBoundType: strong,
+type RunBatchStatement struct{}
+
+func (s *RunBatchStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if !session.InBatch() {
+		return nil, errNoBatch
 	}
 
-	matched := beginRoRe.FindStringSubmatch(input)
-	if matched[1] != "" {
-		if t, err := time.Parse(time.RFC3339Nano, matched[1]); err == nil {
-			stmt = &BeginRoStatement{
-				TimestampBoundType: readTimestamp,
-				Timestamp:          t,
-			}
-		}
-		if i, err := strconv.Atoi(matched[1]); err == nil {
-			stmt = &BeginRoStatement{
-				TimestampBoundType: exactStaleness,
-				Staleness:          time.Duration(i) * time.Second,
-			}
+	// The batch is finished regardless of the result.
+	batch := session.batch
+	session.batch = nil
+
+	switch batch.mode {
+	case batchModeDDL:
+		if len(batch.ddls) == 0 {
+			return &Result{IsMutation: true}, nil
 		}
+		return executeDdlStatements(ctx, session, batch.ddls, false)
+	default:
+		return runBatchDml(ctx, session, batch.dmls)
 	}
+}
 
-	if matched[2] != "" {
-		priority, err := parsePriority(matched[2])
+// runBatchDml executes DML statements in a single BatchUpdate call,
+// either on the running read-write transaction or on an implicit transaction.
+func runBatchDml(ctx context.Context, session *Session, stmts []spanner.Statement) (*Result, error) {
+	result := &Result{IsMutation: true}
+	if len(stmts) == 0 {
+		return result, nil
+	}
+
+	var rowCounts []int64
+	var err error
+	if session.InReadWriteTransaction() {
+		rowCounts, err = session.RunBatchUpdate(ctx, stmts)
 		if err != nil {
+			// Need to call rollback to free the acquired session in underlying google-cloud-go/spanner.
+			rollback := &RollbackStatement{}
+			rollback.Execute(ctx, session)
+			return nil, fmt.Errorf("transaction was aborted: %v", batchDmlError(stmts, rowCounts, err))
+		}
+	} else {
+		// Start implicit transaction.
+		begin := BeginRwStatement{}
+		if _, err = begin.Execute(ctx, session); err != nil {
 			return nil, err
 		}
-		stmt.Priority = priority
-	}
 
-	if matched[3] != "" {
-		stmt.Tag = matched[3]
+		rowCounts, err = session.RunBatchUpdate(ctx, stmts)
+		if err != nil {
+			// once error has happened, escape from implicit transaction
+			rollback := &RollbackStatement{}
+			rollback.Execute(ctx, session)
+			return nil, batchDmlError(stmts, rowCounts, err)
+		}
+
+		commit := CommitStatement{}
+		txnResult, err := commit.Execute(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+		result.Timestamp = txnResult.Timestamp
+		result.CommitStats = txnResult.CommitStats
 	}
 
-	return stmt, nil
+	for i, rowCount := range rowCounts {
+		result.Rows = append(result.Rows, stringRow(stmts[i].SQL, strconv.FormatInt(rowCount, 10)))
+		result.AffectedRows += int(rowCount)
+	}
+	result.ColumnNames = batchDmlColumnNames
+	return result, nil
 }
 
-func (s *BeginRoStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	if session.InReadWriteTransaction() {
-		return nil, errors.New("You're in read-write transaction. Please finish the transaction by 'COMMIT;' or 'ROLLBACK;'")
-	}
-	if session.InReadOnlyTransaction() {
-		// close current transaction implicitly
-		close := &CloseStatement{}
-		close.Execute(ctx, session)
+// batchDmlError tells which statement failed in the batch.
+// BatchUpdate stops at the first failed statement, so it is the one next to the succeeded statements.
+func batchDmlError(stmts []spanner.Statement, rowCounts []int64, err error) error {
+	if len(rowCounts) >= len(stmts) {
+		return err
 	}
+	return fmt.Errorf("statement %d of %d in the batch failed: %s: %v", len(rowCounts)+1, len(stmts), stmts[len(rowCounts)].SQL, err)
+}
 
-	ts, err := session.BeginReadOnlyTransaction(ctx, s.TimestampBoundType, s.Staleness, s.Timestamp, s.Priority, s.Tag)
-	if err != nil {
-		return nil, err
+type AbortBatchStatement struct{}
+
+func (s *AbortBatchStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if !session.InBatch() {
+		return nil, errNoBatch
 	}
 
-	return &Result{
-		IsMutation: true,
-		Timestamp:  ts,
-	}, nil
+	session.batch = nil
+	return &Result{IsMutation: true}, nil
 }
 
-type CloseStatement struct{}
+type ShowBatchStatement struct{}
 
-func (s *CloseStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
-	if session.InReadWriteTransaction() {
-		return nil, errors.New("You're in read-write transaction. Please finish the transaction by 'COMMIT;' or 'ROLLBACK;'")
+func (s *ShowBatchStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if !session.InBatch() {
+		return nil, errNoBatch
 	}
 
-	result := &Result{IsMutation: true}
-
-	if !session.InReadOnlyTransaction() {
-		return result, nil
+	statements := session.batch.ddls
+	if session.batch.mode == batchModeDML {
+		statements = nil
+		for _, stmt := range session.batch.dmls {
+			statements = append(statements, stmt.SQL)
+		}
 	}
 
-	if err := session.CloseReadOnlyTransaction(); err != nil {
-		return nil, err
+	result := &Result{ColumnNames: showBatchColumnNames}
+	for _, stmt := range statements {
+		result.Rows = append(result.Rows, stringRow(stmt))
 	}
-
+	result.AffectedRows = len(result.Rows)
 	return result, nil
 }
 
+var errNoBatch = errors.New("no batch is running. Please start a batch by 'START BATCH DDL;' or 'START BATCH DML;'")
+
+type ShowOperationsStatement struct{}
+
+func (s *ShowOperationsStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	iter := session.adminClient.ListDatabaseOperations(ctx, &adminpb.ListDatabaseOperationsRequest{
+		Parent: session.InstancePath(),
+		Filter: fmt.Sprintf(`(metadata.@type:type.googleapis.com/google.spanner.admin.database.v1.UpdateDatabaseDdlMetadata) AND (name:%s/operations/)`, session.DatabasePath()),
+	})
+
+	result := &Result{ColumnNames: showOperationsColumnNames}
+	for {
+		op, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var metadata adminpb.UpdateDatabaseDdlMetadata
+		if err := op.GetMetadata().UnmarshalTo(&metadata); err != nil {
+			return nil, err
+		}
+
+		// Progress of the operation is the average of all statements.
+		var total int32
+		for i := range metadata.GetStatements() {
+			total += ddlStatementProgress(&metadata, i)
+		}
+		var progress string
+		if n := len(metadata.GetStatements()); n > 0 {
+			progress = fmt.Sprintf("%d%%", total/int32(n))
+		}
+
+		result.Rows = append(result.Rows, stringRow(
+			lastPathSegment(op.GetName()),
+			strings.Join(metadata.GetStatements(), ";\n"),
+			strings.ToUpper(strconv.FormatBool(op.GetDone())),
+			progress,
+			op.GetError().GetMessage(),
+		))
+	}
+	result.AffectedRows = len(result.Rows)
+	return result, nil
+}
+
+type ShowOperationStatement struct {
+	OperationId string
+}
+
+func (s *ShowOperationStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	op, err := session.adminClient.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: session.operationName(s.OperationId)})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := op.GetMetadata().UnmarshalNew()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{ColumnNames: showOperationColumnNames}
+	if description, progress, ok := describeBackupOperation(metadata); ok {
+		result.Rows = []Row{stringRow(
+			description,
+			strings.ToUpper(strconv.FormatBool(op.GetDone())),
+			fmt.Sprintf("%d%%", progress.GetProgressPercent()),
+			formatTimestamp(progress.GetEndTime()),
+			op.GetError().GetMessage(),
+		)}
+		result.AffectedRows = 1
+		return result, nil
+	}
+
+	ddlMetadata, ok := metadata.(*adminpb.UpdateDatabaseDdlMetadata)
+	if !ok {
+		return nil, fmt.Errorf("operation %q is neither a schema update nor a backup operation", s.OperationId)
+	}
+	commitTimestamps := ddlMetadata.GetCommitTimestamps()
+	for i, stmt := range ddlMetadata.GetStatements() {
+		var done, commitTimestamp, errMessage string
+		switch {
+		case i < len(commitTimestamps):
+			done = "TRUE"
+			commitTimestamp = commitTimestamps[i].AsTime().Format(time.RFC3339Nano)
+		case i == len(commitTimestamps) && op.GetError() != nil:
+			// The operation stops at the first failed statement.
+			done = "TRUE"
+			errMessage = op.GetError().GetMessage()
+		default:
+			done = "FALSE"
+		}
+		result.Rows = append(result.Rows, stringRow(
+			stmt,
+			done,
+			fmt.Sprintf("%d%%", ddlStatementProgress(ddlMetadata, i)),
+			commitTimestamp,
+			errMessage,
+		))
+	}
+	result.AffectedRows = len(result.Rows)
+	return result, nil
+}
+
+// ddlStatementProgress returns the progress percentage of i-th statement of the schema update operation.
+func ddlStatementProgress(metadata *adminpb.UpdateDatabaseDdlMetadata, i int) int32 {
+	if i < len(metadata.GetCommitTimestamps()) {
+		return 100
+	}
+	if progress := metadata.GetProgress(); i < len(progress) {
+		return progress[i].GetProgressPercent()
+	}
+	return 0
+}
+
+type WaitOperationStatement struct {
+	OperationId string
+}
+
+func (s *WaitOperationStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if err := waitOperation(ctx, session, session.operationName(s.OperationId)); err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type CancelOperationStatement struct {
+	OperationId string
+}
+
+func (s *CancelOperationStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if err := session.adminClient.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: session.operationName(s.OperationId)}); err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type ShowInstancesStatement struct{}
+
+func (s *ShowInstancesStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	iter := session.instanceClient.ListInstances(ctx, &instancepb.ListInstancesRequest{Parent: session.ProjectPath()})
+
+	result := &Result{ColumnNames: showInstancesColumnNames}
+	for {
+		instance, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, instanceRow(instance))
+	}
+	result.AffectedRows = len(result.Rows)
+	return result, nil
+}
+
+type ShowInstanceStatement struct {
+	Instance string // empty for the current instance
+}
+
+func (s *ShowInstanceStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	name := session.InstancePath()
+	if s.Instance != "" {
+		name = fmt.Sprintf("%s/instances/%s", session.ProjectPath(), s.Instance)
+	}
+
+	instance, err := session.instanceClient.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		ColumnNames:  showInstancesColumnNames,
+		Rows:         []Row{instanceRow(instance)},
+		AffectedRows: 1,
+	}, nil
+}
+
+func instanceRow(instance *instancepb.Instance) Row {
+	return stringRow(
+		lastPathSegment(instance.GetName()),
+		instance.GetDisplayName(),
+		lastPathSegment(instance.GetConfig()),
+		strconv.Itoa(int(instance.GetNodeCount())),
+		strconv.Itoa(int(instance.GetProcessingUnits())),
+		formatAutoscalingConfig(instance.GetAutoscalingConfig()),
+		instance.GetState().String(),
+	)
+}
+
+// formatAutoscalingConfig formats the limits and targets of the autoscaling config, or empty string if autoscaling is disabled.
+func formatAutoscalingConfig(config *instancepb.AutoscalingConfig) string {
+	if config == nil {
+		return ""
+	}
+
+	limits := config.GetAutoscalingLimits()
+	var limitsStr string
+	if limits.GetMaxNodes() > 0 {
+		limitsStr = fmt.Sprintf("nodes: %d-%d", limits.GetMinNodes(), limits.GetMaxNodes())
+	} else {
+		limitsStr = fmt.Sprintf("processing units: %d-%d", limits.GetMinProcessingUnits(), limits.GetMaxProcessingUnits())
+	}
+
+	targets := config.GetAutoscalingTargets()
+	return fmt.Sprintf("%s, high priority cpu: %d%%, storage: %d%%",
+		limitsStr, targets.GetHighPriorityCpuUtilizationPercent(), targets.GetStorageUtilizationPercent())
+}
+
+type ShowInstanceConfigsStatement struct{}
+
+func (s *ShowInstanceConfigsStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	iter := session.instanceClient.ListInstanceConfigs(ctx, &instancepb.ListInstanceConfigsRequest{Parent: session.ProjectPath()})
+
+	result := &Result{ColumnNames: showConfigsColumnNames}
+	for {
+		config, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var replicas []string
+		for _, replica := range config.GetReplicas() {
+			replicaStr := fmt.Sprintf("%s:%s", replica.GetLocation(), replica.GetType())
+			if replica.GetDefaultLeaderLocation() {
+				replicaStr += " (default leader)"
+			}
+			replicas = append(replicas, replicaStr)
+		}
+		result.Rows = append(result.Rows, stringRow(
+			lastPathSegment(config.GetName()),
+			config.GetDisplayName(),
+			strings.Join(replicas, "\n"),
+		))
+	}
+	result.AffectedRows = len(result.Rows)
+	return result, nil
+}
+
+// Backups are kept for 7 days unless the expiration is specified.
+const defaultBackupRetention = 7 * 24 * time.Hour
+
+type CreateBackupStatement struct {
+	Backup      string
+	ExpireIn    time.Duration
+	ExpireAt    time.Time
+	VersionTime time.Time
+	Async       bool
+}
+
+func newCreateBackupStatement(stripped, raw string) (*CreateBackupStatement, error) {
+	matched := createBackupRe.FindStringSubmatch(stripped)
+	stmt := &CreateBackupStatement{
+		Backup: unquoteIdentifier(matched[1]),
+		Async:  asyncDdlRe.MatchString(raw),
+	}
+
+	if matched[2] != "" {
+		expireIn, expireAt, err := parseBackupExpiration(matched[2], matched[3])
+		if err != nil {
+			return nil, err
+		}
+		stmt.ExpireIn, stmt.ExpireAt = expireIn, expireAt
+	}
+
+	if matched[4] != "" {
+		versionTime, err := parseTimestamp(matched[4])
+		if err != nil {
+			return nil, err
+		}
+		stmt.VersionTime = versionTime
+	}
+
+	return stmt, nil
+}
+
+func (s *CreateBackupStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	backup := &adminpb.Backup{
+		Database:   session.DatabasePath(),
+		ExpireTime: timestamppb.New(backupExpireTime(s.ExpireIn, s.ExpireAt)),
+	}
+	if !s.VersionTime.IsZero() {
+		backup.VersionTime = timestamppb.New(s.VersionTime)
+	}
+
+	op, err := session.adminClient.CreateBackup(ctx, &adminpb.CreateBackupRequest{
+		Parent:   session.InstancePath(),
+		BackupId: s.Backup,
+		Backup:   backup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Async || session.systemVariables.AsyncDDL {
+		return asyncOperationResult(session, op.Name()), nil
+	}
+	if op.Done() {
+		_, err = op.Wait(ctx)
+	} else {
+		err = waitOperation(ctx, session, op.Name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type CopyBackupStatement struct {
+	Source   string
+	Backup   string
+	ExpireIn time.Duration
+	ExpireAt time.Time
+	Async    bool
+}
+
+func newCopyBackupStatement(stripped, raw string) (*CopyBackupStatement, error) {
+	matched := copyBackupRe.FindStringSubmatch(stripped)
+	stmt := &CopyBackupStatement{
+		Source: unquoteIdentifier(matched[1]),
+		Backup: unquoteIdentifier(matched[2]),
+		Async:  asyncDdlRe.MatchString(raw),
+	}
+
+	if matched[3] != "" {
+		expireIn, expireAt, err := parseBackupExpiration(matched[3], matched[4])
+		if err != nil {
+			return nil, err
+		}
+		stmt.ExpireIn, stmt.ExpireAt = expireIn, expireAt
+	}
+
+	return stmt, nil
+}
+
+func (s *CopyBackupStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	op, err := session.adminClient.CopyBackup(ctx, &adminpb.CopyBackupRequest{
+		Parent:       session.InstancePath(),
+		BackupId:     s.Backup,
+		SourceBackup: session.backupPath(s.Source),
+		ExpireTime:   timestamppb.New(backupExpireTime(s.ExpireIn, s.ExpireAt)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Async || session.systemVariables.AsyncDDL {
+		return asyncOperationResult(session, op.Name()), nil
+	}
+	if op.Done() {
+		_, err = op.Wait(ctx)
+	} else {
+		err = waitOperation(ctx, session, op.Name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type AlterBackupStatement struct {
+	Backup   string
+	ExpireIn time.Duration
+	ExpireAt time.Time
+}
+
+func (s *AlterBackupStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if _, err := session.adminClient.UpdateBackup(ctx, &adminpb.UpdateBackupRequest{
+		Backup: &adminpb.Backup{
+			Name:       session.backupPath(s.Backup),
+			ExpireTime: timestamppb.New(backupExpireTime(s.ExpireIn, s.ExpireAt)),
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"expire_time"}},
+	}); err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type DropBackupStatement struct {
+	Backup string
+}
+
+func (s *DropBackupStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if err := session.adminClient.DeleteBackup(ctx, &adminpb.DeleteBackupRequest{Name: session.backupPath(s.Backup)}); err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type ShowBackupsStatement struct{}
+
+func (s *ShowBackupsStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	iter := session.adminClient.ListBackups(ctx, &adminpb.ListBackupsRequest{Parent: session.InstancePath()})
+
+	result := &Result{ColumnNames: showBackupsColumnNames}
+	for {
+		backup, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result.Rows = append(result.Rows, stringRow(
+			lastPathSegment(backup.GetName()),
+			lastPathSegment(backup.GetDatabase()),
+			backup.GetState().String(),
+			formatTimestamp(backup.GetCreateTime()),
+			formatTimestamp(backup.GetVersionTime()),
+			formatTimestamp(backup.GetExpireTime()),
+			strconv.FormatInt(backup.GetSizeBytes(), 10),
+		))
+	}
+	result.AffectedRows = len(result.Rows)
+	return result, nil
+}
+
+type ShowBackupOperationsStatement struct{}
+
+func (s *ShowBackupOperationsStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	// Backup operations belong to backups, but restore operations belong to the restored databases.
+	iters := []*adminapi.OperationIterator{
+		session.adminClient.ListBackupOperations(ctx, &adminpb.ListBackupOperationsRequest{Parent: session.InstancePath()}),
+		session.adminClient.ListDatabaseOperations(ctx, &adminpb.ListDatabaseOperationsRequest{
+			Parent: session.InstancePath(),
+			Filter: `(metadata.@type:type.googleapis.com/google.spanner.admin.database.v1.RestoreDatabaseMetadata)`,
+		}),
+	}
+
+	result := &Result{ColumnNames: showOperationsColumnNames}
+	for _, iter := range iters {
+		for {
+			op, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			metadata, err := op.GetMetadata().UnmarshalNew()
+			if err != nil {
+				return nil, err
+			}
+			description, progress, ok := describeBackupOperation(metadata)
+			if !ok {
+				continue
+			}
+			result.Rows = append(result.Rows, stringRow(
+				strings.TrimPrefix(op.GetName(), session.InstancePath()+"/"),
+				description,
+				strings.ToUpper(strconv.FormatBool(op.GetDone())),
+				fmt.Sprintf("%d%%", progress.GetProgressPercent()),
+				op.GetError().GetMessage(),
+			))
+		}
+	}
+	result.AffectedRows = len(result.Rows)
+	return result, nil
+}
+
+type RestoreDatabaseStatement struct {
+	DatabaseId string
+	Backup     string
+	Async      bool
+}
+
+func (s *RestoreDatabaseStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	op, err := session.adminClient.RestoreDatabase(ctx, &adminpb.RestoreDatabaseRequest{
+		Parent:     session.InstancePath(),
+		DatabaseId: s.DatabaseId,
+		Source:     &adminpb.RestoreDatabaseRequest_Backup{Backup: session.backupPath(s.Backup)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Async || session.systemVariables.AsyncDDL {
+		return asyncOperationResult(session, op.Name()), nil
+	}
+	if op.Done() {
+		_, err = op.Wait(ctx)
+	} else {
+		err = waitOperation(ctx, session, op.Name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type CreateBackupScheduleStatement struct {
+	Schedule    string
+	Cron        string
+	Retention   time.Duration
+	Incremental bool
+}
+
+func newCreateBackupScheduleStatement(stripped string) (*CreateBackupScheduleStatement, error) {
+	matched := createBackupScheduleRe.FindStringSubmatch(stripped)
+	cron, err := unquoteVariableValue(matched[2])
+	if err != nil {
+		return nil, err
+	}
+	retention, _, err := parseBackupExpiration("IN", matched[3])
+	if err != nil {
+		return nil, err
+	}
+	return &CreateBackupScheduleStatement{
+		Schedule:    unquoteIdentifier(matched[1]),
+		Cron:        cron,
+		Retention:   retention,
+		Incremental: strings.EqualFold(matched[4], "INCREMENTAL"),
+	}, nil
+}
+
+func (s *CreateBackupScheduleStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	schedule := &adminpb.BackupSchedule{
+		Spec:              backupScheduleSpec(s.Cron),
+		RetentionDuration: durationpb.New(s.Retention),
+	}
+	if s.Incremental {
+		schedule.BackupTypeSpec = &adminpb.BackupSchedule_IncrementalBackupSpec{IncrementalBackupSpec: &adminpb.IncrementalBackupSpec{}}
+	} else {
+		schedule.BackupTypeSpec = &adminpb.BackupSchedule_FullBackupSpec{FullBackupSpec: &adminpb.FullBackupSpec{}}
+	}
+
+	if _, err := session.adminClient.CreateBackupSchedule(ctx, &adminpb.CreateBackupScheduleRequest{
+		Parent:           session.DatabasePath(),
+		BackupScheduleId: s.Schedule,
+		BackupSchedule:   schedule,
+	}); err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type AlterBackupScheduleStatement struct {
+	Schedule  string
+	Cron      string
+	Retention time.Duration
+}
+
+func newAlterBackupScheduleStatement(stripped string) (*AlterBackupScheduleStatement, error) {
+	matched := alterBackupScheduleRe.FindStringSubmatch(stripped)
+	if matched[2] == "" && matched[3] == "" {
+		return nil, errors.New("ALTER BACKUP SCHEDULE requires CRON or RETENTION")
+	}
+
+	stmt := &AlterBackupScheduleStatement{Schedule: unquoteIdentifier(matched[1])}
+	if matched[2] != "" {
+		cron, err := unquoteVariableValue(matched[2])
+		if err != nil {
+			return nil, err
+		}
+		stmt.Cron = cron
+	}
+	if matched[3] != "" {
+		retention, _, err := parseBackupExpiration("IN", matched[3])
+		if err != nil {
+			return nil, err
+		}
+		stmt.Retention = retention
+	}
+	return stmt, nil
+}
+
+func (s *AlterBackupScheduleStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	schedule := &adminpb.BackupSchedule{Name: session.backupSchedulePath(s.Schedule)}
+	var paths []string
+	if s.Cron != "" {
+		schedule.Spec = backupScheduleSpec(s.Cron)
+		paths = append(paths, "spec.cron_spec.text")
+	}
+	if s.Retention != 0 {
+		schedule.RetentionDuration = durationpb.New(s.Retention)
+		paths = append(paths, "retention_duration")
+	}
+
+	if _, err := session.adminClient.UpdateBackupSchedule(ctx, &adminpb.UpdateBackupScheduleRequest{
+		BackupSchedule: schedule,
+		UpdateMask:     &fieldmaskpb.FieldMask{Paths: paths},
+	}); err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type DropBackupScheduleStatement struct {
+	Schedule string
+}
+
+func (s *DropBackupScheduleStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if err := session.adminClient.DeleteBackupSchedule(ctx, &adminpb.DeleteBackupScheduleRequest{Name: session.backupSchedulePath(s.Schedule)}); err != nil {
+		return nil, err
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type ShowBackupSchedulesStatement struct{}
+
+func (s *ShowBackupSchedulesStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	iter := session.adminClient.ListBackupSchedules(ctx, &adminpb.ListBackupSchedulesRequest{Parent: session.DatabasePath()})
+
+	result := &Result{ColumnNames: showBackupSchedulesColumnNames}
+	for {
+		schedule, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result.Rows = append(result.Rows, stringRow(
+			lastPathSegment(schedule.GetName()),
+			schedule.GetSpec().GetCronSpec().GetText(),
+			formatBackupRetention(schedule.GetRetentionDuration().AsDuration()),
+			backupScheduleType(schedule),
+			formatTimestamp(schedule.GetUpdateTime()),
+		))
+	}
+	result.AffectedRows = len(result.Rows)
+	return result, nil
+}
+
+// describeBackupOperation returns a statement-like description and the progress of the operation
+// if the metadata is of a backup related operation.
+func describeBackupOperation(metadata proto.Message) (string, *adminpb.OperationProgress, bool) {
+	switch m := metadata.(type) {
+	case *adminpb.CreateBackupMetadata:
+		return fmt.Sprintf("CREATE BACKUP %s", lastPathSegment(m.GetName())), m.GetProgress(), true
+	case *adminpb.CopyBackupMetadata:
+		return fmt.Sprintf("COPY BACKUP %s TO %s", lastPathSegment(m.GetSourceBackup()), lastPathSegment(m.GetName())), m.GetProgress(), true
+	case *adminpb.RestoreDatabaseMetadata:
+		return fmt.Sprintf("RESTORE DATABASE %s FROM BACKUP %s", lastPathSegment(m.GetName()), lastPathSegment(m.GetBackupInfo().GetBackup())), m.GetProgress(), true
+	default:
+		return "", nil, false
+	}
+}
+
+// asyncOperationResult returns the name of the operation relative to the instance.
+func asyncOperationResult(session *Session, name string) *Result {
+	return &Result{
+		IsMutation:  true,
+		ColumnNames: asyncDdlColumnNames,
+		Rows:        []Row{stringRow(strings.TrimPrefix(name, session.InstancePath()+"/"))},
+	}
+}
+
+// backupPath returns the full backup name from either a backup ID of the current instance or a full name.
+func (s *Session) backupPath(backup string) string {
+	if strings.HasPrefix(backup, "projects/") {
+		return backup
+	}
+	return fmt.Sprintf("%s/backups/%s", s.InstancePath(), backup)
+}
+
+// backupSchedulePath returns the full backup schedule name from either a schedule ID of the current database or a full name.
+func (s *Session) backupSchedulePath(schedule string) string {
+	if strings.HasPrefix(schedule, "projects/") {
+		return schedule
+	}
+	return fmt.Sprintf("%s/backupSchedules/%s", s.DatabasePath(), schedule)
+}
+
+func backupScheduleSpec(cron string) *adminpb.BackupScheduleSpec {
+	return &adminpb.BackupScheduleSpec{ScheduleSpec: &adminpb.BackupScheduleSpec_CronSpec{CronSpec: &adminpb.CrontabSpec{Text: cron}}}
+}
+
+// backupScheduleType returns the type of backups created by the schedule.
+func backupScheduleType(schedule *adminpb.BackupSchedule) string {
+	switch schedule.GetBackupTypeSpec().(type) {
+	case *adminpb.BackupSchedule_FullBackupSpec:
+		return "FULL"
+	case *adminpb.BackupSchedule_IncrementalBackupSpec:
+		return "INCREMENTAL"
+	default:
+		return ""
+	}
+}
+
+// formatBackupRetention formats the retention duration in days if possible like `EXPIRE IN`.
+func formatBackupRetention(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// backupExpireTime returns the expire time from either a relative duration or an absolute timestamp.
+func backupExpireTime(expireIn time.Duration, expireAt time.Time) time.Time {
+	if !expireAt.IsZero() {
+		return expireAt
+	}
+	if expireIn == 0 {
+		expireIn = defaultBackupRetention
+	}
+	return time.Now().Add(expireIn)
+}
+
+// parseBackupExpiration parses `EXPIRE IN <duration>` or `EXPIRE AT <timestamp>`.
+func parseBackupExpiration(kind, value string) (time.Duration, time.Time, error) {
+	if strings.EqualFold(kind, "AT") {
+		t, err := parseTimestamp(value)
+		return 0, t, err
+	}
+
+	// Backups are usually kept for days, so days are also accepted in addition to Go's duration format.
+	if lower := strings.ToLower(value); strings.HasSuffix(lower, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(lower, "d")); err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, time.Time{}, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, time.Time{}, fmt.Errorf("invalid duration: %q, it must be a positive duration such as 7d or 12h", value)
+	}
+	return d, time.Time{}, nil
+}
+
+// parseTimestamp parses an optionally quoted RFC3339 timestamp.
+func parseTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, strings.Trim(value, `'"`))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %q, it must be in RFC3339 format", value)
+	}
+	return t, nil
+}
+
+func formatTimestamp(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().Format(time.RFC3339Nano)
+}
+
+type timestampBoundType int
+
+const (
+	strong timestampBoundType = iota
+	exactStaleness
+	readTimestamp
+	maxStaleness
+	minReadTimestamp
+)
+
+type BeginRoStatement struct {
+	TimestampBoundType timestampBoundType
+	Staleness          time.Duration
+	Timestamp          time.Time
+	Priority           pb.RequestOptions_Priority
+	Tag                string
+}
+
+func newBeginRoStatement(input string) (*BeginRoStatement, error) {
+	stmt := &BeginRoStatement{
+		TimestampBoundType: strong,
+	}
+
+	matched := beginRoRe.FindStringSubmatch(input)
+	if matched[1] != "" {
+		staleness, err := parseReadOnlyStaleness(matched[1] + " " + matched[2])
+		if err != nil {
+			return nil, err
+		}
+		stmt = &BeginRoStatement{
+			TimestampBoundType: staleness.typ,
+			Staleness:          staleness.staleness,
+			Timestamp:          staleness.timestamp,
+		}
+	} else if matched[2] != "" {
+		if t, err := time.Parse(time.RFC3339Nano, matched[2]); err == nil {
+			stmt = &BeginRoStatement{
+				TimestampBoundType: readTimestamp,
+				Timestamp:          t,
+			}
+		}
+		if i, err := strconv.Atoi(matched[2]); err == nil {
+			stmt = &BeginRoStatement{
+				TimestampBoundType: exactStaleness,
+				Staleness:          time.Duration(i) * time.Second,
+			}
+		}
+	}
+
+	if matched[3] != "" {
+		priority, err := parsePriority(matched[3])
+		if err != nil {
+			return nil, err
+		}
+		stmt.Priority = priority
+	}
+
+	if matched[4] != "" {
+		stmt.Tag = matched[4]
+	}
+
+	return stmt, nil
+}
+
+func (s *BeginRoStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if session.InReadWriteTransaction() {
+		return nil, errors.New("You're in read-write transaction. Please finish the transaction by 'COMMIT;' or 'ROLLBACK;'")
+	}
+	if session.InReadOnlyTransaction() {
+		// close current transaction implicitly
+		close := &CloseStatement{}
+		close.Execute(ctx, session)
+	}
+
+	ts, err := session.BeginReadOnlyTransaction(ctx, s.TimestampBoundType, s.Staleness, s.Timestamp, s.Priority, s.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		IsMutation: true,
+		Timestamp:  ts,
+	}, nil
+}
+
+type CloseStatement struct{}
+
+func (s *CloseStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if session.InReadWriteTransaction() {
+		return nil, errors.New("You're in read-write transaction. Please finish the transaction by 'COMMIT;' or 'ROLLBACK;'")
+	}
+
+	result := &Result{IsMutation: true}
+
+	if !session.InReadOnlyTransaction() {
+		return result, nil
+	}
+
+	if err := session.CloseReadOnlyTransaction(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+type SetParamStatement struct {
+	Name  string
+	Value string
+}
+
+func (s *SetParamStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	value, err := parseParamValue(s.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value of parameter %q: %v", s.Name, err)
+	}
+	session.params[s.Name] = value
+	return &Result{IsMutation: true}, nil
+}
+
+type UnsetParamStatement struct {
+	Name string
+}
+
+func (s *UnsetParamStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	if _, ok := session.params[s.Name]; !ok {
+		return nil, fmt.Errorf("parameter %q is not set", s.Name)
+	}
+	delete(session.params, s.Name)
+	return &Result{IsMutation: true}, nil
+}
+
+type ShowParamsStatement struct{}
+
+func (s *ShowParamsStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	names := make([]string, 0, len(session.params))
+	for name := range session.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rows []Row
+	for _, name := range names {
+		value := session.params[name]
+		rows = append(rows, Row{Values: []spanner.GenericColumnValue{stringValue(name), stringValue(formatTypeVerbose(value.Type)), value}})
+	}
+
+	return &Result{
+		ColumnNames:  showParamsColumnNames,
+		Rows:         rows,
+		AffectedRows: len(rows),
+	}, nil
+}
+
+type SetStatement struct {
+	Name  string
+	Value string
+}
+
+func (s *SetStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	// Keep the previous directed read option to restore it if the new one doesn't match any replica.
+	prevDirectedRead := session.systemVariables.DirectedRead
+	if err := session.systemVariables.Set(s.Name, s.Value); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(s.Name, "DIRECTED_READ") {
+		if err := session.ValidateDirectedRead(ctx); err != nil {
+			session.systemVariables.DirectedRead = prevDirectedRead
+			return nil, err
+		}
+	}
+	return &Result{IsMutation: true}, nil
+}
+
+type ShowVariableStatement struct {
+	Name string
+}
+
+func (s *ShowVariableStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	value, err := session.systemVariables.Get(s.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		ColumnNames:  []string{s.Name},
+		Rows:         []Row{stringRow(value)},
+		AffectedRows: 1,
+	}, nil
+}
+
+type ShowVariablesStatement struct{}
+
+func (s *ShowVariablesStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
+	var rows []Row
+	for _, name := range systemVariableNames() {
+		value, err := session.systemVariables.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, stringRow(name, value))
+	}
+	return &Result{
+		ColumnNames:  showVariablesColumnNames,
+		Rows:         rows,
+		AffectedRows: len(rows),
+	}, nil
+}
+
 type NopStatement struct{}
 
 func (s *NopStatement) Execute(ctx context.Context, session *Session) (*Result, error) {
@@ -1169,6 +2660,7 @@ type ExitStatement struct {
 }
 
 type UseStatement struct {
+	Instance string // empty if the instance is not changed
 	Database string
 	Role     string
 	NopStatement
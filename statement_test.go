@@ -23,7 +23,9 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
 	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
 )
 
@@ -337,6 +339,22 @@ func TestBuildStatement(t *testing.T) {
 			want:          &BeginRoStatement{Timestamp: timestamp, TimestampBoundType: readTimestamp},
 			skipLowerCase: true,
 		},
+		{
+			desc:  "BEGIN RO MAX_STALENESS statement",
+			input: "BEGIN RO MAX_STALENESS 15s",
+			want:  &BeginRoStatement{Staleness: time.Duration(15 * time.Second), TimestampBoundType: maxStaleness},
+		},
+		{
+			desc:          "BEGIN RO MIN_READ_TIMESTAMP statement with PRIORITY",
+			input:         "BEGIN RO MIN_READ_TIMESTAMP 2020-03-30T22:54:44.834017+09:00 PRIORITY LOW",
+			want:          &BeginRoStatement{Timestamp: timestamp, TimestampBoundType: minReadTimestamp, Priority: pb.RequestOptions_PRIORITY_LOW},
+			skipLowerCase: true,
+		},
+		{
+			desc:  "BEGIN RO EXACT_STALENESS statement",
+			input: "begin ro exact_staleness 1m",
+			want:  &BeginRoStatement{Staleness: time.Duration(time.Minute), TimestampBoundType: exactStaleness},
+		},
 		{
 			desc:  "BEGIN RO PRIORITY statement",
 			input: "BEGIN RO PRIORITY LOW",
@@ -437,11 +455,41 @@ func TestBuildStatement(t *testing.T) {
 			input: "USE `my-database` ROLE `my-role`",
 			want:  &UseStatement{Database: "my-database", Role: "my-role"},
 		},
+		{
+			desc:  "USE statement with instance",
+			input: "USE instance2.database2",
+			want:  &UseStatement{Instance: "instance2", Database: "database2"},
+		},
+		{
+			desc:  "USE statement with quoted instance and database",
+			input: "USE `my-instance`.`my-database` ROLE role2",
+			want:  &UseStatement{Instance: "my-instance", Database: "my-database", Role: "role2"},
+		},
 		{
 			desc:  "SHOW DATABASES statement",
 			input: "SHOW DATABASES",
 			want:  &ShowDatabasesStatement{},
 		},
+		{
+			desc:  "SHOW INSTANCES statement",
+			input: "SHOW INSTANCES",
+			want:  &ShowInstancesStatement{},
+		},
+		{
+			desc:  "SHOW INSTANCE statement",
+			input: "SHOW INSTANCE",
+			want:  &ShowInstanceStatement{},
+		},
+		{
+			desc:  "SHOW INSTANCE statement with instance",
+			input: "SHOW INSTANCE `my-instance`",
+			want:  &ShowInstanceStatement{Instance: "my-instance"},
+		},
+		{
+			desc:  "SHOW INSTANCE CONFIGS statement",
+			input: "SHOW INSTANCE CONFIGS",
+			want:  &ShowInstanceConfigsStatement{},
+		},
 		{
 			desc:  "SHOW CREATE TABLE statement",
 			input: "SHOW CREATE TABLE t1",
@@ -562,13 +610,299 @@ func TestBuildStatement(t *testing.T) {
 			input: `EXPLAIN ANALYZE CALL cancel_query("1234567890123456789")`,
 			want:  &ExplainAnalyzeStatement{Query: `CALL cancel_query("1234567890123456789")`},
 		},
+		{
+			desc:  "SET PARAM statement",
+			input: "SET PARAM id = 42",
+			want:  &SetParamStatement{Name: "id", Value: "42"},
+		},
+		{
+			desc:          "SET PARAM statement with typed literal",
+			input:         "SET PARAM ts TIMESTAMP '2024-01-01T00:00:00Z'",
+			want:          &SetParamStatement{Name: "ts", Value: "TIMESTAMP '2024-01-01T00:00:00Z'"},
+			skipLowerCase: true,
+		},
+		{
+			desc:  "SET PARAM statement with type",
+			input: "SET PARAM @arr ARRAY<STRING>",
+			want:  &SetParamStatement{Name: "arr", Value: "ARRAY<STRING>"},
+		},
+		{
+			desc:  "UNSET PARAM statement",
+			input: "UNSET PARAM id",
+			want:  &UnsetParamStatement{Name: "id"},
+		},
+		{
+			desc:  "SHOW PARAMS statement",
+			input: "SHOW PARAMS",
+			want:  &ShowParamsStatement{},
+		},
+		{
+			desc:  "START BATCH DDL statement",
+			input: "START BATCH DDL",
+			want:  &StartBatchStatement{Mode: batchModeDDL},
+		},
+		{
+			desc:  "START BATCH DML statement",
+			input: "START BATCH DML",
+			want:  &StartBatchStatement{Mode: batchModeDML},
+		},
+		{
+			desc:  "RUN BATCH statement",
+			input: "RUN BATCH",
+			want:  &RunBatchStatement{},
+		},
+		{
+			desc:  "ABORT BATCH statement",
+			input: "ABORT BATCH",
+			want:  &AbortBatchStatement{},
+		},
+		{
+			desc:  "SHOW BATCH statement",
+			input: "SHOW BATCH",
+			want:  &ShowBatchStatement{},
+		},
+		{
+			desc:  "SHOW OPERATIONS statement",
+			input: "SHOW OPERATIONS",
+			want:  &ShowOperationsStatement{},
+		},
+		{
+			desc:  "SHOW OPERATION statement",
+			input: "SHOW OPERATION _auto_op_123",
+			want:  &ShowOperationStatement{OperationId: "_auto_op_123"},
+		},
+		{
+			desc:  "WAIT OPERATION statement",
+			input: "WAIT OPERATION _auto_op_123",
+			want:  &WaitOperationStatement{OperationId: "_auto_op_123"},
+		},
+		{
+			desc:  "CANCEL OPERATION statement",
+			input: "CANCEL OPERATION _auto_op_123",
+			want:  &CancelOperationStatement{OperationId: "_auto_op_123"},
+		},
+		{
+			desc:  "CREATE BACKUP statement",
+			input: "CREATE BACKUP b1",
+			want:  &CreateBackupStatement{Backup: "b1"},
+		},
+		{
+			desc:          "CREATE BACKUP statement with options",
+			input:         "CREATE BACKUP b1 EXPIRE IN 14d VERSION_TIME 2020-03-30T22:54:44.834017+09:00",
+			want:          &CreateBackupStatement{Backup: "b1", ExpireIn: 14 * 24 * time.Hour, VersionTime: timestamp},
+			skipLowerCase: true,
+		},
+		{
+			desc:          "CREATE BACKUP statement with expire time",
+			input:         "CREATE BACKUP b1 EXPIRE AT '2020-03-30T22:54:44.834017+09:00'",
+			want:          &CreateBackupStatement{Backup: "b1", ExpireAt: timestamp},
+			skipLowerCase: true,
+		},
+		{
+			desc:  "COPY BACKUP statement",
+			input: "COPY BACKUP projects/p/instances/i/backups/b1 TO b2 EXPIRE IN 12h",
+			want:  &CopyBackupStatement{Source: "projects/p/instances/i/backups/b1", Backup: "b2", ExpireIn: 12 * time.Hour},
+		},
+		{
+			desc:  "ALTER BACKUP statement",
+			input: "ALTER BACKUP b1 EXPIRE IN 30d",
+			want:  &AlterBackupStatement{Backup: "b1", ExpireIn: 30 * 24 * time.Hour},
+		},
+		{
+			desc:  "DROP BACKUP statement",
+			input: "DROP BACKUP b1",
+			want:  &DropBackupStatement{Backup: "b1"},
+		},
+		{
+			desc:  "SHOW BACKUPS statement",
+			input: "SHOW BACKUPS",
+			want:  &ShowBackupsStatement{},
+		},
+		{
+			desc:  "SHOW BACKUP OPERATIONS statement",
+			input: "SHOW BACKUP OPERATIONS",
+			want:  &ShowBackupOperationsStatement{},
+		},
+		{
+			desc:  "RESTORE DATABASE statement",
+			input: "RESTORE DATABASE db2 FROM BACKUP b1",
+			want:  &RestoreDatabaseStatement{DatabaseId: "db2", Backup: "b1"},
+		},
+		{
+			desc:  "CREATE BACKUP SCHEDULE statement",
+			input: "CREATE BACKUP SCHEDULE s1 CRON '0 2 * * *' RETENTION 7d",
+			want:  &CreateBackupScheduleStatement{Schedule: "s1", Cron: "0 2 * * *", Retention: 7 * 24 * time.Hour},
+		},
+		{
+			desc:  "CREATE BACKUP SCHEDULE statement with backup type",
+			input: "create backup schedule `s1` cron \"0 */4 * * *\" retention 36h incremental",
+			want:  &CreateBackupScheduleStatement{Schedule: "s1", Cron: "0 */4 * * *", Retention: 36 * time.Hour, Incremental: true},
+		},
+		{
+			desc:  "ALTER BACKUP SCHEDULE statement",
+			input: "ALTER BACKUP SCHEDULE s1 CRON '30 1 * * *' RETENTION 14d",
+			want:  &AlterBackupScheduleStatement{Schedule: "s1", Cron: "30 1 * * *", Retention: 14 * 24 * time.Hour},
+		},
+		{
+			desc:  "ALTER BACKUP SCHEDULE statement with retention only",
+			input: "ALTER BACKUP SCHEDULE s1 RETENTION 3d",
+			want:  &AlterBackupScheduleStatement{Schedule: "s1", Retention: 3 * 24 * time.Hour},
+		},
+		{
+			desc:  "DROP BACKUP SCHEDULE statement",
+			input: "DROP BACKUP SCHEDULE s1",
+			want:  &DropBackupScheduleStatement{Schedule: "s1"},
+		},
+		{
+			desc:  "SHOW BACKUP SCHEDULES statement",
+			input: "SHOW BACKUP SCHEDULES",
+			want:  &ShowBackupSchedulesStatement{},
+		},
+		{
+			desc:  "SET statement",
+			input: "SET RPC_PRIORITY = LOW",
+			want:  &SetStatement{Name: "RPC_PRIORITY", Value: "LOW"},
+		},
+		{
+			desc:          "SET statement with quoted value",
+			input:         "SET STATEMENT_TAG = 'app=foo'",
+			want:          &SetStatement{Name: "STATEMENT_TAG", Value: "app=foo"},
+			skipLowerCase: true,
+		},
+		{
+			desc:  "SHOW VARIABLE statement",
+			input: "SHOW VARIABLE RPC_PRIORITY",
+			want:  &ShowVariableStatement{Name: "RPC_PRIORITY"},
+		},
+		{
+			desc:  "SHOW VARIABLES statement",
+			input: "SHOW VARIABLES",
+			want:  &ShowVariablesStatement{},
+		},
+		{
+			desc:  "DUMP DATABASE statement",
+			input: "DUMP DATABASE",
+			want:  &DumpStatement{},
+		},
+		{
+			desc:  "DUMP TABLE statement",
+			input: "DUMP TABLE t1, `t2`, sch1.t3",
+			want:  &DumpStatement{Tables: []dumpTableName{{Name: "t1"}, {Name: "t2"}, {Schema: "sch1", Name: "t3"}}},
+		},
+		{
+			desc:  "DUMP TABLES statement",
+			input: "DUMP TABLES t1",
+			want:  &DumpStatement{Tables: []dumpTableName{{Name: "t1"}}},
+		},
+		{
+			desc:  "LOAD DATA statement",
+			input: "LOAD DATA FROM '/tmp/singers.csv' INTO TABLE Singers",
+			want:  &LoadDataStatement{Path: "/tmp/singers.csv", Table: "Singers", Mode: "INSERT"},
+		},
+		{
+			desc:  "LOAD DATA statement with format and mode",
+			input: `LOAD DATA FROM "data/it's.txt" INTO TABLE sch1.Singers FORMAT JSONL MODE INSERT_OR_UPDATE`,
+			want:  &LoadDataStatement{Path: "data/it's.txt", Schema: "sch1", Table: "Singers", Format: "JSONL", Mode: "INSERT_OR_UPDATE"},
+		},
+		{
+			desc:  "LOAD DATA statement with PARQUET format",
+			input: "LOAD DATA FROM '/tmp/singers' INTO TABLE Singers FORMAT PARQUET",
+			want:  &LoadDataStatement{Path: "/tmp/singers", Table: "Singers", Format: "PARQUET", Mode: "INSERT"},
+		},
+		{
+			desc:  "EXPORT QUERY statement",
+			input: "EXPORT QUERY 'SELECT * FROM Singers' TO '/tmp/singers.parquet'",
+			want:  &ExportQueryStatement{Query: "SELECT * FROM Singers", Path: "/tmp/singers.parquet"},
+		},
+		{
+			desc:  "EXPORT QUERY statement with format",
+			input: `EXPORT QUERY """SELECT * FROM Singers WHERE FirstName = 'Marc'""" TO "/tmp/marc" FORMAT AVRO`,
+			want:  &ExportQueryStatement{Query: "SELECT * FROM Singers WHERE FirstName = 'Marc'", Path: "/tmp/marc", Format: "AVRO"},
+		},
+		{
+			desc:  "DIFF TABLE statement",
+			input: "DIFF TABLE Singers BETWEEN -60 sec AND NOW",
+			want: &DiffTableStatement{
+				Table: "Singers",
+				From:  readOnlyStaleness{typ: exactStaleness, staleness: 60 * time.Second},
+				To:    readOnlyStaleness{typ: strong},
+			},
+		},
+		{
+			desc:  "DIFF TABLE statement with timestamps",
+			input: "DIFF TABLE sch1.Singers BETWEEN '2024-01-02T03:04:05Z' AND -5m",
+			want: &DiffTableStatement{
+				Schema: "sch1",
+				Table:  "Singers",
+				From:   readOnlyStaleness{typ: readTimestamp, timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+				To:     readOnlyStaleness{typ: exactStaleness, staleness: 5 * time.Minute},
+			},
+			skipLowerCase: true,
+		},
+		{
+			desc:  "RESTORE ROWS statement",
+			input: "RESTORE ROWS FROM Singers AS OF -10m",
+			want: &RestoreRowsStatement{
+				Table:     "Singers",
+				Timestamp: readOnlyStaleness{typ: exactStaleness, staleness: 10 * time.Minute},
+			},
+		},
+		{
+			desc:  "RESTORE ROWS statement with WHERE clause",
+			input: "RESTORE ROWS FROM sch1.Singers AS OF '2024-01-02T03:04:05Z'\nWHERE SingerId IN (1, 2) AND LastName = 'Where'",
+			want: &RestoreRowsStatement{
+				Schema:    "sch1",
+				Table:     "Singers",
+				Timestamp: readOnlyStaleness{typ: readTimestamp, timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+				Where:     "SingerId IN (1, 2) AND LastName = 'Where'",
+			},
+			skipLowerCase: true,
+		},
+		{
+			desc:  "COMPARE TABLE statement",
+			input: "COMPARE TABLE Singers WITH staging",
+			want:  &CompareTableStatement{Table: "Singers", Database: "staging", TargetTable: "Singers"},
+		},
+		{
+			desc:  "COMPARE TABLE statement with schema",
+			input: "COMPARE TABLE sch1.Singers WITH staging",
+			want:  &CompareTableStatement{Schema: "sch1", Table: "Singers", Database: "staging", TargetSchema: "sch1", TargetTable: "Singers"},
+		},
+		{
+			desc:          "COMPARE TABLE statement with other table",
+			input:         "COMPARE TABLE Singers WITH `staging-db`.sch2.`SingersCopy`",
+			want:          &CompareTableStatement{Table: "Singers", Database: "staging-db", TargetSchema: "sch2", TargetTable: "SingersCopy"},
+			skipLowerCase: true,
+		},
+		{
+			desc:          "COMPARE QUERY statement",
+			input:         "COMPARE QUERY 'SELECT * FROM Singers WHERE SingerId > 10 ORDER BY SingerId' WITH staging KEY (SingerId)",
+			want:          &CompareQueryStatement{Query: "SELECT * FROM Singers WHERE SingerId > 10 ORDER BY SingerId", Database: "staging", Keys: []string{"SingerId"}},
+			skipLowerCase: true,
+		},
+		{
+			desc:          "COMPARE QUERY statement with multiple keys",
+			input:         "COMPARE QUERY \"WITH A AS (SELECT * FROM Albums) SELECT * FROM A ORDER BY 1, 2\" WITH `staging-db` KEY (SingerId, `AlbumId`)",
+			want:          &CompareQueryStatement{Query: "WITH A AS (SELECT * FROM Albums) SELECT * FROM A ORDER BY 1, 2", Database: "staging-db", Keys: []string{"SingerId", "AlbumId"}},
+			skipLowerCase: true,
+		},
+		{
+			desc:  "PARTITIONED EXPORT QUERY statement",
+			input: "PARTITIONED EXPORT QUERY 'SELECT * FROM Singers' TO '/tmp/singers.avro'",
+			want:  &PartitionedExportStatement{Query: "SELECT * FROM Singers", Path: "/tmp/singers.avro"},
+		},
+		{
+			desc:  "PARTITIONED EXPORT QUERY statement with options",
+			input: "PARTITIONED EXPORT QUERY 'SELECT * FROM Singers' TO '/tmp/singers' FORMAT PARQUET WORKERS 16 WITH DATA BOOST FILE PER PARTITION",
+			want:  &PartitionedExportStatement{Query: "SELECT * FROM Singers", Path: "/tmp/singers", Format: "PARQUET", Workers: 16, DataBoost: true, FilePerPartition: true},
+		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
 			got, err := BuildStatement(test.input)
 			if err != nil {
 				t.Fatalf("BuildStatement(%q) got error: %v", test.input, err)
 			}
-			if !cmp.Equal(got, test.want) {
+			if !cmp.Equal(got, test.want, cmp.AllowUnexported(readOnlyStaleness{})) {
 				t.Errorf("BuildStatement(%q) = %v, but want = %v", test.input, got, test.want)
 			}
 		})
@@ -597,7 +931,24 @@ func TestBuildStatement(t *testing.T) {
 		{"FOO BAR"},
 		{"SELEC T FROM t1"},
 		{"SET @a = 1"},
+		{"SET PARAM id"},
+		{"SET RPC_PRIORITY"},
+		{"START BATCH"},
+		{"CREATE BACKUP b1 EXPIRE IN 0d"},
+		{"ALTER BACKUP b1 EXPIRE AT tomorrow"},
+		{"CREATE BACKUP SCHEDULE s1 CRON '0 2 * * *' RETENTION 0d"},
+		{"ALTER BACKUP SCHEDULE s1"},
+		{"SET STATEMENT_TAG = 'unclosed"},
 		{"BEGIN PRIORITY CRITICAL"},
+		{"EXPORT QUERY SELECT 1 TO '/tmp/one.avro'"},
+		{"PARTITIONED EXPORT QUERY 'SELECT 1' TO '/tmp/one.avro' WORKERS 0"},
+		{"DIFF TABLE Singers BETWEEN yesterday AND NOW"},
+		{"DIFF TABLE Singers BETWEEN -0s AND NOW"},
+		{"RESTORE ROWS FROM Singers AS OF yesterday"},
+		{"RESTORE ROWS FROM Singers WHERE SingerId = 1"},
+		{"COMPARE TABLE Singers WITH"},
+		{"COMPARE QUERY 'SELECT 1' WITH staging"},
+		{"COMPARE QUERY SELECT 1 WITH staging KEY (Id)"},
 	} {
 		got, err := BuildStatement(test.input)
 		if err == nil {
@@ -606,6 +957,70 @@ func TestBuildStatement(t *testing.T) {
 	}
 }
 
+func TestFormatDdlProgress(t *testing.T) {
+	statements := []string{"CREATE TABLE t1 (Id INT64) PRIMARY KEY (Id)", "CREATE INDEX idx ON t1 (Id)"}
+	for _, tt := range []struct {
+		desc     string
+		metadata *adminpb.UpdateDatabaseDdlMetadata
+		want     string
+	}{
+		{
+			desc:     "first statement is running",
+			metadata: &adminpb.UpdateDatabaseDdlMetadata{Statements: statements},
+			want:     "[1/2]   0% CREATE TABLE t1 (Id INT64) PRIMARY KEY (Id)",
+		},
+		{
+			desc: "second statement is running",
+			metadata: &adminpb.UpdateDatabaseDdlMetadata{
+				Statements:       statements,
+				CommitTimestamps: []*timestamppb.Timestamp{timestamppb.Now()},
+				Progress: []*adminpb.OperationProgress{
+					{ProgressPercent: 100},
+					{ProgressPercent: 42},
+				},
+			},
+			want: "[2/2]  42% CREATE INDEX idx ON t1 (Id)",
+		},
+		{
+			desc: "all statements are committed",
+			metadata: &adminpb.UpdateDatabaseDdlMetadata{
+				Statements:       statements,
+				CommitTimestamps: []*timestamppb.Timestamp{timestamppb.Now(), timestamppb.Now()},
+			},
+			want: "",
+		},
+		{
+			desc: "long statement is truncated",
+			metadata: &adminpb.UpdateDatabaseDdlMetadata{
+				Statements: []string{"CREATE TABLE t1 (Id INT64, Name STRING(MAX)) PRIMARY KEY (Id)"},
+			},
+			want: "[1/1]   0% CREATE TABLE t1 (Id INT64, Name STRING(MAX)) PR...",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := formatDdlProgress(tt.metadata); got != tt.want {
+				t.Errorf("formatDdlProgress() = %q, but want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	session := &Session{projectId: "p", instanceId: "i", databaseId: "d"}
+	for _, tt := range []struct {
+		id   string
+		want string
+	}{
+		{id: "_auto_op_1", want: "projects/p/instances/i/databases/d/operations/_auto_op_1"},
+		{id: "backups/b1/operations/op1", want: "projects/p/instances/i/backups/b1/operations/op1"},
+		{id: "projects/p2/instances/i2/databases/d2/operations/op2", want: "projects/p2/instances/i2/databases/d2/operations/op2"},
+	} {
+		if got := session.operationName(tt.id); got != tt.want {
+			t.Errorf("operationName(%q) = %q, but want = %q", tt.id, got, tt.want)
+		}
+	}
+}
+
 func TestIsCreateTableDDL(t *testing.T) {
 	for _, tt := range []struct {
 		desc   string
@@ -0,0 +1,436 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// systemVariables holds session-level settings.
+// They are seeded by command line options and can be changed by `SET <name> = <value>` at runtime.
+type systemVariables struct {
+	RPCPriority                pb.RequestOptions_Priority
+	StatementTag               string
+	ReadOnlyStaleness          readOnlyStaleness
+	DirectedRead               *pb.DirectedReadOptions
+	OptimizerVersion           string
+	OptimizerStatisticsPackage string
+	StatementTimeout           time.Duration
+	CLIFormat                  DisplayMode
+	CLIVerbose                 bool
+	TablePreviewRows           int
+	CSVHeader                  bool
+	CSVDelimiter               rune
+	CSVQuote                   rune
+	CSVNull                    string
+	JSONInt64AsString          bool
+	SQLLiteral                 bool
+	Template                   string
+	TemplateHeader             string
+	TemplateFooter             string
+	LoadResume                 bool
+	AsyncDDL                   bool
+}
+
+type systemVariable struct {
+	get func(v *systemVariables) string
+	set func(v *systemVariables, value string) error
+}
+
+var systemVariableDefs = map[string]systemVariable{
+	"RPC_PRIORITY": {
+		get: func(v *systemVariables) string {
+			return strings.TrimPrefix(v.RPCPriority.String(), "PRIORITY_")
+		},
+		set: func(v *systemVariables, value string) error {
+			priority, err := parsePriority(value)
+			if err != nil {
+				return err
+			}
+			v.RPCPriority = priority
+			return nil
+		},
+	},
+	"STATEMENT_TAG": {
+		get: func(v *systemVariables) string {
+			return v.StatementTag
+		},
+		set: func(v *systemVariables, value string) error {
+			v.StatementTag = value
+			return nil
+		},
+	},
+	"READ_ONLY_STALENESS": {
+		get: func(v *systemVariables) string {
+			return v.ReadOnlyStaleness.String()
+		},
+		set: func(v *systemVariables, value string) error {
+			staleness, err := parseReadOnlyStaleness(value)
+			if err != nil {
+				return err
+			}
+			v.ReadOnlyStaleness = staleness
+			return nil
+		},
+	},
+	"DIRECTED_READ": {
+		get: func(v *systemVariables) string {
+			return formatDirectedReadOption(v.DirectedRead)
+		},
+		set: func(v *systemVariables, value string) error {
+			opts, err := parseDirectedReadOption(value)
+			if err != nil {
+				return err
+			}
+			v.DirectedRead = opts
+			return nil
+		},
+	},
+	"OPTIMIZER_VERSION": {
+		get: func(v *systemVariables) string {
+			return v.OptimizerVersion
+		},
+		set: func(v *systemVariables, value string) error {
+			// https://cloud.google.com/spanner/docs/query-optimizer/manage-query-optimizer
+			if _, err := strconv.ParseUint(value, 10, 32); err != nil && value != "" && !strings.EqualFold(value, "latest") {
+				return fmt.Errorf("optimizer version must be either a version number or LATEST, but got %q", value)
+			}
+			v.OptimizerVersion = value
+			return nil
+		},
+	},
+	"OPTIMIZER_STATISTICS_PACKAGE": {
+		get: func(v *systemVariables) string {
+			return v.OptimizerStatisticsPackage
+		},
+		set: func(v *systemVariables, value string) error {
+			v.OptimizerStatisticsPackage = value
+			return nil
+		},
+	},
+	"STATEMENT_TIMEOUT": {
+		get: func(v *systemVariables) string {
+			return v.StatementTimeout.String()
+		},
+		set: func(v *systemVariables, value string) error {
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout < 0 {
+				return fmt.Errorf("statement timeout must be a non-negative duration such as 10s, but got %q", value)
+			}
+			v.StatementTimeout = timeout
+			return nil
+		},
+	},
+	"CLI_FORMAT": {
+		get: func(v *systemVariables) string {
+			return v.CLIFormat.String()
+		},
+		set: func(v *systemVariables, value string) error {
+			mode, err := parseDisplayMode(value)
+			if err != nil {
+				return err
+			}
+			v.CLIFormat = mode
+			return nil
+		},
+	},
+	"CLI_VERBOSE": {
+		get: func(v *systemVariables) string {
+			return strings.ToUpper(strconv.FormatBool(v.CLIVerbose))
+		},
+		set: func(v *systemVariables, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("CLI_VERBOSE must be either TRUE or FALSE, but got %q", value)
+			}
+			v.CLIVerbose = b
+			return nil
+		},
+	},
+	"CLI_TABLE_PREVIEW_ROWS": {
+		get: func(v *systemVariables) string {
+			return strconv.Itoa(v.TablePreviewRows)
+		},
+		set: func(v *systemVariables, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("CLI_TABLE_PREVIEW_ROWS must be a non-negative integer, but got %q", value)
+			}
+			v.TablePreviewRows = n
+			return nil
+		},
+	},
+	"CLI_CSV_HEADER": {
+		get: func(v *systemVariables) string {
+			return strings.ToUpper(strconv.FormatBool(v.CSVHeader))
+		},
+		set: func(v *systemVariables, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("CLI_CSV_HEADER must be either TRUE or FALSE, but got %q", value)
+			}
+			v.CSVHeader = b
+			return nil
+		},
+	},
+	"CLI_CSV_DELIMITER": {
+		get: func(v *systemVariables) string {
+			if v.CSVDelimiter == 0 {
+				return string(defaultCSVDelimiter)
+			}
+			return string(v.CSVDelimiter)
+		},
+		set: func(v *systemVariables, value string) error {
+			r, err := parseCSVCharacter("CLI_CSV_DELIMITER", value)
+			if err != nil {
+				return err
+			}
+			v.CSVDelimiter = r
+			return nil
+		},
+	},
+	"CLI_CSV_QUOTE": {
+		get: func(v *systemVariables) string {
+			if v.CSVQuote == 0 {
+				return string(defaultCSVQuote)
+			}
+			return string(v.CSVQuote)
+		},
+		set: func(v *systemVariables, value string) error {
+			r, err := parseCSVCharacter("CLI_CSV_QUOTE", value)
+			if err != nil {
+				return err
+			}
+			v.CSVQuote = r
+			return nil
+		},
+	},
+	"CLI_CSV_NULL": {
+		get: func(v *systemVariables) string {
+			return v.CSVNull
+		},
+		set: func(v *systemVariables, value string) error {
+			v.CSVNull = value
+			return nil
+		},
+	},
+	"CLI_JSON_INT64_AS_STRING": {
+		get: func(v *systemVariables) string {
+			return strings.ToUpper(strconv.FormatBool(v.JSONInt64AsString))
+		},
+		set: func(v *systemVariables, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("CLI_JSON_INT64_AS_STRING must be either TRUE or FALSE, but got %q", value)
+			}
+			v.JSONInt64AsString = b
+			return nil
+		},
+	},
+	"CLI_SQL_LITERAL": {
+		get: func(v *systemVariables) string {
+			return strings.ToUpper(strconv.FormatBool(v.SQLLiteral))
+		},
+		set: func(v *systemVariables, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("CLI_SQL_LITERAL must be either TRUE or FALSE, but got %q", value)
+			}
+			v.SQLLiteral = b
+			return nil
+		},
+	},
+	"CLI_TEMPLATE":        templateVariable("CLI_TEMPLATE", func(v *systemVariables) *string { return &v.Template }),
+	"CLI_TEMPLATE_HEADER": templateVariable("CLI_TEMPLATE_HEADER", func(v *systemVariables) *string { return &v.TemplateHeader }),
+	"CLI_TEMPLATE_FOOTER": templateVariable("CLI_TEMPLATE_FOOTER", func(v *systemVariables) *string { return &v.TemplateFooter }),
+	"CLI_LOAD_RESUME": {
+		get: func(v *systemVariables) string {
+			return strings.ToUpper(strconv.FormatBool(v.LoadResume))
+		},
+		set: func(v *systemVariables, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("CLI_LOAD_RESUME must be either TRUE or FALSE, but got %q", value)
+			}
+			v.LoadResume = b
+			return nil
+		},
+	},
+	"CLI_ASYNC_DDL": {
+		get: func(v *systemVariables) string {
+			return strings.ToUpper(strconv.FormatBool(v.AsyncDDL))
+		},
+		set: func(v *systemVariables, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("CLI_ASYNC_DDL must be either TRUE or FALSE, but got %q", value)
+			}
+			v.AsyncDDL = b
+			return nil
+		},
+	},
+}
+
+const (
+	defaultCSVDelimiter = ','
+	defaultCSVQuote     = '"'
+)
+
+// parseCSVCharacter parses a delimiter or a quote character of CSV, which must be a single character other than line breaks.
+func parseCSVCharacter(name, value string) (rune, error) {
+	r := []rune(value)
+	if len(r) != 1 || r[0] == '\r' || r[0] == '\n' {
+		return 0, fmt.Errorf("%s must be a single character, but got %q", name, value)
+	}
+	return r[0], nil
+}
+
+// Set validates the value and sets it to the variable.
+func (v *systemVariables) Set(name, value string) error {
+	def, ok := systemVariableDefs[strings.ToUpper(name)]
+	if !ok {
+		return fmt.Errorf("unknown variable name: %q", name)
+	}
+	return def.set(v, value)
+}
+
+// Get returns the current value of the variable in the form accepted by Set.
+func (v *systemVariables) Get(name string) (string, error) {
+	def, ok := systemVariableDefs[strings.ToUpper(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown variable name: %q", name)
+	}
+	return def.get(v), nil
+}
+
+// systemVariableNames returns all variable names in alphabetical order.
+func systemVariableNames() []string {
+	var names []string
+	for name := range systemVariableDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// queryOptions returns query options for ExecuteSql requests.
+func (v *systemVariables) queryOptions() *pb.ExecuteSqlRequest_QueryOptions {
+	return &pb.ExecuteSqlRequest_QueryOptions{
+		OptimizerVersion:           v.OptimizerVersion,
+		OptimizerStatisticsPackage: v.OptimizerStatisticsPackage,
+	}
+}
+
+// readOnlyStaleness is a timestamp bound for single-use read-only transactions.
+type readOnlyStaleness struct {
+	typ       timestampBoundType
+	staleness time.Duration
+	timestamp time.Time
+}
+
+// parseReadOnlyStaleness parses READ_ONLY_STALENESS in the same format as the other Cloud Spanner clients.
+// https://cloud.google.com/spanner/docs/jdbc-session-mgmt-commands#read_only_staleness
+func parseReadOnlyStaleness(value string) (readOnlyStaleness, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return readOnlyStaleness{}, fmt.Errorf("invalid read-only staleness: %q", value)
+	}
+
+	switch typ := strings.ToUpper(fields[0]); {
+	case typ == "STRONG" && len(fields) == 1:
+		return readOnlyStaleness{typ: strong}, nil
+	case typ == "EXACT_STALENESS" && len(fields) == 2:
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d < 0 {
+			return readOnlyStaleness{}, fmt.Errorf("invalid staleness: %q", fields[1])
+		}
+		return readOnlyStaleness{typ: exactStaleness, staleness: d}, nil
+	case typ == "MAX_STALENESS" && len(fields) == 2:
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d < 0 {
+			return readOnlyStaleness{}, fmt.Errorf("invalid staleness: %q", fields[1])
+		}
+		return readOnlyStaleness{typ: maxStaleness, staleness: d}, nil
+	case typ == "READ_TIMESTAMP" && len(fields) == 2:
+		t, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return readOnlyStaleness{}, fmt.Errorf("invalid timestamp: %q", fields[1])
+		}
+		return readOnlyStaleness{typ: readTimestamp, timestamp: t}, nil
+	case typ == "MIN_READ_TIMESTAMP" && len(fields) == 2:
+		t, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return readOnlyStaleness{}, fmt.Errorf("invalid timestamp: %q", fields[1])
+		}
+		return readOnlyStaleness{typ: minReadTimestamp, timestamp: t}, nil
+	default:
+		return readOnlyStaleness{}, fmt.Errorf("read-only staleness must be one of STRONG, EXACT_STALENESS <duration>, MAX_STALENESS <duration>, READ_TIMESTAMP <timestamp> or MIN_READ_TIMESTAMP <timestamp>, but got %q", value)
+	}
+}
+
+func (s readOnlyStaleness) String() string {
+	switch s.typ {
+	case exactStaleness:
+		return fmt.Sprintf("EXACT_STALENESS %s", s.staleness)
+	case maxStaleness:
+		return fmt.Sprintf("MAX_STALENESS %s", s.staleness)
+	case readTimestamp:
+		return fmt.Sprintf("READ_TIMESTAMP %s", s.timestamp.Format(time.RFC3339Nano))
+	case minReadTimestamp:
+		return fmt.Sprintf("MIN_READ_TIMESTAMP %s", s.timestamp.Format(time.RFC3339Nano))
+	default:
+		return "STRONG"
+	}
+}
+
+func (s readOnlyStaleness) timestampBound() spanner.TimestampBound {
+	switch s.typ {
+	case exactStaleness:
+		return spanner.ExactStaleness(s.staleness)
+	case maxStaleness:
+		return spanner.MaxStaleness(s.staleness)
+	case readTimestamp:
+		return spanner.ReadTimestamp(s.timestamp)
+	case minReadTimestamp:
+		return spanner.MinReadTimestamp(s.timestamp)
+	default:
+		return spanner.StrongRead()
+	}
+}
+
+// templateVariable defines a system variable holding a text/template, which is validated on set.
+func templateVariable(name string, field func(v *systemVariables) *string) systemVariable {
+	return systemVariable{
+		get: func(v *systemVariables) string {
+			return *field(v)
+		},
+		set: func(v *systemVariables, value string) error {
+			if _, err := parseOutputTemplate(name, value); err != nil {
+				return err
+			}
+			*field(v) = value
+			return nil
+		},
+	}
+}
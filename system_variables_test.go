@@ -0,0 +1,97 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSystemVariables(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "RPC_PRIORITY", value: "low", want: "LOW"},
+		{name: "rpc_priority", value: "HIGH", want: "HIGH"},
+		{name: "STATEMENT_TAG", value: "app=foo", want: "app=foo"},
+		{name: "READ_ONLY_STALENESS", value: "strong", want: "STRONG"},
+		{name: "READ_ONLY_STALENESS", value: "EXACT_STALENESS 10s", want: "EXACT_STALENESS 10s"},
+		{name: "READ_ONLY_STALENESS", value: "READ_TIMESTAMP 2024-01-01T00:00:00Z", want: "READ_TIMESTAMP 2024-01-01T00:00:00Z"},
+		{name: "READ_ONLY_STALENESS", value: "max_staleness 15s", want: "MAX_STALENESS 15s"},
+		{name: "READ_ONLY_STALENESS", value: "MIN_READ_TIMESTAMP 2024-01-01T00:00:00Z", want: "MIN_READ_TIMESTAMP 2024-01-01T00:00:00Z"},
+		{name: "DIRECTED_READ", value: "us-central1:read_only,READ_WRITE", want: "us-central1:READ_ONLY,READ_WRITE"},
+		{name: "DIRECTED_READ", value: "include=us-central1; auto_failover=true", want: "us-central1;auto_failover=true"},
+		{name: "DIRECTED_READ", value: "exclude=us-east1", want: "exclude=us-east1"},
+		{name: "DIRECTED_READ", value: "", want: ""},
+		{name: "OPTIMIZER_VERSION", value: "6", want: "6"},
+		{name: "OPTIMIZER_VERSION", value: "LATEST", want: "LATEST"},
+		{name: "OPTIMIZER_STATISTICS_PACKAGE", value: "auto_20240101", want: "auto_20240101"},
+		{name: "STATEMENT_TIMEOUT", value: "1m30s", want: "1m30s"},
+		{name: "CLI_FORMAT", value: "vertical", want: "VERTICAL"},
+		{name: "CLI_VERBOSE", value: "true", want: "TRUE"},
+		{name: "CLI_FORMAT", value: "csv", want: "CSV"},
+		{name: "CLI_CSV_HEADER", value: "false", want: "FALSE"},
+		{name: "CLI_CSV_DELIMITER", value: "\t", want: "\t"},
+		{name: "CLI_CSV_QUOTE", value: "'", want: "'"},
+		{name: "CLI_CSV_NULL", value: "\\N", want: "\\N"},
+		{name: "CLI_SQL_LITERAL", value: "true", want: "TRUE"},
+		{name: "CLI_LOAD_RESUME", value: "true", want: "TRUE"},
+		{name: "CLI_TEMPLATE", value: "{{.Columns.id}}", want: "{{.Columns.id}}"},
+	} {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			var v systemVariables
+			if err := v.Set(tt.name, tt.value); err != nil {
+				t.Fatalf("Set(%q, %q) got error: %v", tt.name, tt.value, err)
+			}
+			got, err := v.Get(tt.name)
+			if err != nil {
+				t.Fatalf("Get(%q) got error: %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("Get(%q) = %q, but want = %q", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	// invalid tests
+	for _, tt := range []struct {
+		name  string
+		value string
+	}{
+		{name: "UNKNOWN", value: "1"},
+		{name: "RPC_PRIORITY", value: "CRITICAL"},
+		{name: "READ_ONLY_STALENESS", value: "EXACT_STALENESS"},
+		{name: "READ_ONLY_STALENESS", value: "EXACT_STALENESS -1s"},
+		{name: "READ_ONLY_STALENESS", value: "READ_TIMESTAMP yesterday"},
+		{name: "READ_ONLY_STALENESS", value: "MAX_STALENESS"},
+		{name: "READ_ONLY_STALENESS", value: "MIN_READ_TIMESTAMP 10s"},
+		{name: "DIRECTED_READ", value: "us-central1:READONLY"},
+		{name: "OPTIMIZER_VERSION", value: "v1"},
+		{name: "STATEMENT_TIMEOUT", value: "10"},
+		{name: "CLI_FORMAT", value: "XML"},
+		{name: "CLI_VERBOSE", value: "yes"},
+		{name: "CLI_CSV_DELIMITER", value: ";;"},
+		{name: "CLI_CSV_QUOTE", value: ""},
+		{name: "CLI_TEMPLATE", value: "{{.id"},
+	} {
+		var v systemVariables
+		if err := v.Set(tt.name, tt.value); err == nil {
+			t.Errorf("Set(%q, %q) should fail", tt.name, tt.value)
+		}
+	}
+}
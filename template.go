@@ -0,0 +1,226 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// templateWriter renders each row through the row template of CLI_TEMPLATE,
+// preceded by CLI_TEMPLATE_HEADER. CLI_TEMPLATE_FOOTER is rendered by printResult
+// because the stats and the timestamp of a streamed result are known only after all rows.
+type templateWriter struct {
+	out     io.Writer
+	sysVars *systemVariables
+
+	columnNames []string
+	columnTypes []string
+	row         *template.Template
+	index       int
+}
+
+// templateRow is the data of the row template.
+type templateRow struct {
+	// Index is the 0-based position of the row in the result.
+	Index       int
+	ColumnNames []string
+	ColumnTypes []string
+	Values      []templateValue
+	// Columns are the values keyed by column names, or by 1-based positions for unnamed columns.
+	Columns map[string]templateValue
+}
+
+// templateResult is the data of the header and the footer templates.
+type templateResult struct {
+	ColumnNames  []string
+	ColumnTypes  []string
+	AffectedRows int
+	Stats        QueryStats
+	Timestamp    time.Time
+}
+
+func newTemplateResult(result *Result) templateResult {
+	return templateResult{
+		ColumnNames:  result.ColumnNames,
+		ColumnTypes:  templateColumnTypes(result.ColumnTypes),
+		AffectedRows: result.AffectedRows,
+		Stats:        result.Stats,
+		Timestamp:    result.Timestamp,
+	}
+}
+
+func templateColumnTypes(fields []*pb.StructType_Field) []string {
+	var types []string
+	for _, field := range fields {
+		types = append(types, formatTypeSimple(field.GetType()))
+	}
+	return types
+}
+
+func (w *templateWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	if len(columnNames) == 0 {
+		return nil
+	}
+	if w.sysVars.Template == "" {
+		return fmt.Errorf("CLI_TEMPLATE must be set to use the TEMPLATE format")
+	}
+	row, err := parseOutputTemplate("CLI_TEMPLATE", w.sysVars.Template)
+	if err != nil {
+		return err
+	}
+	w.columnNames = columnNames
+	w.columnTypes = templateColumnTypes(columnTypes)
+	w.row = row
+	return renderOutputTemplate(w.out, "CLI_TEMPLATE_HEADER", w.sysVars.TemplateHeader, templateResult{
+		ColumnNames: w.columnNames,
+		ColumnTypes: w.columnTypes,
+	})
+}
+
+func (w *templateWriter) WriteRow(row Row) error {
+	if w.row == nil {
+		return nil
+	}
+	data := templateRow{
+		Index:       w.index,
+		ColumnNames: w.columnNames,
+		ColumnTypes: w.columnTypes,
+		Values:      make([]templateValue, len(row.Values)),
+		Columns:     make(map[string]templateValue, len(row.Values)),
+	}
+	for i, value := range row.Values {
+		name := w.columnNames[i]
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+		data.Values[i] = templateValue{value}
+		data.Columns[name] = templateValue{value}
+	}
+	if err := w.row.Execute(w.out, data); err != nil {
+		return err
+	}
+	fmt.Fprintln(w.out)
+	w.index++
+	return nil
+}
+
+func (w *templateWriter) Flush() error {
+	return nil
+}
+
+// templateFuncs are functions available in templates in addition to the predefined ones.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// parseOutputTemplate parses the template held by the system variable.
+func parseOutputTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// renderOutputTemplate renders the template followed by a newline. An empty template prints nothing.
+func renderOutputTemplate(out io.Writer, name, text string, data interface{}) error {
+	if text == "" {
+		return nil
+	}
+	tmpl, err := parseOutputTemplate(name, text)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(out, data); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+// templateValue is a column value in row templates.
+// It is printed in the same format as the other display modes, and its methods give the typed value.
+type templateValue struct {
+	value spanner.GenericColumnValue
+}
+
+func (v templateValue) String() string {
+	s, err := DecodeColumn(v.value)
+	if err != nil {
+		return fmt.Sprintf("%%!(%v)", err)
+	}
+	return s
+}
+
+// Type returns the type name like INT64 or ARRAY<STRING>.
+func (v templateValue) Type() string {
+	return formatTypeSimple(v.value.Type)
+}
+
+func (v templateValue) IsNull() bool {
+	return isNullValue(v.value)
+}
+
+// Value returns the value as bool, int64, float64, []templateValue for ARRAY, nil for NULL, or string for other types.
+func (v templateValue) Value() (interface{}, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+	switch v.value.Type.GetCode() {
+	case pb.TypeCode_BOOL:
+		return v.value.Value.GetBoolValue(), nil
+	case pb.TypeCode_INT64, pb.TypeCode_ENUM:
+		return strconv.ParseInt(v.value.Value.GetStringValue(), 10, 64)
+	case pb.TypeCode_FLOAT32, pb.TypeCode_FLOAT64:
+		// NaN and infinities are encoded as strings.
+		if s, ok := v.value.Value.GetKind().(*structpb.Value_StringValue); ok {
+			return strconv.ParseFloat(s.StringValue, 64)
+		}
+		return v.value.Value.GetNumberValue(), nil
+	case pb.TypeCode_ARRAY:
+		var values []templateValue
+		for _, elem := range v.value.Value.GetListValue().GetValues() {
+			values = append(values, templateValue{spanner.GenericColumnValue{Type: v.value.Type.GetArrayElementType(), Value: elem}})
+		}
+		return values, nil
+	default:
+		return v.String(), nil
+	}
+}
+
+// SQL returns the value as a GoogleSQL literal, in the same way as CLI_SQL_LITERAL.
+func (v templateValue) SQL() (string, error) {
+	return formatSQLLiteral(v.value)
+}
+
+// JSON returns the value encoded as JSON, in the same way as the JSON format.
+func (v templateValue) JSON() (string, error) {
+	b, err := encodeJSONValue(v.value.Type, v.value.Value, false)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,99 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+func TestTemplateWriter(t *testing.T) {
+	result := &Result{
+		ColumnNames: []string{"id", "name", "tags", ""},
+		ColumnTypes: []*pb.StructType_Field{
+			{Name: "id", Type: &pb.Type{Code: pb.TypeCode_INT64}},
+			{Name: "name", Type: &pb.Type{Code: pb.TypeCode_STRING}},
+			{Name: "tags", Type: &pb.Type{Code: pb.TypeCode_ARRAY, ArrayElementType: &pb.Type{Code: pb.TypeCode_STRING}}},
+			{Name: "", Type: &pb.Type{Code: pb.TypeCode_BOOL}},
+		},
+		Rows: []Row{
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(1)), createColumnValue(t, "foo"), createColumnValue(t, []string{"a", "b"}), createColumnValue(t, true)}},
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(12)), createColumnValue(t, spanner.NullString{}), createColumnValue(t, []string{}), createColumnValue(t, false)}},
+		},
+		AffectedRows: 2,
+		Stats:        QueryStats{ElapsedTime: "1 msecs"},
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		sysVars *systemVariables
+		want    string
+	}{
+		{
+			desc:    "row template",
+			sysVars: &systemVariables{Template: `UPDATE users SET name = {{.Columns.name.JSON}} WHERE id = {{.Columns.id}};`},
+			want:    "UPDATE users SET name = \"foo\" WHERE id = 1;\nUPDATE users SET name = null WHERE id = 12;\n",
+		},
+		{
+			desc:    "typed values",
+			sysVars: &systemVariables{Template: `{{if gt .Columns.id.Value 10}}large{{else}}small{{end}} {{.Columns.name.IsNull}} {{.Columns.tags.Type}}{{range .Columns.tags.Value}} {{.}}{{end}} {{index .Columns "4"}}`},
+			want:    "small false ARRAY<STRING> a b true\nlarge true ARRAY<STRING> false\n",
+		},
+		{
+			desc:    "positions",
+			sysVars: &systemVariables{Template: `{{.Index}}:{{range $i, $v := .Values}} {{index $.ColumnNames $i}}({{index $.ColumnTypes $i}})={{$v}}{{end}}`},
+			want:    "0: id(INT64)=1 name(STRING)=foo tags(ARRAY<STRING>)=[a, b] (BOOL)=true\n1: id(INT64)=12 name(STRING)=NULL tags(ARRAY<STRING>)=[] (BOOL)=false\n",
+		},
+		{
+			desc: "header and footer",
+			sysVars: &systemVariables{
+				Template:       `{{.Columns.id}}`,
+				TemplateHeader: `-- {{join .ColumnNames ","}}`,
+				TemplateFooter: `-- {{.AffectedRows}} rows ({{.Stats.ElapsedTime}})`,
+			},
+			want: "-- id,name,tags,\n1\n12\n-- 2 rows (1 msecs)\n",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := printResult(out, result, DisplayModeTemplate, false, tt.sysVars); err != nil {
+				t.Fatalf("printResult() got error: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("invalid print: expected = %q, but got = %q", tt.want, got)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		sysVars *systemVariables
+	}{
+		{desc: "no template", sysVars: &systemVariables{}},
+		{desc: "unknown column", sysVars: &systemVariables{Template: `{{.Columns.unknown}}`}},
+		{desc: "unknown field", sysVars: &systemVariables{Template: `{{.unknown}}`}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := printResult(&bytes.Buffer{}, result, DisplayModeTemplate, false, tt.sysVars); err == nil {
+				t.Errorf("printResult() should fail")
+			}
+		})
+	}
+}
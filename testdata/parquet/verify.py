@@ -0,0 +1,86 @@
+#!/usr/bin/env python3
+#
+# Copyright 2020 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+
+"""Reads the golden Parquet files with pyarrow, and checks them against the expected JSON files.
+
+The golden files are written by TestParquetGolden. Run this script after updating them:
+
+    pip install pyarrow
+    python3 testdata/parquet/verify.py
+"""
+
+import datetime
+import decimal
+import glob
+import json
+import os
+import sys
+
+import pyarrow.parquet as pq
+
+
+def normalize(value):
+    """Formats a value read by pyarrow in the same way as the JSON files."""
+    if value is None or isinstance(value, bool):
+        return value
+    if isinstance(value, list):
+        return [normalize(v) for v in value]
+    if isinstance(value, decimal.Decimal):
+        return format(value, "f")
+    if isinstance(value, datetime.datetime):
+        return value.astimezone(datetime.timezone.utc).strftime("%Y-%m-%dT%H:%M:%S.%fZ")
+    if isinstance(value, datetime.date):
+        return value.isoformat()
+    return str(value)
+
+
+def verify(json_path):
+    parquet_path = os.path.splitext(json_path)[0] + ".parquet"
+    with open(json_path, encoding="utf-8") as f:
+        want = json.load(f)
+
+    errors = []
+    file = pq.ParquetFile(parquet_path)
+    if file.metadata.num_row_groups != want["num_row_groups"]:
+        errors.append("row groups: got %d, want %d" % (file.metadata.num_row_groups, want["num_row_groups"]))
+
+    table = file.read()
+    schema = [{"name": field.name, "type": str(field.type)} for field in table.schema]
+    if schema != want["schema"]:
+        errors.append("schema: got %s, want %s" % (schema, want["schema"]))
+
+    names = [field["name"] for field in want["schema"]]
+    columns = [table.column(name).to_pylist() if name in table.column_names else [] for name in names]
+    rows = [[normalize(column[i]) for column in columns] for i in range(table.num_rows)]
+    if rows != want["rows"]:
+        errors.append("rows: got %s, want %s" % (rows, want["rows"]))
+
+    for error in errors:
+        print("%s: %s" % (parquet_path, error))
+    if not errors:
+        print("%s: OK" % parquet_path)
+    return not errors
+
+
+def main():
+    paths = sorted(glob.glob(os.path.join(os.path.dirname(os.path.abspath(__file__)), "*.json")))
+    results = [verify(path) for path in paths]
+    sys.exit(0 if paths and all(results) else 1)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,561 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	pb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/olekukonko/tablewriter"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// resultWriter writes rows of a result in a display mode.
+// Rows are written as they arrive, so that large results can be printed with bounded memory.
+type resultWriter interface {
+	// WriteHeader is called once before rows.
+	WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error
+	WriteRow(row Row) error
+	// Flush is called once after all rows.
+	Flush() error
+}
+
+// newResultWriter creates a resultWriter for the display mode with the settings of the system variables.
+func newResultWriter(out io.Writer, mode DisplayMode, sysVars *systemVariables) resultWriter {
+	format := valueFormatterOf(sysVars)
+	switch mode {
+	case DisplayModeVertical:
+		return &verticalWriter{out: out, format: format}
+	case DisplayModeTab:
+		return &tabWriter{out: out, format: format}
+	case DisplayModeCSV:
+		return newCSVWriter(out, sysVars)
+	case DisplayModeMarkdown:
+		return &markdownWriter{out: out, format: format}
+	case DisplayModeHTML:
+		return &htmlWriter{out: out, format: format}
+	case DisplayModeTemplate:
+		return &templateWriter{out: out, sysVars: sysVars}
+	case DisplayModeJSON, DisplayModeJSONL:
+		return &jsonWriter{out: out, lines: mode == DisplayModeJSONL, int64AsString: sysVars.JSONInt64AsString}
+	default:
+		// Column widths are computed from the first TablePreviewRows rows. 0 means all rows are buffered to compute them.
+		return &tableWriter{out: out, format: format, verbose: sysVars.CLIVerbose, previewRows: sysVars.TablePreviewRows}
+	}
+}
+
+// valueFormatterOf returns the formatter of values in the display modes which print values as text.
+func valueFormatterOf(sysVars *systemVariables) valueFormatter {
+	if sysVars.SQLLiteral {
+		return formatSQLLiteral
+	}
+	return DecodeColumn
+}
+
+// writeResult writes all rows of the result kept in memory.
+func writeResult(w resultWriter, result *Result) error {
+	if err := w.WriteHeader(result.ColumnNames, result.ColumnTypes); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		if err := w.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// rowCollector is a resultWriter which keeps rows in memory instead of writing them.
+type rowCollector struct {
+	rows []Row
+}
+
+func (c *rowCollector) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	return nil
+}
+
+func (c *rowCollector) WriteRow(row Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Flush() error {
+	return nil
+}
+
+type tableWriter struct {
+	out         io.Writer
+	format      valueFormatter
+	verbose     bool
+	previewRows int
+
+	header      []string
+	forceRender bool
+	rows        [][]string
+	widths      []int // column widths fixed by the preview window, nil until rows exceed it
+}
+
+func (w *tableWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	// This condition is true if statement is SelectStatement or DmlStatement
+	if w.verbose && len(columnTypes) > 0 {
+		w.forceRender = true
+		for _, field := range columnTypes {
+			w.header = append(w.header, field.GetName()+"\n"+formatTypeSimple(field.GetType()))
+		}
+		return nil
+	}
+	w.header = columnNames
+	return nil
+}
+
+func (w *tableWriter) WriteRow(row Row) error {
+	columns, err := formatRow(row, w.format)
+	if err != nil {
+		return err
+	}
+	if w.widths != nil {
+		w.writeLines(columns)
+		return nil
+	}
+
+	w.rows = append(w.rows, columns)
+	if w.previewRows > 0 && len(w.rows) >= w.previewRows {
+		w.startStreaming()
+	}
+	return nil
+}
+
+func (w *tableWriter) Flush() error {
+	if w.widths != nil {
+		w.writeBorder()
+		return nil
+	}
+	if !w.forceRender && len(w.rows) == 0 {
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w.out)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.SetHeader(w.header)
+	table.AppendBulk(w.rows)
+	table.Render()
+	return nil
+}
+
+// startStreaming fixes the column widths by the preview rows and writes them in the same format as tablewriter.
+// Subsequent rows are written immediately, and values wider than the preview rows are not aligned.
+func (w *tableWriter) startStreaming() {
+	w.widths = make([]int, len(w.header))
+	for _, columns := range append([][]string{w.header}, w.rows...) {
+		for i, column := range columns {
+			for _, line := range strings.Split(column, "\n") {
+				if width := tablewriter.DisplayWidth(line); i < len(w.widths) && width > w.widths[i] {
+					w.widths[i] = width
+				}
+			}
+		}
+	}
+
+	w.writeBorder()
+	w.writeLines(w.header)
+	w.writeBorder()
+	for _, columns := range w.rows {
+		w.writeLines(columns)
+	}
+	w.rows = nil
+}
+
+func (w *tableWriter) writeBorder() {
+	var sb strings.Builder
+	sb.WriteString("+")
+	for _, width := range w.widths {
+		sb.WriteString(strings.Repeat("-", width+2) + "+")
+	}
+	fmt.Fprintln(w.out, sb.String())
+}
+
+// writeLines writes the columns which may have multiple lines.
+func (w *tableWriter) writeLines(columns []string) {
+	var lines [][]string
+	var height int
+	for _, column := range columns {
+		columnLines := strings.Split(column, "\n")
+		lines = append(lines, columnLines)
+		if len(columnLines) > height {
+			height = len(columnLines)
+		}
+	}
+
+	for i := 0; i < height; i++ {
+		var sb strings.Builder
+		sb.WriteString("|")
+		for j, columnLines := range lines {
+			var line string
+			if i < len(columnLines) {
+				line = columnLines[i]
+			}
+			var width int
+			if j < len(w.widths) {
+				width = w.widths[j]
+			}
+			sb.WriteString(" " + tablewriter.PadRight(line, " ", width) + " |")
+		}
+		fmt.Fprintln(w.out, sb.String())
+	}
+}
+
+type verticalWriter struct {
+	out    io.Writer
+	format valueFormatter
+
+	columnNames []string
+	lineFormat  string
+	count       int
+}
+
+func (w *verticalWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	max := 0
+	for _, columnName := range columnNames {
+		if len(columnName) > max {
+			max = len(columnName)
+		}
+	}
+	w.columnNames = columnNames
+	w.lineFormat = fmt.Sprintf("%%%ds: %%s\n", max) // for align right
+	return nil
+}
+
+func (w *verticalWriter) WriteRow(row Row) error {
+	columns, err := formatRow(row, w.format)
+	if err != nil {
+		return err
+	}
+	w.count++
+	fmt.Fprintf(w.out, "*************************** %d. row ***************************\n", w.count)
+	for i, column := range columns {
+		fmt.Fprintf(w.out, w.lineFormat, w.columnNames[i], column)
+	}
+	return nil
+}
+
+func (w *verticalWriter) Flush() error {
+	return nil
+}
+
+type tabWriter struct {
+	out    io.Writer
+	format valueFormatter
+
+	hasColumns bool
+}
+
+func (w *tabWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	if len(columnNames) > 0 {
+		w.hasColumns = true
+		fmt.Fprintln(w.out, strings.Join(columnNames, "\t"))
+	}
+	return nil
+}
+
+func (w *tabWriter) WriteRow(row Row) error {
+	if !w.hasColumns {
+		return nil
+	}
+	columns, err := formatRow(row, w.format)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w.out, strings.Join(columns, "\t"))
+	return nil
+}
+
+func (w *tabWriter) Flush() error {
+	return nil
+}
+
+// csvWriter writes rows in RFC 4180 CSV.
+type csvWriter struct {
+	out       io.Writer
+	format    valueFormatter
+	header    bool
+	delimiter rune
+	quote     rune
+	null      string
+}
+
+func newCSVWriter(out io.Writer, sysVars *systemVariables) *csvWriter {
+	w := &csvWriter{
+		out:       out,
+		format:    valueFormatterOf(sysVars),
+		header:    sysVars.CSVHeader,
+		delimiter: sysVars.CSVDelimiter,
+		quote:     sysVars.CSVQuote,
+		null:      sysVars.CSVNull,
+	}
+	if w.delimiter == 0 {
+		w.delimiter = defaultCSVDelimiter
+	}
+	if w.quote == 0 {
+		w.quote = defaultCSVQuote
+	}
+	return w
+}
+
+func (w *csvWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	if !w.header || len(columnNames) == 0 {
+		return nil
+	}
+	fields := make([]string, len(columnNames))
+	for i, name := range columnNames {
+		fields[i] = w.quoteField(name, false)
+	}
+	return w.writeRecord(fields)
+}
+
+func (w *csvWriter) WriteRow(row Row) error {
+	fields := make([]string, len(row.Values))
+	for i, value := range row.Values {
+		if isNullValue(value) {
+			fields[i] = w.null
+			continue
+		}
+		s, err := w.format(value)
+		if err != nil {
+			return err
+		}
+		// A value same as the NULL representation is quoted to be distinguished from NULL.
+		fields[i] = w.quoteField(s, s == w.null)
+	}
+	return w.writeRecord(fields)
+}
+
+func (w *csvWriter) Flush() error {
+	return nil
+}
+
+// writeRecord writes the fields terminated by CRLF as RFC 4180 requires.
+func (w *csvWriter) writeRecord(fields []string) error {
+	_, err := fmt.Fprint(w.out, strings.Join(fields, string(w.delimiter)), "\r\n")
+	return err
+}
+
+// quoteField quotes the field if it contains the delimiter, the quote character or line breaks, or force is true.
+func (w *csvWriter) quoteField(field string, force bool) string {
+	if !force && !strings.ContainsAny(field, string([]rune{w.delimiter, w.quote, '\r', '\n'})) {
+		return field
+	}
+	quote := string(w.quote)
+	return quote + strings.ReplaceAll(field, quote, quote+quote) + quote
+}
+
+// jsonWriter writes rows as JSON objects keyed by column names.
+// It writes an array of the objects, or one object per line if lines is true.
+type jsonWriter struct {
+	out           io.Writer
+	lines         bool
+	int64AsString bool
+
+	columnNames []string
+	count       int
+}
+
+func (w *jsonWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	w.columnNames = columnNames
+	if !w.lines && len(columnNames) > 0 {
+		fmt.Fprint(w.out, "[")
+	}
+	return nil
+}
+
+func (w *jsonWriter) WriteRow(row Row) error {
+	if len(w.columnNames) == 0 {
+		return nil
+	}
+	types := make([]*pb.Type, len(row.Values))
+	values := make([]*structpb.Value, len(row.Values))
+	for i, value := range row.Values {
+		types[i] = value.Type
+		values[i] = value.Value
+	}
+	b, err := encodeJSONObject(w.columnNames, types, values, w.int64AsString)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case w.lines:
+		fmt.Fprintf(w.out, "%s\n", b)
+	case w.count == 0:
+		fmt.Fprintf(w.out, "\n  %s", b)
+	default:
+		fmt.Fprintf(w.out, ",\n  %s", b)
+	}
+	w.count++
+	return nil
+}
+
+func (w *jsonWriter) Flush() error {
+	switch {
+	case w.lines || len(w.columnNames) == 0:
+	case w.count == 0:
+		fmt.Fprintln(w.out, "]")
+	default:
+		fmt.Fprint(w.out, "\n]\n")
+	}
+	return nil
+}
+
+// markdownWriter writes rows as a GitHub Flavored Markdown table.
+type markdownWriter struct {
+	out    io.Writer
+	format valueFormatter
+
+	hasColumns bool
+}
+
+// markdownEscaper escapes characters which have special meanings in Markdown tables, including inline HTML.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"\n", "<br>",
+)
+
+func (w *markdownWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	if len(columnNames) == 0 {
+		return nil
+	}
+	w.hasColumns = true
+	w.writeCells(columnNames)
+	separators := make([]string, len(columnNames))
+	for i := range separators {
+		separators[i] = "---"
+	}
+	fmt.Fprintf(w.out, "| %s |\n", strings.Join(separators, " | "))
+	return nil
+}
+
+func (w *markdownWriter) WriteRow(row Row) error {
+	if !w.hasColumns {
+		return nil
+	}
+	columns, err := formatRow(row, w.format)
+	if err != nil {
+		return err
+	}
+	w.writeCells(columns)
+	return nil
+}
+
+func (w *markdownWriter) Flush() error {
+	if w.hasColumns {
+		fmt.Fprintln(w.out)
+	}
+	return nil
+}
+
+func (w *markdownWriter) writeCells(columns []string) {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = keepIndent(markdownEscaper.Replace(column))
+	}
+	fmt.Fprintf(w.out, "| %s |\n", strings.Join(cells, " | "))
+}
+
+// htmlWriter writes rows as an HTML table.
+type htmlWriter struct {
+	out    io.Writer
+	format valueFormatter
+
+	hasColumns bool
+}
+
+func (w *htmlWriter) WriteHeader(columnNames []string, columnTypes []*pb.StructType_Field) error {
+	if len(columnNames) == 0 {
+		return nil
+	}
+	w.hasColumns = true
+	fmt.Fprintln(w.out, "<table>")
+	w.writeCells("th", columnNames)
+	return nil
+}
+
+func (w *htmlWriter) WriteRow(row Row) error {
+	if !w.hasColumns {
+		return nil
+	}
+	columns, err := formatRow(row, w.format)
+	if err != nil {
+		return err
+	}
+	w.writeCells("td", columns)
+	return nil
+}
+
+func (w *htmlWriter) Flush() error {
+	if w.hasColumns {
+		fmt.Fprintln(w.out, "</table>")
+	}
+	return nil
+}
+
+func (w *htmlWriter) writeCells(tag string, columns []string) {
+	var sb strings.Builder
+	sb.WriteString("<tr>")
+	for _, column := range columns {
+		escaped := strings.ReplaceAll(html.EscapeString(column), "\n", "<br>")
+		fmt.Fprintf(&sb, "<%s>%s</%s>", tag, keepIndent(escaped), tag)
+	}
+	sb.WriteString("</tr>")
+	fmt.Fprintln(w.out, sb.String())
+}
+
+// keepIndent replaces leading spaces of each line with non-breaking spaces,
+// so that indentation such as query plan trees is kept in rendered HTML.
+func keepIndent(s string) string {
+	lines := strings.Split(s, "<br>")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		lines[i] = strings.Repeat("&nbsp;", len(line)-len(trimmed)) + trimmed
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// notifyingWriter calls notify once before the first write.
+type notifyingWriter struct {
+	out    io.Writer
+	notify func()
+}
+
+func (w *notifyingWriter) Write(p []byte) (int, error) {
+	if w.notify != nil {
+		w.notify()
+		w.notify = nil
+	}
+	return w.out.Write(p)
+}
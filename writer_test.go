@@ -0,0 +1,247 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestTableWriterPreview(t *testing.T) {
+	result := &Result{
+		ColumnNames: []string{"foo", "bar"},
+		Rows: []Row{
+			stringRow("1", "2"),
+			stringRow("3", "multi\nline"),
+			stringRow("55555", "6"),
+		},
+	}
+
+	for _, tt := range []struct {
+		desc        string
+		previewRows int
+		want        string
+	}{
+		{
+			desc:        "all rows in preview",
+			previewRows: 0,
+			want: `
++-------+-------+
+| foo   | bar   |
++-------+-------+
+| 1     | 2     |
+| 3     | multi |
+|       | line  |
+| 55555 | 6     |
++-------+-------+
+`,
+		},
+		{
+			desc:        "rows exceed preview",
+			previewRows: 2,
+			want: `
++-----+-------+
+| foo | bar   |
++-----+-------+
+| 1   | 2     |
+| 3   | multi |
+|     | line  |
+| 55555 | 6     |
++-----+-------+
+`,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := writeResult(newResultWriter(out, DisplayModeTable, &systemVariables{TablePreviewRows: tt.previewRows}), result); err != nil {
+				t.Fatalf("writeResult() got error: %v", err)
+			}
+			if want := strings.TrimPrefix(tt.want, "\n"); out.String() != want {
+				t.Errorf("invalid print: expected = %s, but got = %s", want, out.String())
+			}
+		})
+	}
+}
+
+func TestNotifyingWriter(t *testing.T) {
+	var notified int
+	out := &bytes.Buffer{}
+	w := &notifyingWriter{out: out, notify: func() { notified++ }}
+	w.Write([]byte("foo"))
+	w.Write([]byte("bar"))
+
+	if notified != 1 {
+		t.Errorf("notify is called %d times, but want once", notified)
+	}
+	if got := out.String(); got != "foobar" {
+		t.Errorf("written = %q, but want = %q", got, "foobar")
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	result := &Result{
+		ColumnNames: []string{"id", "name"},
+		Rows: []Row{
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(1)), createColumnValue(t, "a,b")}},
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(2)), createColumnValue(t, "say \"hi\"\nbye")}},
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(3)), createColumnValue(t, spanner.NullString{})}},
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(4)), createColumnValue(t, "")}},
+		},
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		sysVars *systemVariables
+		want    string
+	}{
+		{
+			desc:    "default",
+			sysVars: &systemVariables{CSVHeader: true},
+			want:    "id,name\r\n1,\"a,b\"\r\n2,\"say \"\"hi\"\"\nbye\"\r\n3,\r\n4,\"\"\r\n",
+		},
+		{
+			desc:    "without header",
+			sysVars: &systemVariables{},
+			want:    "1,\"a,b\"\r\n2,\"say \"\"hi\"\"\nbye\"\r\n3,\r\n4,\"\"\r\n",
+		},
+		{
+			desc:    "custom delimiter, quote and NULL",
+			sysVars: &systemVariables{CSVHeader: true, CSVDelimiter: '\t', CSVQuote: '\'', CSVNull: `\N`},
+			want:    "id\tname\r\n1\ta,b\r\n2\t'say \"hi\"\nbye'\r\n3\t\\N\r\n4\t\r\n",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := writeResult(newResultWriter(out, DisplayModeCSV, tt.sysVars), result); err != nil {
+				t.Fatalf("writeResult() got error: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("invalid print: expected = %q, but got = %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	result := &Result{
+		ColumnNames: []string{"id", "name"},
+		Rows: []Row{
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(1)), createColumnValue(t, "foo")}},
+			{Values: []spanner.GenericColumnValue{createColumnValue(t, int64(2)), createColumnValue(t, spanner.NullString{})}},
+		},
+		AffectedRows: 2,
+		Stats:        QueryStats{ElapsedTime: "1 msecs"},
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		mode    DisplayMode
+		sysVars *systemVariables
+		result  *Result
+		want    string
+	}{
+		{
+			desc:    "JSON",
+			mode:    DisplayModeJSON,
+			sysVars: &systemVariables{},
+			result:  result,
+			want:    "[\n  {\"id\":1,\"name\":\"foo\"},\n  {\"id\":2,\"name\":null}\n]\n",
+		},
+		{
+			desc:    "JSON with empty result",
+			mode:    DisplayModeJSON,
+			sysVars: &systemVariables{},
+			result:  &Result{ColumnNames: []string{"id"}},
+			want:    "[]\n",
+		},
+		{
+			desc:    "JSONL",
+			mode:    DisplayModeJSONL,
+			sysVars: &systemVariables{JSONInt64AsString: true},
+			result:  result,
+			want:    "{\"id\":\"1\",\"name\":\"foo\"}\n{\"id\":\"2\",\"name\":null}\n",
+		},
+		{
+			desc:    "JSONL with metadata",
+			mode:    DisplayModeJSONL,
+			sysVars: &systemVariables{CLIVerbose: true},
+			result:  result,
+			want:    "{\"id\":1,\"name\":\"foo\"}\n{\"id\":2,\"name\":null}\n{\"metadata\":{\"affected_rows\":2,\"elapsed_time\":\"1 msecs\"}}\n",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := printResult(out, tt.result, tt.mode, false, tt.sysVars); err != nil {
+				t.Fatalf("printResult() got error: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("invalid print: expected = %q, but got = %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMarkdownAndHTMLWriter(t *testing.T) {
+	result := &Result{
+		ColumnNames: []string{"ID", "Query_Execution_Plan"},
+		Rows: []Row{
+			stringRow("0", "Distributed Union"),
+			stringRow("*1", "  +- Filter <a|b & c>"),
+		},
+		Predicates: []string{"1: Condition: ($a < 1)"},
+	}
+
+	for _, tt := range []struct {
+		desc string
+		mode DisplayMode
+		want string
+	}{
+		{
+			desc: "markdown",
+			mode: DisplayModeMarkdown,
+			want: "| ID | Query\\_Execution\\_Plan |\n" +
+				"| --- | --- |\n" +
+				"| 0 | Distributed Union |\n" +
+				"| \\*1 | &nbsp;&nbsp;+- Filter &lt;a\\|b &amp; c&gt; |\n" +
+				"\n" +
+				"Predicates(identified by ID):\n\n```\n 1: Condition: ($a < 1)\n```\n\n",
+		},
+		{
+			desc: "HTML",
+			mode: DisplayModeHTML,
+			want: "<table>\n" +
+				"<tr><th>ID</th><th>Query_Execution_Plan</th></tr>\n" +
+				"<tr><td>0</td><td>Distributed Union</td></tr>\n" +
+				"<tr><td>*1</td><td>&nbsp;&nbsp;+- Filter &lt;a|b &amp; c&gt;</td></tr>\n" +
+				"</table>\n" +
+				"<p>Predicates(identified by ID):</p>\n<pre>\n 1: Condition: ($a &lt; 1)\n</pre>\n",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := printResult(out, result, tt.mode, false, &systemVariables{}); err != nil {
+				t.Fatalf("printResult() got error: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("invalid print: expected = %q, but got = %q", tt.want, got)
+			}
+		})
+	}
+}